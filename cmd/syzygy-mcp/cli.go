@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/application"
+	"github.com/cookchen233/syzygy-mcp-go/internal/infrastructure/persistence/fs"
+	"github.com/cookchen233/syzygy-mcp-go/internal/infrastructure/replay"
+)
+
+// cliCommand maps a CLI subcommand onto an MCP tool so maintenance tasks can be
+// run from a shell without an MCP host.
+type cliCommand struct {
+	Tool  string
+	Usage string
+	Args  func(argv []string) (map[string]any, error)
+}
+
+var cliCommands = map[string]cliCommand{
+	"migrate": {
+		Tool:  "syzygy_store_migrate",
+		Usage: "migrate [project_key]",
+		Args: func(argv []string) (map[string]any, error) {
+			args := map[string]any{}
+			if len(argv) > 0 {
+				args["project_key"] = argv[0]
+			}
+			return args, nil
+		},
+	},
+	"import": {
+		Tool:  "syzygy_spec_import",
+		Usage: "import <spec.json|dir> [project_key]",
+		Args: func(argv []string) (map[string]any, error) {
+			if len(argv) == 0 {
+				return nil, errors.New("missing path")
+			}
+			args := map[string]any{"path": argv[0]}
+			if len(argv) > 1 {
+				args["project_key"] = argv[1]
+			}
+			return args, nil
+		},
+	},
+}
+
+func runCLI(name string, argv []string, out io.Writer, logger *log.Logger) error {
+	c, ok := cliCommands[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+	args, err := c.Args(argv)
+	if err != nil {
+		return fmt.Errorf("usage: syzygy-mcp %s: %w", c.Usage, err)
+	}
+
+	store := fs.NewFileStore(fs.FileStoreConfig{})
+	app := application.NewApp(store, replay.NewEngine(replay.EngineConfig{}), logger)
+	res, err := app.ToolRegistry().CallTool(c.Tool, args)
+	if err != nil {
+		var appErr *application.AppError
+		if errors.As(err, &appErr) {
+			return fmt.Errorf("%s (%s)", appErr.Message, appErr.Code)
+		}
+		return err
+	}
+
+	b, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(b))
+	return err
+}
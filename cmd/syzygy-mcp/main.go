@@ -10,6 +10,14 @@ import (
 func main() {
 	logger := log.New(os.Stderr, "syzygy-mcp: ", log.LstdFlags|log.LUTC)
 
+	if len(os.Args) > 1 {
+		if err := runCLI(os.Args[1], os.Args[2:], os.Stdout, logger); err != nil {
+			logger.Printf("%s failed: %v", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	srv := mcp.NewServer(mcp.ServerConfig{
 		Name:    "syzygy-mcp",
 		Version: "0.1.0",
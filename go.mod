@@ -1,3 +1,11 @@
 module github.com/cookchen233/syzygy-mcp-go
 
 go 1.22
+
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+)
+
+require filippo.io/edwards25519 v1.1.0 // indirect
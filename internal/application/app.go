@@ -3,20 +3,26 @@ package application
 import "log"
 
 type App struct {
-	tools  *ToolRegistry
-	logger *log.Logger
+	tools     *ToolRegistry
+	resources *ResourceRegistry
+	logger    *log.Logger
 }
 
-func NewApp(store Store, logger *log.Logger) *App {
+func NewApp(store Store, executor SpecExecutor, logger *log.Logger) *App {
 	if logger == nil {
 		logger = log.Default()
 	}
 
-	svc := NewSyzygyService(store, logger)
+	svc := NewSyzygyService(store, executor, logger)
 	tools := NewToolRegistry(svc)
-	return &App{tools: tools, logger: logger}
+	resources := NewResourceRegistry(svc)
+	return &App{tools: tools, resources: resources, logger: logger}
 }
 
 func (a *App) ToolRegistry() *ToolRegistry {
 	return a.tools
 }
+
+func (a *App) ResourceRegistry() *ResourceRegistry {
+	return a.resources
+}
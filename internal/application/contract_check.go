@@ -0,0 +1,330 @@
+package application
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// Contract issue kinds reported by syzygy_contract_check.
+const (
+	contractUnknownPath   = "unknown_path"
+	contractWrongMethod   = "wrong_method"
+	contractRemovedField  = "removed_field"
+	contractStatusChanged = "status_changed"
+)
+
+var (
+	contractOriginVar = regexp.MustCompile(`^\$\{[^}]+\}`)
+	// contractJSONPathToken matches .name, ['name'], [0] and [*]; paths with
+	// filters or recursive descent are not checked.
+	contractJSONPathToken = regexp.MustCompile(`^(?:\.([A-Za-z_$][\w$-]*)|\['([^']*)'\]|\[(\d+|\*)\])`)
+)
+
+// contractRule is a net.call or net.must rule in the shape the check needs.
+type contractRule struct {
+	name     string
+	method   string
+	url      string
+	status   string
+	fields   []string
+	exactURL bool
+}
+
+// ContractIssue is one drift between a unit and the OpenAPI document.
+type ContractIssue struct {
+	UnitID string `json:"unit_id"`
+	RunID  string `json:"run_id"`
+	StepID string `json:"step_id"`
+	Rule   string `json:"rule"`
+	Kind   string `json:"kind"`
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url"`
+	Field  string `json:"field,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// ContractCheck compares the net.call and net.must rules of the latest run of
+// each unit against a local OpenAPI 3 JSON document.
+func (s *SyzygyService) ContractCheck(projectKey string, path string, unitIDs []string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	doc, err := loadOpenAPI(path)
+	if err != nil {
+		return nil, err
+	}
+	ops := doc.operations()
+	units, err := s.projectUnits(projectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []ContractIssue{}
+	checked, rules := 0, 0
+	for _, u := range units {
+		if len(unitIDs) > 0 && !slices.Contains(unitIDs, u.UnitID) {
+			continue
+		}
+		run, err := findRun(u, latestRunID(u))
+		if err != nil {
+			continue
+		}
+		checked++
+		for _, st := range concatSteps(run.Setup, run.Steps, run.Teardown) {
+			for _, rule := range contractRules(st) {
+				rules++
+				for _, issue := range doc.contractIssues(ops, rule) {
+					issue.UnitID, issue.RunID, issue.StepID = u.UnitID, run.RunID, st.StepID
+					issue.Rule, issue.Method, issue.URL = rule.name, rule.method, rule.url
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+	return map[string]any{
+		"ok":            len(issues) == 0,
+		"openapi":       path,
+		"units_checked": checked,
+		"rules_checked": rules,
+		"issues":        issues,
+	}, nil
+}
+
+// contractRules extracts the net.call of a step followed by its net.must rules.
+func contractRules(st *domain.ActionStep) []contractRule {
+	if st == nil || st.Net == nil {
+		return nil
+	}
+	out := []contractRule{}
+	prefix := "net.must"
+	op, err := st.Op()
+	if call, ok := op.(*domain.NetCall); ok && err == nil {
+		prefix = "net.call.must"
+		r := contractRule{name: "net.call", method: strings.ToUpper(call.Method), url: call.URL, status: call.Status.String(), exactURL: true}
+		if r.method == "" {
+			r.method = "GET"
+		}
+		r.fields = append(contractFields(call.ExpectJSON, call.ExpectJSONPath, call.Anchor), call.ExpectPresent...)
+		out = append(out, r)
+	}
+	must, err := st.NetRules()
+	if err != nil {
+		return out
+	}
+	for i, m := range must {
+		r := contractRule{name: fmt.Sprintf("%s[%d]", prefix, i), method: strings.ToUpper(m.Method), url: m.URLContains, status: m.Status.String()}
+		r.fields = contractFields(m.ExpectJSON, m.ExpectJSONPath, m.Anchor)
+		for _, captures := range []map[string]string{m.CaptureAnchors, m.Anchors} {
+			for _, key := range sortedKeys(captures) {
+				r.fields = append(r.fields, captures[key])
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// contractFields lists the response JSONPaths a rule depends on.
+func contractFields(expectJSON, expectJSONPath map[string]any, anchor *domain.NetAnchor) []string {
+	out := []string{}
+	for _, k := range sortedKeys(expectJSON) {
+		out = append(out, "$['"+k+"']")
+	}
+	out = append(out, sortedKeys(expectJSONPath)...)
+	if anchor != nil && anchor.JSONPath != "" {
+		out = append(out, anchor.JSONPath)
+	}
+	return out
+}
+
+func (d *openapiDoc) contractIssues(ops []*openapiOperation, rule contractRule) []ContractIssue {
+	p := contractPath(rule.url)
+	if p == "" {
+		// url_contains that is empty or only a placeholder matches any request.
+		return nil
+	}
+	matched := []*openapiOperation{}
+	methods := []string{}
+	for _, op := range ops {
+		if !d.contractMatches(p, op.Path, rule.exactURL) {
+			continue
+		}
+		if rule.method == "" || op.Method == rule.method {
+			matched = append(matched, op)
+		} else if !slices.Contains(methods, op.Method) {
+			methods = append(methods, op.Method)
+		}
+	}
+	if len(matched) == 0 && len(methods) == 0 {
+		return []ContractIssue{{Kind: contractUnknownPath, Detail: fmt.Sprintf("no operation in the document matches %s", p)}}
+	}
+	if len(matched) == 0 {
+		return []ContractIssue{{Kind: contractWrongMethod, Detail: fmt.Sprintf("%s is not documented for this path; documented: %s", rule.method, strings.Join(methods, ", "))}}
+	}
+
+	out := []ContractIssue{}
+	if rule.status != "" {
+		documented := []string{}
+		found := false
+		for _, op := range matched {
+			responses, _ := op.op["responses"].(map[string]any)
+			for _, code := range sortedKeys(responses) {
+				documented = append(documented, code)
+				if contractStatusMatches(code, rule.status) {
+					found = true
+				}
+			}
+		}
+		if !found && len(documented) > 0 {
+			out = append(out, ContractIssue{Kind: contractStatusChanged, Detail: fmt.Sprintf("status %s is not documented; documented: %s", rule.status, strings.Join(documented, ", "))})
+		}
+	}
+	for _, field := range rule.fields {
+		known, present := false, false
+		for _, op := range matched {
+			schema := d.responseSchema(op, rule.status)
+			if schema == nil {
+				continue
+			}
+			ok, checkable := d.schemaHasPath(schema, field)
+			if !checkable {
+				continue
+			}
+			known = true
+			present = present || ok
+		}
+		if known && !present {
+			out = append(out, ContractIssue{Kind: contractRemovedField, Field: field, Detail: fmt.Sprintf("%s is not in the documented response schema", field)})
+		}
+	}
+	return out
+}
+
+// contractPath reduces a rule URL to its path: origin, leading ${origin}
+// placeholder, query and fragment are dropped.
+func contractPath(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		raw = u.Path
+	}
+	if m := contractOriginVar.FindString(raw); m != "" && (len(m) == len(raw) || raw[len(m)] == '/') {
+		raw = raw[len(m):]
+	}
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	return raw
+}
+
+// contractMatches reports whether path (from a spec, with ${var} segments)
+// matches an operation path template (with {param} segments), with or
+// without the server base path. Inexact matches are for url_contains, where
+// path may be any part of the request URL.
+func (d *openapiDoc) contractMatches(path, template string, exact bool) bool {
+	want := strings.Split(path, "/")
+	for _, t := range []string{d.basePath + template, template} {
+		have := strings.Split(t, "/")
+		if exact {
+			if len(want) == len(have) && contractSegments(want, have, true) {
+				return true
+			}
+			continue
+		}
+		for i := 0; i+len(want) <= len(have); i++ {
+			if contractSegments(want, have[i:i+len(want)], false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contractSegments(want, have []string, exact bool) bool {
+	for j := range want {
+		w, h := want[j], have[j]
+		switch {
+		case w == h, strings.Contains(w, "${"), openapiPathParam.MatchString(h):
+		case !exact && len(want) == 1 && strings.Contains(h, w):
+		case !exact && j == 0 && strings.HasSuffix(h, w):
+		case !exact && j == len(want)-1 && strings.HasPrefix(h, w):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// contractStatusMatches matches a status against a response key such as 200,
+// 2XX or default.
+func contractStatusMatches(code, status string) bool {
+	code = strings.ToUpper(code)
+	if code == "DEFAULT" || code == status {
+		return true
+	}
+	return len(code) == 3 && strings.HasSuffix(code, "XX") && strings.HasPrefix(status, code[:1])
+}
+
+// responseSchema returns the JSON schema of the response for status, falling
+// back to the first 2xx response when status is empty or not documented.
+func (d *openapiDoc) responseSchema(op *openapiOperation, status string) any {
+	responses, _ := op.op["responses"].(map[string]any)
+	for _, match := range []func(code string) bool{
+		func(code string) bool { return status != "" && contractStatusMatches(code, status) },
+		func(code string) bool { return strings.HasPrefix(code, "2") },
+	} {
+		for _, code := range sortedKeys(responses) {
+			if !match(code) {
+				continue
+			}
+			res, _ := d.resolve(responses[code]).(map[string]any)
+			content, _ := res["content"].(map[string]any)
+			if _, media := openapiContent(content); media != nil {
+				return media["schema"]
+			}
+		}
+	}
+	return nil
+}
+
+// schemaHasPath walks a simple JSONPath through a schema. checkable is false
+// when the path or the schema cannot tell, e.g. filters or free-form objects.
+func (d *openapiDoc) schemaHasPath(schema any, jsonPath string) (ok, checkable bool) {
+	rest := strings.TrimPrefix(strings.TrimSpace(jsonPath), "$")
+	if rest == jsonPath {
+		return false, false
+	}
+	cur := d.schema(schema, 0)
+	for rest != "" {
+		if cur == nil {
+			return false, false
+		}
+		m := contractJSONPathToken.FindStringSubmatch(rest)
+		if m == nil {
+			return false, false
+		}
+		rest = rest[len(m[0]):]
+		if m[3] != "" {
+			if cur["type"] != "array" {
+				return false, cur["type"] != nil
+			}
+			cur = d.schema(cur["items"], 0)
+			continue
+		}
+		name := m[1] + m[2]
+		props, hasProps := cur["properties"].(map[string]any)
+		if p, found := props[name]; found {
+			cur = d.schema(p, 0)
+			continue
+		}
+		// Free-form objects may carry any field.
+		ap, hasAP := cur["additionalProperties"]
+		if hasAP && ap != false || !hasProps && (cur["type"] == nil || cur["type"] == "object") {
+			return false, false
+		}
+		return false, true
+	}
+	return true, true
+}
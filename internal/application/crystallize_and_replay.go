@@ -8,9 +8,15 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
 )
 
-func (s *SyzygyService) Crystallize(projectKey string, unitID, runID, template, outputDir string) (map[string]any, error) {
+// Crystallize writes the spec of a run and moves it to crystallized. Other
+// templates render their artifacts from the run in any status, without
+// writing spec.json or changing the status, so accepted runs can still be
+// documented or exported.
+func (s *SyzygyService) Crystallize(projectKey string, unitID, runID, template, outputDir string, dryRun bool) (map[string]any, error) {
 	projectKey = defaultProjectKey(projectKey)
 	cfg, _ := s.EnsureProjectInitialized(projectKey)
 	u, err := s.store.GetUnit(projectKey, unitID)
@@ -21,8 +27,38 @@ func (s *SyzygyService) Crystallize(projectKey string, unitID, runID, template,
 	if err != nil {
 		return nil, err
 	}
+	if template == "" {
+		template = templateSpecJSON
+	}
+	writeSpec := template == templateSpecJSON
+	if writeSpec {
+		if err := ensureRunOpen(run); err != nil {
+			return nil, err
+		}
+	}
+	spec := buildSpec(u, run)
+	var datasources map[string]domain.Datasource
+	if cfg != nil {
+		datasources = cfg.Datasources
+	}
+	if err := guardSQL(datasources, spec); err != nil {
+		return nil, err
+	}
+	tpl, err := findTemplate(cfg, template)
+	if err != nil {
+		return nil, err
+	}
 
+	specPath := ""
+	// rootDir receives project-level outputs such as the docs index.
+	rootDir := outputDir
 	if outputDir == "" {
+		// Units imported from a spec file write the spec back to that file;
+		// otherwise the project's spec repository is used when configured.
+		specPath, _ = u.Meta[metaSourceSpecPath].(string)
+		if specPath == "" && cfg != nil && strings.TrimSpace(cfg.SpecsDir) != "" {
+			specPath = filepath.Join(strings.TrimSpace(cfg.SpecsDir), unitID+".spec.json")
+		}
 		base := ""
 		if cfg != nil {
 			base = strings.TrimSpace(cfg.ArtifactsDir)
@@ -30,58 +66,117 @@ func (s *SyzygyService) Crystallize(projectKey string, unitID, runID, template,
 		if base == "" {
 			base = "./syzygy-artifacts"
 		}
+		rootDir = base
 		outputDir = filepath.Join(base, unitID, runID)
 	}
-	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+	if specPath == "" {
+		specPath = filepath.Join(outputDir, "spec.json")
+	}
+	spec.Prerequisites = rebasePrerequisites(u, filepath.Dir(specPath))
+	b, err := marshalValidSpec(spec)
+	if err != nil {
 		return nil, err
 	}
 
-	if template == "" {
-		template = "spec_json"
+	// Overwriting an existing spec reports what changes, so reviewers see the
+	// effect on the spec repository before committing it.
+	specDiff := ""
+	overwrite := false
+	if writeSpec {
+		if old, err := os.ReadFile(specPath); err == nil {
+			overwrite = true
+			if string(old) != string(b) {
+				specDiff = lineDiff(string(old), string(b), 3)
+			}
+		}
+	}
+	if dryRun && writeSpec {
+		return map[string]any{
+			"dry_run":      true,
+			"spec_path":    specPath,
+			"overwrite":    overwrite,
+			"spec_changed": !overwrite || specDiff != "",
+			"spec_diff":    specDiff,
+			"run_status":   run.Status,
+		}, nil
 	}
 
 	paths := map[string]string{}
+	if writeSpec {
+		if err := os.MkdirAll(filepath.Dir(specPath), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(specPath, b, 0o644); err != nil {
+			return nil, err
+		}
+		paths["spec"] = specPath
+		if err := transitionRun(run, domain.RunStatusCrystallized, "syzygy_crystallize"); err != nil {
+			return nil, err
+		}
+	}
 
-	// 1) Save spec
-	specPath := filepath.Join(outputDir, "spec.json")
-	b, err := json.MarshalIndent(map[string]any{
-		"unit_id":   unitID,
-		"run_id":    runID,
-		"steps":     run.Steps,
-		"anchors":   run.Anchors,
-		"db_checks": run.DBChecks,
-		"variables": run.Variables,
-		"env":       u.Env,
-	}, "", "  ")
+	// Render the template's artifacts next to the run outputs.
+	units, err := s.projectUnits(projectKey)
 	if err != nil {
 		return nil, err
 	}
-	if err := os.WriteFile(specPath, b, 0o644); err != nil {
-		return nil, err
+	outputs, err := tpl.Render(&TemplateData{
+		Unit:          u,
+		Run:           run,
+		Config:        cfg,
+		Spec:          spec,
+		Prerequisites: prerequisiteSpecs(u),
+		Units:         units,
+		OutputDir:     outputDir,
+		RootDir:       rootDir,
+	})
+	if err != nil {
+		return nil, NewAppError("template_failed", fmt.Sprintf("template %s: %v", tpl.Name, err))
+	}
+	for _, out := range outputs {
+		p := filepath.Join(outputDir, out.File)
+		if out.Root {
+			p = filepath.Join(rootDir, out.File)
+		}
+		paths[out.Artifact] = p
+	}
+	if dryRun {
+		return map[string]any{"dry_run": true, "template": tpl.Name, "artifact_paths": paths, "run_status": run.Status}, nil
+	}
+	for _, out := range outputs {
+		p := paths[out.Artifact]
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(p, out.Content, 0o644); err != nil {
+			return nil, err
+		}
 	}
-	paths["spec"] = specPath
-
-	// 2) Save a minimal Playwright TS template (optional)
-	pwPath := filepath.Join(outputDir, "e2e.spec.ts")
-	pwContent := []byte("import { test, expect } from '@playwright/test'\n\n" +
-		"test('SYZYGY unit " + unitID + "', async ({ page }) => {\n" +
-		"  // TODO: load spec.json and execute steps via your runner\n" +
-		"  await page.goto(process.env.BASE_URL || 'http://localhost');\n" +
-		"  await expect(page).toBeTruthy();\n" +
-		"});\n")
-	_ = os.WriteFile(pwPath, pwContent, 0o644)
-	paths["playwright_ts"] = pwPath
 
-	run.Artifacts = paths
+	if run.Artifacts == nil {
+		run.Artifacts = map[string]string{}
+	}
+	for k, v := range paths {
+		run.Artifacts[k] = v
+	}
 	u.UpdatedAt = time.Now().UTC()
 	if err := s.store.SaveUnit(projectKey, u); err != nil {
 		return nil, err
 	}
 
-	return map[string]any{"artifact_paths": paths}, nil
+	result := map[string]any{"artifact_paths": paths, "template": tpl.Name, "run_status": run.Status}
+	if overwrite {
+		result["spec_changed"] = specDiff != ""
+		result["spec_diff"] = specDiff
+	}
+	// Unresolved placeholders do not block crystallize but fail syzygy_selfcheck.
+	if issues := domain.AnalyzeVariables(u.Env, run, prerequisiteKeys(u)); len(issues) > 0 {
+		result["variable_issues"] = issues
+	}
+	return result, nil
 }
 
-func (s *SyzygyService) Replay(projectKey string, unitID, runID, command string, args []string, cwd string, env map[string]any) (map[string]any, error) {
+func (s *SyzygyService) Replay(projectKey string, unitID, runID, command string, args []string, cwd string, env map[string]any, engine string, snapshot bool) (map[string]any, error) {
 	projectKey = defaultProjectKey(projectKey)
 	cfg, err := s.EnsureProjectInitialized(projectKey)
 	if err != nil {
@@ -96,81 +191,151 @@ func (s *SyzygyService) Replay(projectKey string, unitID, runID, command string,
 	if err != nil {
 		return nil, err
 	}
+	// Accepted runs may be replayed for regression without leaving their terminal status.
+	if run.Status == domain.RunStatusAbandoned {
+		return nil, NewAppError("run_closed", fmt.Sprintf("run %s is abandoned", runID))
+	}
+	if !domain.IsRunClosed(run.Status) && !domain.CanTransitionRun(run.Status, domain.RunStatusReplayPassed) {
+		return nil, NewAppError("invalid_run_transition", fmt.Sprintf("run %s is %s; run syzygy_crystallize before replay", runID, run.Status))
+	}
 
+	engine = strings.TrimSpace(engine)
+	if engine == "" {
+		engine = ReplayEngineAuto
+	}
+	if engine != ReplayEngineAuto && engine != ReplayEngineNode && engine != ReplayEngineGo {
+		return nil, NewAppError("invalid_args", "engine must be auto, node or go")
+	}
+	if command != "" && engine == ReplayEngineGo {
+		return nil, NewAppError("invalid_args", "command cannot be combined with engine=go")
+	}
+
+	specPath := ""
+	if run.Artifacts != nil {
+		specPath = run.Artifacts["spec"]
+	}
 	if command == "" {
-		specPath := ""
-		if run.Artifacts != nil {
-			specPath = run.Artifacts["spec"]
-		}
 		if specPath == "" {
 			return nil, NewAppError("missing_artifact", "spec artifact not found; run syzygy_crystallize first")
 		}
-
-		// Default: call configured runner command
-		command = cfg.RunnerCommand
-		args = []string{specPath}
-		if command == "" {
-			command = "syzygy-runner"
-		}
 		if cwd == "" {
 			cwd = cfg.RunnerDir
 		}
-		if env == nil {
-			env = map[string]any{}
-		}
-		env["SYZYGY_SPEC"] = specPath
 	}
+	procEnv := replayEnv(cfg, u, env)
 
-	// 环境检查和命令验证
-	if err := s.validateCommand(command); err != nil {
-		// 严格模式：环境问题必须明确报告，不允许mock通过
-		return nil, NewAppError("environment_error", fmt.Sprintf("Command validation failed: %v. This is an environment issue that must be resolved before replay can proceed. Please check your PATH and command availability.", err))
+	var snap *dbSnapshot
+	if snapshot {
+		if snap, err = s.snapshotBefore(cfg, u, run, procEnv); err != nil {
+			return nil, err
+		}
 	}
 
-	cmd := exec.Command(command, args...)
-	if cwd != "" {
-		cmd.Dir = cwd
+	var result map[string]any
+	var failed bool
+	var after []*domain.TableSnapshot
+	anchors := run.Anchors
+	var specs []*domain.Spec
+	if command == "" {
+		if specs, err = replaySpecs(specPath); err != nil {
+			return nil, err
+		}
 	}
-	cmd.Env = os.Environ()
-
-	// 1) project config env
-	for k, v := range cfg.Env {
-		cmd.Env = append(cmd.Env, k+"="+v)
+	// A custom command gets the fixtures of the run itself.
+	fixtureSpecs := specs
+	if command != "" {
+		fixtureSpecs = []*domain.Spec{buildSpec(u, run)}
 	}
-
-	// 2) unit env (string only)
-	for k, v := range u.Env {
-		if sv, ok := v.(string); ok {
-			cmd.Env = append(cmd.Env, k+"="+sv)
+	for _, sp := range fixtureSpecs {
+		if err := sp.ValidateFixtures(); err != nil {
+			return nil, NewAppError("invalid_spec", fmt.Sprintf("%s: %v", sp.UnitID, err))
 		}
 	}
-
-	// 3) tool-call env (best effort stringify)
-	for k, v := range env {
-		sv := anyToString(v)
-		if sv != "" {
-			cmd.Env = append(cmd.Env, k+"="+sv)
+	if err := guardSQL(cfg.Datasources, fixtureSpecs...); err != nil {
+		return nil, err
+	}
+	rollback := fixtureSpecs[len(fixtureSpecs)-1].Isolation == domain.IsolationRollback
+	if command == "" && engine != ReplayEngineNode {
+		native := s.executor != nil && !domain.HasUISteps(specs...)
+		if engine == ReplayEngineGo && !native {
+			if s.executor == nil {
+				return nil, NewAppError("environment_error", "the go replay engine is not available")
+			}
+			return nil, NewAppError("invalid_args", "the spec or its prerequisites have ui steps; only the node runner can replay them")
+		}
+		if native {
+			var report *domain.ReplayReport
+			result, report = s.replayNative(cfg, specs, specPath, cwd, procEnv, run, snap.tableRefs())
+			failed, anchors, after = !report.OK, report.Anchors, report.Snapshot
+		}
+	}
+	if result == nil {
+		if rollback {
+			return nil, NewAppError("invalid_args", "isolation rollback needs the go replay engine; remove engine=node or command")
+		}
+		// The runner skips DB checks it cannot evaluate; they run here after it.
+		extended := false
+		runner := command == ""
+		if runner {
+			if c := domain.HasExtendedDbChecks(specs...); c != nil {
+				if s.executor == nil {
+					return nil, NewAppError("environment_error", fmt.Sprintf("db check %s uses assertions only the go engine evaluates, which is not available", c.Name))
+				}
+				extended = true
+				procEnv = append(procEnv, "SYZYGY_DB_CHECKS=basic")
+			}
+			// Default: call configured runner command
+			command = cfg.RunnerCommand
+			args = []string{specPath}
+			if command == "" {
+				command = "syzygy-runner"
+			}
+			procEnv = append(procEnv, "SYZYGY_SPEC="+specPath)
+		}
+		opts := domain.ReplayOptions{Env: envMap(procEnv), Datasources: cfg.Datasources, Since: time.Now(), Snapshot: snap.tableRefs()}
+		if runner {
+			dbEnv, err := s.runnerDatasourceEnv(specs, opts)
+			if err != nil {
+				return nil, err
+			}
+			procEnv = append(procEnv, dbEnv...)
+		}
+		result, failed, after, err = s.replayCommandWithFixtures(fixtureSpecs, opts, func(anchors map[string]string) (map[string]any, bool, error) {
+			cmdEnv := procEnv
+			if len(anchors) > 0 {
+				// Anchors captured by setup steps, merged over the recorded ones.
+				b, _ := json.Marshal(anchors)
+				cmdEnv = append(append([]string{}, procEnv...), "SYZYGY_ANCHORS="+string(b))
+			}
+			result, failed, err := s.replayCommand(command, args, cwd, cmdEnv, specPath, run)
+			if err == nil && !failed && extended {
+				failed = s.runExtendedDbChecks(specs, result, opts)
+			}
+			return result, failed, err
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	out, err := cmd.CombinedOutput()
+	if snap != nil {
+		result["db_diff"] = s.snapshotAfter(cfg, u, run, snap, after, anchors, !failed)
+	}
 
 	// 保存replay结果到run.Meta供selfcheck检测
 	if run.Meta == nil {
 		run.Meta = map[string]any{}
 	}
-
-	var result map[string]any
-	if err != nil {
-		msg := err.Error()
-		// Add actionable hints for common runner dependency issues
-		if strings.Contains(string(out), "Cannot find package 'playwright'") || strings.Contains(string(out), "Cannot find module") {
-			msg = msg + "; runner dependencies missing. Please run: cd <runner-node> && npm install && npx playwright install"
+	if !domain.IsRunClosed(run.Status) {
+		to := domain.RunStatusReplayPassed
+		if failed {
+			to = domain.RunStatusReplayFailed
+		}
+		if tErr := transitionRun(run, to, "syzygy_replay"); tErr != nil {
+			return nil, tErr
 		}
-		result = map[string]any{"ok": false, "output": string(out), "error": msg, "anchors": run.Anchors}
-	} else {
-		result = map[string]any{"ok": true, "output": string(out), "anchors": run.Anchors}
 	}
+	result["run_status"] = run.Status
 
 	// 将replay结果保存到meta中
 	run.Meta["replay_result"] = result
@@ -184,6 +349,81 @@ func (s *SyzygyService) Replay(projectKey string, unitID, runID, command string,
 	return result, nil
 }
 
+// replayCommand runs the Node runner (or a custom command) and reports whether
+// it failed.
+func (s *SyzygyService) replayCommand(command string, args []string, cwd string, procEnv []string, specPath string, run *domain.Run) (map[string]any, bool, error) {
+	// 环境检查和命令验证
+	if err := s.validateCommand(command); err != nil {
+		// 严格模式：环境问题必须明确报告，不允许mock通过
+		return nil, false, NewAppError("environment_error", fmt.Sprintf("Command validation failed: %v. This is an environment issue that must be resolved before replay can proceed. Please check your PATH and command availability.", err))
+	}
+
+	cmd := exec.Command(command, args...)
+	if cwd != "" {
+		cmd.Dir = cwd
+	}
+	cmd.Env = procEnv
+
+	started := time.Now()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := err.Error()
+		// Add actionable hints for common runner dependency issues
+		if strings.Contains(string(out), "Cannot find package 'playwright'") || strings.Contains(string(out), "Cannot find module") {
+			msg = msg + "; runner dependencies missing. Please run: cd <runner-node> && npm install && npx playwright install"
+		}
+		result := map[string]any{"ok": false, "engine": ReplayEngineNode, "output": string(out), "error": msg, "anchors": run.Anchors}
+		if files := failureArtifacts(runnerArtifactsDir(procEnv, cwd, specPath), started); len(files) > 0 {
+			result["failure_artifacts"] = files
+		}
+		return result, true, nil
+	}
+	return map[string]any{"ok": true, "engine": ReplayEngineNode, "output": string(out), "anchors": run.Anchors}, false, nil
+}
+
+// runnerArtifactsDir mirrors where syzygy-runner writes failure artifacts:
+// SYZYGY_ARTIFACTS_DIR, otherwise ../artifacts next to the spec, both resolved
+// against the runner's working directory.
+func runnerArtifactsDir(procEnv []string, cwd, specPath string) string {
+	dir := ""
+	for _, kv := range procEnv {
+		if v, ok := strings.CutPrefix(kv, "SYZYGY_ARTIFACTS_DIR="); ok {
+			dir = v
+		}
+	}
+	if dir == "" && specPath != "" {
+		dir = filepath.Join(filepath.Dir(specPath), "..", "artifacts")
+	}
+	if dir != "" && !filepath.IsAbs(dir) && cwd != "" {
+		dir = filepath.Join(cwd, dir)
+	}
+	return dir
+}
+
+// failureArtifacts lists the *-failed.* files the runner wrote since a replay
+// started (screenshot, HTML dump, page info, DB check snapshot).
+func failureArtifacts(dir string, since time.Time) []string {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	out := []string{}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || !strings.Contains(e.Name(), "-failed.") {
+			continue
+		}
+		if info.ModTime().Before(since.Truncate(time.Second)) {
+			continue
+		}
+		out = append(out, filepath.Join(dir, e.Name()))
+	}
+	return out
+}
+
 // validateCommand 检查命令是否存在且可执行
 func (s *SyzygyService) validateCommand(command string) error {
 	// 检查是否是绝对路径
@@ -0,0 +1,286 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// maxSuggestions caps the DB checks syzygy_dbcheck_suggest proposes.
+const maxSuggestions = 50
+
+// dbSnapshot is the state of the touchpoints.db_tables taken before a replay.
+type dbSnapshot struct {
+	tables []string
+	spec   *domain.Spec
+	opts   domain.ReplayOptions
+	before []*domain.TableSnapshot
+}
+
+// dbDiffArtifact is the db-diff.json a snapshot replay writes next to the
+// run's crystallized artifacts.
+type dbDiffArtifact struct {
+	UnitID    string             `json:"unit_id"`
+	RunID     string             `json:"run_id"`
+	CreatedAt string             `json:"created_at"`
+	ReplayOK  bool               `json:"replay_ok"`
+	Anchors   map[string]string  `json:"anchors"`
+	Tables    []domain.TableDiff `json:"tables"`
+}
+
+func snapshotTables(u *domain.Unit) []string {
+	touch, _ := u.Meta["touchpoints"].(map[string]any)
+	return toStringSliceAny(touch["db_tables"])
+}
+
+// snapshotBefore reads the unit's touchpoints.db_tables with the datasources
+// the replay uses.
+func (s *SyzygyService) snapshotBefore(cfg *ProjectConfig, u *domain.Unit, run *domain.Run, procEnv []string) (*dbSnapshot, error) {
+	if s.executor == nil {
+		return nil, NewAppError("environment_error", "the go replay engine is not available")
+	}
+	tables := snapshotTables(u)
+	if len(tables) == 0 {
+		return nil, NewAppError("invalid_args", "snapshot needs touchpoints.db_tables in the unit meta")
+	}
+	for _, t := range tables {
+		if _, _, err := domain.ParseTableRef(t); err != nil {
+			return nil, NewAppError("invalid_args", err.Error())
+		}
+	}
+	snap := &dbSnapshot{
+		tables: tables,
+		spec:   buildSpec(u, run),
+		opts:   domain.ReplayOptions{Env: envMap(procEnv), Datasources: cfg.Datasources},
+	}
+	snap.before = s.executor.Snapshot(snap.spec, tables, snap.opts)
+	return snap, nil
+}
+
+// tableRefs lists the tables to read after the replay, nil without a snapshot.
+func (snap *dbSnapshot) tableRefs() []string {
+	if snap == nil {
+		return nil
+	}
+	return snap.tables
+}
+
+// snapshotAfter writes the row-level diff between the snapshot before and
+// after, the tables the replay read before teardown, as the run's db_diff
+// artifact and returns a per-table summary for the replay result. Without
+// after (no teardown ran) the tables are read now.
+func (s *SyzygyService) snapshotAfter(cfg *ProjectConfig, u *domain.Unit, run *domain.Run, snap *dbSnapshot, after []*domain.TableSnapshot, anchors map[string]string, ok bool) map[string]any {
+	if after == nil {
+		after = s.executor.Snapshot(snap.spec, snap.tables, snap.opts)
+	}
+	art := dbDiffArtifact{
+		UnitID:    u.UnitID,
+		RunID:     run.RunID,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		ReplayOK:  ok,
+		Anchors:   anchors,
+		Tables:    make([]domain.TableDiff, len(after)),
+	}
+	summary := []map[string]any{}
+	for i := range after {
+		d := domain.DiffTable(snap.before[i], after[i])
+		art.Tables[i] = d
+		row := map[string]any{"table": d.Table, "inserted": len(d.Inserted), "updated": len(d.Updated), "deleted": len(d.Deleted)}
+		if d.Datasource != "" {
+			row["datasource"] = d.Datasource
+		}
+		if d.Truncated {
+			row["truncated"] = true
+		}
+		if d.Error != "" {
+			row["error"] = d.Error
+		}
+		summary = append(summary, row)
+	}
+	out := map[string]any{"tables": summary}
+
+	base := strings.TrimSpace(cfg.ArtifactsDir)
+	if base == "" {
+		base = "./syzygy-artifacts"
+	}
+	path := filepath.Join(base, u.UnitID, run.RunID, "db-diff.json")
+	b, _ := json.MarshalIndent(art, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		out["error"] = err.Error()
+		return out
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		out["error"] = err.Error()
+		return out
+	}
+	if run.Artifacts == nil {
+		run.Artifacts = map[string]string{}
+	}
+	run.Artifacts["db_diff"] = path
+	out["artifact"] = path
+	return out
+}
+
+// DbCheckSuggest turns the row-level diff of a snapshot replay into DB checks
+// ready for syzygy_dbcheck_append. Rows are looked up by columns holding an
+// anchor value where possible, otherwise by primary key.
+func (s *SyzygyService) DbCheckSuggest(projectKey, unitID, runID, path string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	u, err := s.store.GetUnit(projectKey, unitID)
+	if err != nil {
+		return nil, err
+	}
+	run, err := findRun(u, runID)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = run.Artifacts["db_diff"]
+	}
+	if path == "" {
+		return nil, NewAppError("missing_artifact", "no db diff recorded; run syzygy_replay with snapshot=true first")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewAppError("missing_artifact", err.Error())
+	}
+	var art dbDiffArtifact
+	if err := json.Unmarshal(raw, &art); err != nil {
+		return nil, NewAppError("invalid_args", fmt.Sprintf("%s: %v", path, err))
+	}
+
+	anchorOf := map[string]string{}
+	for _, k := range sortedKeys(art.Anchors) {
+		// Short values such as 1 or "ok" would match unrelated columns.
+		if v := art.Anchors[k]; len(v) >= 3 && anchorOf[v] == "" {
+			anchorOf[v] = k
+		}
+	}
+	suggestions := []map[string]any{}
+	for _, t := range art.Tables {
+		if t.Error != "" {
+			continue
+		}
+		for _, row := range t.Inserted {
+			suggestions = append(suggestions, suggestDbCheck(t, "inserted", row, nil, anchorOf))
+		}
+		for _, ch := range t.Updated {
+			suggestions = append(suggestions, suggestDbCheck(t, "updated", ch.After, ch.Changed, anchorOf))
+		}
+		for _, row := range t.Deleted {
+			suggestions = append(suggestions, suggestDbCheck(t, "deleted", row, nil, anchorOf))
+		}
+	}
+	out := map[string]any{"unit_id": unitID, "run_id": run.RunID, "artifact": path, "count": len(suggestions)}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+		out["truncated"] = true
+	}
+	out["suggestions"] = suggestions
+	return out, nil
+}
+
+// suggestDbCheck proposes a check for one inserted, updated or deleted row.
+// columns limits the asserted columns (the changed ones of an update).
+func suggestDbCheck(t domain.TableDiff, kind string, row map[string]any, columns []string, anchorOf map[string]string) map[string]any {
+	var notes []string
+	where := []string{}
+	for _, c := range sortedKeys(row) {
+		if v, ok := row[c].(string); ok && anchorOf[v] != "" {
+			where = append(where, c)
+		}
+	}
+	switch {
+	case len(where) > 0:
+	case len(t.PrimaryKey) > 0:
+		where = t.PrimaryKey
+		notes = append(notes, "the row is matched by a primary key value of this replay; bind it to an anchor if it changes between replays")
+	default:
+		for _, c := range sortedKeys(row) {
+			if row[c] != nil {
+				where = append(where, c)
+			}
+		}
+		notes = append(notes, "the table has no primary key; the row is matched by all of its values")
+	}
+
+	params := map[string]any{}
+	conds := make([]string, len(where))
+	for i, c := range where {
+		conds[i] = c + " = :" + c
+		params[c] = suggestParam(row[c], anchorOf)
+	}
+	if columns == nil {
+		columns = sortedKeys(row)
+	}
+	selected := []string{}
+	for _, c := range columns {
+		if !slices.Contains(where, c) {
+			selected = append(selected, c)
+		}
+	}
+
+	check := map[string]any{
+		"name":   fmt.Sprintf("%s row %s", t.Table, kind),
+		"params": params,
+	}
+	if t.Datasource != "" {
+		check["dms"] = t.Datasource
+	}
+	if kind == "deleted" || len(selected) == 0 {
+		selected = where
+		check["row_count"] = 0
+		if kind != "deleted" {
+			check["row_count"] = 1
+		}
+	} else {
+		assert := map[string]any{}
+		for _, c := range selected {
+			assert[c] = suggestAssert(c, row[c], t.PrimaryKey, anchorOf)
+		}
+		check["assert"] = assert
+	}
+	check["sql"] = fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(selected, ", "), t.Table, strings.Join(conds, " AND "))
+
+	out := map[string]any{"kind": kind, "table": t.Table, "db_check": check}
+	if len(notes) > 0 {
+		out["notes"] = notes
+	}
+	return out
+}
+
+func suggestParam(v any, anchorOf map[string]string) string {
+	s, _ := v.(string)
+	if name := anchorOf[s]; name != "" {
+		return "${" + name + "}"
+	}
+	return s
+}
+
+// suggestAssert expects an anchor by reference, timestamps within the replay
+// window and generated keys to be set; other values are expected verbatim.
+func suggestAssert(column string, v any, pk []string, anchorOf map[string]string) any {
+	s, ok := v.(string)
+	switch {
+	case !ok:
+		return nil
+	case anchorOf[s] != "":
+		return "${" + anchorOf[s] + "}"
+	case strings.ContainsAny(s, "-:") && isDBTime(s):
+		return map[string]any{"op": domain.AssertWithin, "value": "10m"}
+	case slices.Contains(pk, column):
+		return domain.AssertNotNull
+	}
+	return s
+}
+
+func isDBTime(s string) bool {
+	_, err := domain.ParseDBTime(s, time.UTC)
+	return err == nil
+}
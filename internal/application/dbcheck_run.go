@@ -0,0 +1,62 @@
+package application
+
+import (
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// DbCheckRun runs one DB check of a run, or all of them when checkID is empty,
+// against the current database state without replaying any steps. ${var}
+// resolves from the run's variables and anchors and the unit env, and the
+// datasources and MYSQL_* env are those a replay would use.
+func (s *SyzygyService) DbCheckRun(projectKey, unitID, runID, checkID string, env map[string]any) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	cfg, err := s.EnsureProjectInitialized(projectKey)
+	if err != nil {
+		return nil, err
+	}
+	if s.executor == nil {
+		return nil, NewAppError("environment_error", "the go replay engine is not available")
+	}
+	u, err := s.store.GetUnit(projectKey, unitID)
+	if err != nil {
+		return nil, err
+	}
+	run, err := findRun(u, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := run.DBChecks
+	if checkID != "" {
+		i, err := findDbCheckIndex(run, checkID)
+		if err != nil {
+			return nil, err
+		}
+		checks = run.DBChecks[i : i+1]
+	}
+	spec := buildSpec(u, run)
+	if err := guardSQL(cfg.Datasources, &domain.Spec{UnitID: spec.UnitID, DBChecks: checks}); err != nil {
+		return nil, err
+	}
+	results := s.executor.RunDBChecks(spec, checks, domain.ReplayOptions{
+		Env:         envMap(replayEnv(cfg, u, env)),
+		Datasources: cfg.Datasources,
+		Since:       run.StartedAt,
+	})
+
+	ok, failed := true, 0
+	for _, r := range results {
+		if !r.OK {
+			ok = false
+			failed++
+		}
+	}
+	return map[string]any{
+		"ok":      ok,
+		"unit_id": unitID,
+		"run_id":  run.RunID,
+		"total":   len(results),
+		"failed":  failed,
+		"results": results,
+	}, nil
+}
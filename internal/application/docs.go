@@ -0,0 +1,520 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"path/filepath"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// Files written by the markdown and markdown_html templates. The index lives in
+// the artifacts root (or the explicit output directory) and links every unit.
+const (
+	docsIndexMarkdown = "index.md"
+	docsIndexHTML     = "index.html"
+)
+
+// docLayers is the order the step groups of a unit document are listed in.
+var docLayers = []struct{ Key, Title string }{
+	{"ui", "UI"},
+	{"net", "Net"},
+	{"db", "DB"},
+	{"util", "Util"},
+}
+
+// unitDoc is the human-readable view of a crystallized run shared by the
+// Markdown and HTML renderers.
+type unitDoc struct {
+	UnitID        string
+	RunID         string
+	Title         string
+	Status        string
+	Tags          []string
+	Prerequisites []string
+	Touchpoints   []docRow
+	Variables     []docRow
+	Isolation     string
+	Setup         []docStep
+	Layers        []docLayer
+	Teardown      []docStep
+	Checks        []docCheck
+	Replay        *docReplay
+}
+
+type docRow struct {
+	Key   string
+	Value string
+}
+
+type docLayer struct {
+	Title string
+	Steps []docStep
+}
+
+type docStep struct {
+	N      int
+	Name   string
+	Op     string
+	Params string
+	Expect []string
+}
+
+type docCheck struct {
+	Name    string
+	SQL     string
+	Params  string
+	Asserts []docRow
+}
+
+type docReplay struct {
+	OK        bool
+	At        string
+	Error     string
+	Artifacts []docRow
+}
+
+// docIndexEntry is one unit listed on the project index page.
+type docIndexEntry struct {
+	UnitID string
+	Title  string
+	RunID  string
+	Status string
+	Link   string
+}
+
+// renderDocs renders the unit document and the project index. withHTML adds
+// the HTML variants next to the Markdown files.
+func renderDocs(d *TemplateData, withHTML bool) ([]TemplateOutput, error) {
+	doc, err := buildUnitDoc(d)
+	if err != nil {
+		return nil, err
+	}
+	unitFile := d.Unit.UnitID + ".md"
+	outputs := []TemplateOutput{
+		{Artifact: "markdown", File: unitFile, Content: []byte(doc.markdown())},
+	}
+	entries := docIndex(d, "markdown", unitFile)
+	outputs = append(outputs, TemplateOutput{Artifact: "markdown_index", File: docsIndexMarkdown, Content: []byte(indexMarkdown(entries)), Root: true})
+	if !withHTML {
+		return outputs, nil
+	}
+
+	htmlFile := d.Unit.UnitID + ".html"
+	var buf bytes.Buffer
+	if err := unitDocHTML.Execute(&buf, doc); err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, TemplateOutput{Artifact: "html", File: htmlFile, Content: append([]byte{}, buf.Bytes()...)})
+	buf.Reset()
+	if err := indexHTML.Execute(&buf, docIndex(d, "html", htmlFile)); err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, TemplateOutput{Artifact: "html_index", File: docsIndexHTML, Content: buf.Bytes(), Root: true})
+	return outputs, nil
+}
+
+func buildUnitDoc(d *TemplateData) (*unitDoc, error) {
+	spec := d.Spec
+	doc := &unitDoc{
+		UnitID:        spec.UnitID,
+		RunID:         spec.RunID,
+		Title:         spec.Title,
+		Status:        d.Run.Status,
+		Tags:          toStringSliceAny(spec.Metadata["tags"]),
+		Prerequisites: spec.Prerequisites,
+		Isolation:     spec.Isolation,
+	}
+	if doc.Title == "" {
+		doc.Title = spec.UnitID
+	}
+	touch, _ := spec.Metadata["touchpoints"].(map[string]any)
+	for _, k := range sortedKeys(touch) {
+		if vals := toStringSliceAny(touch[k]); len(vals) > 0 {
+			doc.Touchpoints = append(doc.Touchpoints, docRow{Key: k, Value: strings.Join(vals, ", ")})
+		}
+	}
+	for _, k := range sortedKeys(spec.Variables) {
+		doc.Variables = append(doc.Variables, docRow{Key: k, Value: docValue(spec.Variables[k])})
+	}
+
+	for i, st := range spec.Setup {
+		step, _, err := docStepOf(i+1, st)
+		if err != nil {
+			return nil, fmt.Errorf("setup[%d]: %w", i, err)
+		}
+		doc.Setup = append(doc.Setup, step)
+	}
+	for i, st := range spec.Teardown {
+		step, _, err := docStepOf(i+1, st)
+		if err != nil {
+			return nil, fmt.Errorf("teardown[%d]: %w", i, err)
+		}
+		doc.Teardown = append(doc.Teardown, step)
+	}
+
+	groups := map[string][]docStep{}
+	for i, st := range spec.Steps {
+		step, layer, err := docStepOf(i+1, st)
+		if err != nil {
+			return nil, fmt.Errorf("steps[%d]: %w", i, err)
+		}
+		groups[layer] = append(groups[layer], step)
+	}
+	for _, l := range docLayers {
+		if len(groups[l.Key]) > 0 {
+			doc.Layers = append(doc.Layers, docLayer{Title: l.Title, Steps: groups[l.Key]})
+		}
+	}
+
+	for _, c := range spec.DBChecks {
+		check := docCheck{Name: c.Name, SQL: strings.TrimSpace(c.SQL)}
+		if check.Name == "" {
+			check.Name = c.CheckID
+		}
+		if len(c.Params) > 0 {
+			check.Params = docValue(c.Params)
+		}
+		for _, k := range sortedKeys(c.Assert) {
+			check.Asserts = append(check.Asserts, docRow{Key: k, Value: docValue(c.Assert[k])})
+		}
+		doc.Checks = append(doc.Checks, check)
+	}
+
+	doc.Replay = docReplayOf(d.Run, d.OutputDir)
+	return doc, nil
+}
+
+// docStepOf describes one step and returns the layer it is grouped under.
+// Steps that only carry net expectations are listed under Net.
+func docStepOf(n int, st *domain.ActionStep) (docStep, string, error) {
+	op, err := st.Op()
+	if err != nil {
+		return docStep{}, "", err
+	}
+	rules, err := st.NetRules()
+	if err != nil {
+		return docStep{}, "", err
+	}
+	step := docStep{N: n, Name: st.Name, Op: "net.must"}
+	layer := "net"
+	if op != nil {
+		step.Op = op.OpName()
+		layer, _, _ = strings.Cut(step.Op, ".")
+		params := map[string]any{}
+		for k, v := range docLayerMap(st, layer) {
+			if k != "op" && k != "must" {
+				params[k] = v
+			}
+		}
+		if len(params) > 0 {
+			step.Params = docValue(params)
+		}
+	}
+	for _, r := range rules {
+		step.Expect = append(step.Expect, "net.must "+describeNetRule(r))
+	}
+	if len(st.Expect) > 0 {
+		step.Expect = append(step.Expect, "expect "+docValue(st.Expect))
+	}
+	return step, layer, nil
+}
+
+func docLayerMap(st *domain.ActionStep, layer string) map[string]any {
+	switch layer {
+	case "ui":
+		return st.UI
+	case "db":
+		return st.DB
+	case "util":
+		return st.Util
+	case "net":
+		return st.Net
+	}
+	return nil
+}
+
+// docReplayOf reads the latest replay recorded on the run, with failure
+// artifacts linked relative to the document directory.
+func docReplayOf(run *domain.Run, docDir string) *docReplay {
+	result, ok := run.Meta["replay_result"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	r := &docReplay{}
+	r.OK, _ = result["ok"].(bool)
+	r.At, _ = run.Meta["replay_executed_at"].(string)
+	r.Error, _ = result["error"].(string)
+	files, ok := result["failure_artifacts"].([]string)
+	if !ok {
+		files = toStringSliceAny(result["failure_artifacts"])
+	}
+	for _, p := range files {
+		r.Artifacts = append(r.Artifacts, docRow{Key: filepath.Base(p), Value: docLink(docDir, p)})
+	}
+	return r
+}
+
+// docIndex lists every unit of the project with the status of its latest run.
+// Links point at the documents recorded under artifact, or at file for the
+// unit being crystallized.
+func docIndex(d *TemplateData, artifact, file string) []docIndexEntry {
+	out := []docIndexEntry{}
+	for _, u := range d.Units {
+		e := docIndexEntry{UnitID: u.UnitID, Title: u.Title}
+		var run *domain.Run
+		if u.UnitID == d.Unit.UnitID {
+			run = d.Run
+			e.Link = docLink(d.RootDir, filepath.Join(d.OutputDir, file))
+		} else if id := latestRunID(u); id != "" {
+			run, _ = findRun(u, id)
+		}
+		if run != nil {
+			e.RunID, e.Status = run.RunID, run.Status
+			if e.Link == "" && run.Artifacts[artifact] != "" {
+				e.Link = docLink(d.RootDir, run.Artifacts[artifact])
+			}
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// docLink makes target relative to dir when possible, using forward slashes.
+func docLink(dir, target string) string {
+	if rel, err := filepath.Rel(dir, target); err == nil {
+		target = rel
+	}
+	return filepath.ToSlash(target)
+}
+
+// docValue renders a parameter or assertion value on a single line.
+func docValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (doc *unitDoc) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", doc.Title)
+	fmt.Fprintf(&b, "- Unit: `%s`\n", doc.UnitID)
+	fmt.Fprintf(&b, "- Run: `%s` (%s)\n", doc.RunID, doc.Status)
+	if len(doc.Tags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(doc.Tags, ", "))
+	}
+	if len(doc.Prerequisites) > 0 {
+		fmt.Fprintf(&b, "- Prerequisites: %s\n", strings.Join(doc.Prerequisites, ", "))
+	}
+	if doc.Isolation != "" {
+		fmt.Fprintf(&b, "- Isolation: %s\n", doc.Isolation)
+	}
+
+	b.WriteString("\n## Latest replay\n\n")
+	switch {
+	case doc.Replay == nil:
+		b.WriteString("Not replayed yet.\n")
+	case doc.Replay.OK:
+		fmt.Fprintf(&b, "Passed at %s.\n", doc.Replay.At)
+	default:
+		fmt.Fprintf(&b, "Failed at %s: %s\n", doc.Replay.At, mdCell(doc.Replay.Error))
+		if len(doc.Replay.Artifacts) > 0 {
+			b.WriteString("\nFailure artifacts:\n\n")
+			for _, a := range doc.Replay.Artifacts {
+				fmt.Fprintf(&b, "- [%s](%s)\n", a.Key, a.Value)
+			}
+		}
+	}
+
+	if len(doc.Touchpoints) > 0 {
+		b.WriteString("\n## Touchpoints\n\n")
+		for _, t := range doc.Touchpoints {
+			fmt.Fprintf(&b, "- %s: %s\n", t.Key, t.Value)
+		}
+	}
+
+	if len(doc.Variables) > 0 {
+		b.WriteString("\n## Variables\n\n| Name | Value |\n| --- | --- |\n")
+		for _, v := range doc.Variables {
+			fmt.Fprintf(&b, "| `%s` | %s |\n", v.Key, mdCell(v.Value))
+		}
+	}
+
+	if len(doc.Setup) > 0 {
+		b.WriteString("\n## Setup\n\n")
+		mdSteps(&b, doc.Setup)
+	}
+
+	b.WriteString("\n## Steps\n")
+	for _, l := range doc.Layers {
+		fmt.Fprintf(&b, "\n### %s\n\n", l.Title)
+		mdSteps(&b, l.Steps)
+	}
+
+	if len(doc.Checks) > 0 {
+		b.WriteString("\n## DB checks\n")
+		for _, c := range doc.Checks {
+			fmt.Fprintf(&b, "\n### %s\n\n```sql\n%s\n```\n", c.Name, c.SQL)
+			if c.Params != "" {
+				fmt.Fprintf(&b, "\nParams: `%s`\n", c.Params)
+			}
+			if len(c.Asserts) > 0 {
+				b.WriteString("\n| Column | Expected |\n| --- | --- |\n")
+				for _, a := range c.Asserts {
+					fmt.Fprintf(&b, "| `%s` | %s |\n", a.Key, mdCell(a.Value))
+				}
+			}
+		}
+	}
+
+	if len(doc.Teardown) > 0 {
+		b.WriteString("\n## Teardown\n\n")
+		mdSteps(&b, doc.Teardown)
+	}
+	return b.String()
+}
+
+func mdSteps(b *strings.Builder, steps []docStep) {
+	for _, st := range steps {
+		fmt.Fprintf(b, "%d. ", st.N)
+		if st.Name != "" {
+			fmt.Fprintf(b, "%s — ", st.Name)
+		}
+		fmt.Fprintf(b, "`%s`", st.Op)
+		if st.Params != "" {
+			fmt.Fprintf(b, " `%s`", st.Params)
+		}
+		b.WriteString("\n")
+		for _, e := range st.Expect {
+			fmt.Fprintf(b, "   - %s\n", e)
+		}
+	}
+}
+
+func indexMarkdown(entries []docIndexEntry) string {
+	var b strings.Builder
+	b.WriteString("# Units\n\n| Unit | Title | Latest run | Status |\n| --- | --- | --- | --- |\n")
+	for _, e := range entries {
+		unit := "`" + e.UnitID + "`"
+		if e.Link != "" {
+			unit = fmt.Sprintf("[%s](%s)", e.UnitID, e.Link)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", unit, mdCell(e.Title), e.RunID, e.Status)
+	}
+	return b.String()
+}
+
+// mdCell keeps a value inside one Markdown table cell.
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+const docsCSS = `body{font-family:sans-serif;max-width:960px;margin:2em auto;padding:0 1em}
+table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px 8px;text-align:left}
+pre{background:#f6f8fa;padding:8px;overflow:auto}.passed{color:#1a7f37}.failed{color:#cf222e}`
+
+var unitDocHTML = htmltemplate.Must(htmltemplate.New("unit").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title><style>` + docsCSS + `</style></head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+<li>Unit: <code>{{.UnitID}}</code></li>
+<li>Run: <code>{{.RunID}}</code> ({{.Status}})</li>
+{{- if .Tags}}
+<li>Tags: {{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</li>
+{{- end}}
+{{- if .Prerequisites}}
+<li>Prerequisites: {{range $i, $p := .Prerequisites}}{{if $i}}, {{end}}{{$p}}{{end}}</li>
+{{- end}}
+{{- if .Isolation}}
+<li>Isolation: {{.Isolation}}</li>
+{{- end}}
+</ul>
+<h2>Latest replay</h2>
+{{- with .Replay}}
+{{- if .OK}}
+<p class="passed">Passed at {{.At}}.</p>
+{{- else}}
+<p class="failed">Failed at {{.At}}: {{.Error}}</p>
+{{- if .Artifacts}}
+<ul>{{range .Artifacts}}<li><a href="{{.Value}}">{{.Key}}</a></li>{{end}}</ul>
+{{- end}}
+{{- end}}
+{{- else}}
+<p>Not replayed yet.</p>
+{{- end}}
+{{- if .Touchpoints}}
+<h2>Touchpoints</h2>
+<ul>{{range .Touchpoints}}<li>{{.Key}}: {{.Value}}</li>{{end}}</ul>
+{{- end}}
+{{- if .Variables}}
+<h2>Variables</h2>
+<table><tr><th>Name</th><th>Value</th></tr>
+{{- range .Variables}}
+<tr><td><code>{{.Key}}</code></td><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Setup}}
+<h2>Setup</h2>
+{{- template "steps" .Setup}}
+{{- end}}
+<h2>Steps</h2>
+{{- range .Layers}}
+<h3>{{.Title}}</h3>
+{{- template "steps" .Steps}}
+{{- end}}
+{{- if .Checks}}
+<h2>DB checks</h2>
+{{- range .Checks}}
+<h3>{{.Name}}</h3>
+<pre><code>{{.SQL}}</code></pre>
+{{- if .Params}}
+<p>Params: <code>{{.Params}}</code></p>
+{{- end}}
+{{- if .Asserts}}
+<table><tr><th>Column</th><th>Expected</th></tr>
+{{- range .Asserts}}
+<tr><td><code>{{.Key}}</code></td><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .Teardown}}
+<h2>Teardown</h2>
+{{- template "steps" .Teardown}}
+{{- end}}
+</body></html>
+{{- define "steps"}}
+<ol>
+{{- range .}}
+<li value="{{.N}}">{{if .Name}}{{.Name}} — {{end}}<code>{{.Op}}</code>{{if .Params}} <code>{{.Params}}</code>{{end}}
+{{- if .Expect}}<ul>{{range .Expect}}<li>{{.}}</li>{{end}}</ul>{{end}}</li>
+{{- end}}
+</ol>
+{{- end}}
+`))
+
+var indexHTML = htmltemplate.Must(htmltemplate.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Units</title><style>` + docsCSS + `</style></head>
+<body>
+<h1>Units</h1>
+<table><tr><th>Unit</th><th>Title</th><th>Latest run</th><th>Status</th></tr>
+{{- range .}}
+<tr><td>{{if .Link}}<a href="{{.Link}}">{{.UnitID}}</a>{{else}}<code>{{.UnitID}}</code>{{end}}</td><td>{{.Title}}</td><td>{{.RunID}}</td><td>{{.Status}}</td></tr>
+{{- end}}
+</table>
+</body></html>
+`))
@@ -0,0 +1,86 @@
+package application
+
+import (
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// hasFixtures reports whether any spec has setup or teardown steps.
+func hasFixtures(specs []*domain.Spec) bool {
+	for _, sp := range specs {
+		if len(sp.Setup) > 0 || len(sp.Teardown) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// concatSteps joins step lists, e.g. setup, steps and teardown in run order.
+func concatSteps(lists ...[]*domain.ActionStep) []*domain.ActionStep {
+	out := []*domain.ActionStep{}
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	return out
+}
+
+// fixtureTitle names a setup or teardown step in generated tests.
+func fixtureTitle(st *domain.ActionStep, op domain.StepOp) string {
+	if st.Name != "" {
+		return st.Name
+	}
+	return op.OpName()
+}
+
+// replayCommandWithFixtures runs the setup steps of specs with the Go engine,
+// then the runner command unless a setup step failed, then reads the tables of
+// opts.Snapshot and runs the teardown steps of every spec whose setup ran, in
+// reverse order. Setup and teardown share one session: replay gets the anchors
+// after setup, and teardown also sees the anchors the runner reported. The
+// snapshot is nil without fixtures.
+func (s *SyzygyService) replayCommandWithFixtures(specs []*domain.Spec, opts domain.ReplayOptions, replay func(anchors map[string]string) (map[string]any, bool, error)) (map[string]any, bool, []*domain.TableSnapshot, error) {
+	if !hasFixtures(specs) {
+		result, failed, err := replay(nil)
+		return result, failed, nil, err
+	}
+	if s.executor == nil {
+		return nil, false, nil, NewAppError("environment_error", "setup and teardown steps need the go replay engine, which is not available")
+	}
+
+	var result map[string]any
+	var failed bool
+	var replayErr error
+	report := s.executor.RunFixtures(specs, opts, func(anchors map[string]string) map[string]string {
+		if result, failed, replayErr = replay(anchors); replayErr != nil {
+			return nil
+		}
+		return runnerReportAnchors(anyToString(result["output"]))
+	})
+	if replayErr != nil {
+		return nil, false, nil, replayErr
+	}
+	if !report.OK {
+		result = map[string]any{"ok": false, "engine": ReplayEngineNode, "error": report.Error, "setup": report.Setup}
+		failed = true
+	}
+	if len(report.Teardown) > 0 {
+		result["teardown"] = teardownResult(report.Teardown)
+	}
+	return result, failed, report.Snapshot, nil
+}
+
+func firstFailure(outcomes []domain.StepOutcome) string {
+	for _, o := range outcomes {
+		if !o.OK {
+			return strings.TrimSpace(o.Name + ": " + o.Error)
+		}
+	}
+	return ""
+}
+
+// teardownResult summarizes teardown steps for the replay result; a failed
+// teardown is reported but does not fail the replay.
+func teardownResult(outcomes []domain.StepOutcome) map[string]any {
+	return map[string]any{"ok": firstFailure(outcomes) == "", "steps": outcomes}
+}
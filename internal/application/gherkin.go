@@ -0,0 +1,744 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// The Gherkin dialect shared by the gherkin template and syzygy_gherkin_import.
+// Values after "is" are Go/JSON quoted strings or JSON literals; doc strings
+// carry JSON params (steps, response rules) or SQL (db checks).
+//
+//	@unit:<unit_id> @<tag>
+//	Feature: <title>
+//	  Scenario: <run>
+//	    Given prerequisite "<spec path>"     relative to the feature file
+//	    And env "<key>" is <value>
+//	    And variable "<key>" is <value>
+//	    And isolation "<mode>"
+//	    And setup "<step name>"           the step as a JSON doc string
+//	    When <step name> [<op>]          params as a JSON doc string
+//	    When <free text>                 skeleton step without op
+//	    Then response <METHOD> "<url contains>" returns <status>
+//	    And expect "<key>" is <value>
+//	    Then db check "<name>" on "<dms>"   SQL as a doc string
+//	    And param "<key>" is "<value>"
+//	    And column "<column>" is <value>
+//	    And rows "<first|all|any>"
+//	    And row count is <value>
+//	    And expect rows                    rows as a JSON doc string
+//	    And retries <n> times every <ms> ms
+//	    Then teardown "<step name>"       the step as a JSON doc string
+const gherkinUnitTag = "@unit:"
+
+var (
+	gherkinQuoted   = `"((?:[^"\\]|\\.)*)"`
+	reGherkinStep   = regexp.MustCompile(`^(.*?)\s*\[([a-z]+\.[a-z_]+)\]$`)
+	reGherkinKV     = regexp.MustCompile(`^(prerequisite|env|variable|isolation|setup|teardown|rows|expect|param|column)\s+` + gherkinQuoted + `(?:\s+is\s+(.+))?$`)
+	reGherkinNet    = regexp.MustCompile(`^response(?:\s+([A-Z]+))?\s+` + gherkinQuoted + `(?:\s+returns\s+(\d+))?$`)
+	reGherkinCheck  = regexp.MustCompile(`^db check\s+` + gherkinQuoted + `(?:\s+on\s+` + gherkinQuoted + `)?$`)
+	reGherkinCount  = regexp.MustCompile(`^row count is (.+)$`)
+	reGherkinRetry  = regexp.MustCompile(`^retries\s+(\d+)\s+times(?:\s+every\s+(\d+)\s*ms)?$`)
+	gherkinKeywords = []string{"Given", "When", "Then", "And", "But", "*"}
+	// keyOnlyGherkin are the dialect lines without "is <value>".
+	keyOnlyGherkin = map[string]bool{"prerequisite": true, "isolation": true, "setup": true, "teardown": true, "rows": true}
+)
+
+// renderGherkin writes a spec as a feature file in the dialect above.
+func renderGherkin(spec *domain.Spec) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated from %s", spec.UnitID)
+	if spec.RunID != "" {
+		fmt.Fprintf(&b, " (%s)", spec.RunID)
+	}
+	b.WriteString("; syzygy_gherkin_import reads it back.\n")
+	tags := []string{gherkinUnitTag + spec.UnitID}
+	for _, t := range toStringSliceAny(spec.Metadata["tags"]) {
+		tags = append(tags, "@"+strings.Join(strings.Fields(t), "_"))
+	}
+	b.WriteString(strings.Join(tags, " ") + "\n")
+	title := spec.Title
+	if title == "" {
+		title = spec.UnitID
+	}
+	fmt.Fprintf(&b, "Feature: %s\n", gherkinText(title))
+	touch, _ := spec.Metadata["touchpoints"].(map[string]any)
+	if len(touch) > 0 {
+		b.WriteString("\n  Touchpoints:\n")
+		for _, k := range sortedKeys(touch) {
+			fmt.Fprintf(&b, "    %s: %s\n", k, strings.Join(toStringSliceAny(touch[k]), ", "))
+		}
+	}
+	scenario := spec.RunID
+	if scenario == "" {
+		scenario = "recorded run"
+	}
+	fmt.Fprintf(&b, "\n  Scenario: %s\n", scenario)
+
+	w := &gherkinWriter{b: &b}
+	for _, p := range spec.Prerequisites {
+		w.step("Given", "prerequisite "+strconv.Quote(p))
+	}
+	for _, k := range sortedKeys(spec.Env) {
+		w.step("Given", fmt.Sprintf("env %s is %s", strconv.Quote(k), gherkinValue(spec.Env[k])))
+	}
+	for _, k := range sortedKeys(spec.Variables) {
+		w.step("Given", fmt.Sprintf("variable %s is %s", strconv.Quote(k), gherkinValue(spec.Variables[k])))
+	}
+	if spec.Isolation != "" {
+		w.step("Given", "isolation "+strconv.Quote(spec.Isolation))
+	}
+	for i, st := range spec.Setup {
+		if err := w.fixtureStep("Given", "setup", st); err != nil {
+			return "", fmt.Errorf("setup[%d]: %w", i, err)
+		}
+	}
+
+	for i, st := range spec.Steps {
+		if err := w.actionStep(st); err != nil {
+			return "", fmt.Errorf("steps[%d]: %w", i, err)
+		}
+	}
+
+	for _, c := range spec.DBChecks {
+		name := c.Name
+		if name == "" {
+			name = c.CheckID
+		}
+		line := "db check " + strconv.Quote(name)
+		if c.DMS != "" {
+			line += " on " + strconv.Quote(c.DMS)
+		}
+		w.step("Then", line)
+		w.docString("sql", strings.TrimSpace(c.SQL))
+		for _, k := range sortedKeys(c.Params) {
+			w.step("Then", fmt.Sprintf("param %s is %s", strconv.Quote(k), strconv.Quote(c.Params[k])))
+		}
+		for _, k := range sortedKeys(c.Assert) {
+			w.step("Then", fmt.Sprintf("column %s is %s", strconv.Quote(k), gherkinValue(c.Assert[k])))
+		}
+		if c.Rows != "" {
+			w.step("Then", "rows "+strconv.Quote(c.Rows))
+		}
+		if c.RowCount != nil {
+			w.step("Then", "row count is "+gherkinValue(c.RowCount))
+		}
+		if c.ExpectRows != nil {
+			w.step("Then", "expect rows")
+			if err := w.jsonDocString(c.ExpectRows); err != nil {
+				return "", err
+			}
+		}
+		if c.RetryAttempts > 0 {
+			line := fmt.Sprintf("retries %d times", c.RetryAttempts)
+			if c.RetryIntervalMS > 0 {
+				line += fmt.Sprintf(" every %d ms", c.RetryIntervalMS)
+			}
+			w.step("Then", line)
+		}
+	}
+
+	for i, st := range spec.Teardown {
+		if err := w.fixtureStep("Then", "teardown", st); err != nil {
+			return "", fmt.Errorf("teardown[%d]: %w", i, err)
+		}
+	}
+	return b.String(), nil
+}
+
+// gherkinWriter turns repeated keywords into "And", as Gherkin is usually written.
+type gherkinWriter struct {
+	b    *strings.Builder
+	last string
+}
+
+func (w *gherkinWriter) step(keyword, text string) {
+	kw := keyword
+	if keyword == w.last {
+		kw = "And"
+	}
+	w.last = keyword
+	fmt.Fprintf(w.b, "    %s %s\n", kw, text)
+}
+
+func (w *gherkinWriter) docString(mediaType, content string) {
+	fmt.Fprintf(w.b, "      \"\"\"%s\n", mediaType)
+	for _, l := range strings.Split(content, "\n") {
+		fmt.Fprintf(w.b, "      %s\n", l)
+	}
+	w.b.WriteString("      \"\"\"\n")
+}
+
+func (w *gherkinWriter) actionStep(st *domain.ActionStep) error {
+	op, err := st.Op()
+	if err != nil {
+		return err
+	}
+	if op != nil {
+		layer, _, _ := strings.Cut(op.OpName(), ".")
+		params := map[string]any{}
+		for k, v := range docLayerMap(st, layer) {
+			if k != "op" {
+				params[k] = v
+			}
+		}
+		text := "[" + op.OpName() + "]"
+		if name := gherkinText(st.Name); name != "" {
+			text = name + " " + text
+		}
+		w.step("When", text)
+		if len(params) > 0 {
+			if err := w.jsonDocString(params); err != nil {
+				return err
+			}
+		}
+	}
+	// Response rules of the net layer; net.call keeps its own in the params.
+	if _, isCall := op.(*domain.NetCall); !isCall {
+		rules, err := st.NetRules()
+		if err != nil {
+			return err
+		}
+		for _, r := range rules {
+			if err := w.netRule(r); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range sortedKeys(st.Expect) {
+		w.step("Then", fmt.Sprintf("expect %s is %s", strconv.Quote(k), gherkinValue(st.Expect[k])))
+	}
+	return nil
+}
+
+// fixtureStep writes a setup or teardown step whole, as a JSON doc string
+// without its name and id.
+func (w *gherkinWriter) fixtureStep(keyword, kind string, st *domain.ActionStep) error {
+	var body map[string]any
+	if err := deepCopyJSON(st, &body); err != nil {
+		return err
+	}
+	delete(body, "step_id")
+	delete(body, "name")
+	w.step(keyword, kind+" "+strconv.Quote(gherkinText(st.Name)))
+	return w.jsonDocString(body)
+}
+
+func (w *gherkinWriter) netRule(r domain.NetRule) error {
+	line := "response"
+	if r.Method != "" {
+		line += " " + strings.ToUpper(r.Method)
+	}
+	line += " " + strconv.Quote(r.URLContains)
+	if r.Status != "" {
+		line += " returns " + r.Status.String()
+	}
+	w.step("Then", line)
+
+	r.Method, r.URLContains, r.Status = "", "", ""
+	if r.ExpectJSON == nil && r.ExpectJSONPath == nil && r.Anchor == nil && r.CaptureAnchors == nil && r.Anchors == nil {
+		return nil
+	}
+	return w.jsonDocString(r)
+}
+
+func (w *gherkinWriter) jsonDocString(v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	w.docString("json", strings.TrimSuffix(buf.String(), "\n"))
+	return nil
+}
+
+// gherkinValue renders a value after "is": strings quoted, the rest as JSON.
+func gherkinValue(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return docValue(v)
+}
+
+// gherkinText keeps free text on one line and away from the op brackets.
+func gherkinText(s string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer("[", "(", "]", ")").Replace(s)), " ")
+}
+
+// gherkinFeature is a parsed feature file.
+type gherkinFeature struct {
+	Title string
+	Tags  []string
+	Steps []gherkinStep
+}
+
+type gherkinStep struct {
+	Line      int
+	Keyword   string
+	Text      string
+	DocString *string
+}
+
+// parseGherkin reads the structure of a single-scenario feature file: tags,
+// title and steps with their doc strings. Data tables and outlines are refused.
+func parseGherkin(src string) (*gherkinFeature, error) {
+	f := &gherkinFeature{}
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	tags := []string{}
+	inFeature, scenarios := false, 0
+	prevKeyword := ""
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "@"):
+			tags = append(tags, strings.Fields(line)...)
+			continue
+		case strings.HasPrefix(line, `"""`) || strings.HasPrefix(line, "```"):
+			if len(f.Steps) == 0 || f.Steps[len(f.Steps)-1].DocString != nil {
+				return nil, fmt.Errorf("line %d: doc string without a step", n)
+			}
+			delim := line[:3]
+			indent := strings.Index(lines[i], delim)
+			body := []string{}
+			closed := false
+			for i++; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == delim {
+					closed = true
+					break
+				}
+				l := lines[i]
+				if len(l)-len(strings.TrimLeft(l, " \t")) >= indent {
+					l = l[indent:]
+				} else {
+					l = strings.TrimLeft(l, " \t")
+				}
+				body = append(body, l)
+			}
+			if !closed {
+				return nil, fmt.Errorf("line %d: unterminated doc string", n)
+			}
+			doc := strings.Join(body, "\n")
+			f.Steps[len(f.Steps)-1].DocString = &doc
+			continue
+		case strings.HasPrefix(line, "|"):
+			return nil, fmt.Errorf("line %d: data tables are not supported; use one And line per value", n)
+		}
+
+		keyword, text, found := strings.Cut(line, ":")
+		switch strings.TrimSpace(keyword) {
+		case "Feature":
+			if found {
+				f.Title = strings.TrimSpace(text)
+				f.Tags = append(f.Tags, tags...)
+				tags = tags[:0]
+				inFeature = true
+				continue
+			}
+		case "Background", "Rule":
+			if found {
+				return nil, fmt.Errorf("line %d: %s is not supported", n, keyword)
+			}
+		case "Scenario Outline", "Scenario Template", "Examples":
+			if found {
+				return nil, fmt.Errorf("line %d: %s is not supported; write one Scenario per run", n, keyword)
+			}
+		case "Scenario", "Example":
+			if found {
+				if scenarios++; scenarios > 1 {
+					return nil, fmt.Errorf("line %d: only one Scenario per feature is supported", n)
+				}
+				f.Tags = append(f.Tags, tags...)
+				tags = tags[:0]
+				continue
+			}
+		}
+
+		kw, rest := gherkinKeyword(line)
+		if kw == "" {
+			if inFeature && scenarios == 0 {
+				continue // feature description
+			}
+			return nil, fmt.Errorf("line %d: expected a Given/When/Then step, got %q", n, line)
+		}
+		if scenarios == 0 {
+			return nil, fmt.Errorf("line %d: step outside of a Scenario", n)
+		}
+		if kw == "And" || kw == "But" || kw == "*" {
+			if prevKeyword == "" {
+				return nil, fmt.Errorf("line %d: %s without a preceding Given/When/Then", n, kw)
+			}
+			kw = prevKeyword
+		}
+		prevKeyword = kw
+		f.Steps = append(f.Steps, gherkinStep{Line: n, Keyword: kw, Text: rest})
+	}
+	if !inFeature {
+		return nil, fmt.Errorf("no Feature found")
+	}
+	return f, nil
+}
+
+func gherkinKeyword(line string) (string, string) {
+	for _, kw := range gherkinKeywords {
+		if rest, ok := strings.CutPrefix(line, kw+" "); ok {
+			return kw, strings.TrimSpace(rest)
+		}
+	}
+	return "", ""
+}
+
+// parseGherkinValue reads a value written after "is".
+func parseGherkinValue(s string) (any, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("value %s is neither a quoted string nor JSON", s)
+	}
+	return v, nil
+}
+
+func unquoteGherkin(s string) string {
+	v, err := strconv.Unquote(`"` + s + `"`)
+	if err != nil {
+		return s
+	}
+	return v
+}
+
+func parseGherkinObject(doc *string) (map[string]any, error) {
+	out := map[string]any{}
+	if doc == nil || strings.TrimSpace(*doc) == "" {
+		return out, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(*doc))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("doc string must be a JSON object: %v", err)
+	}
+	return out, nil
+}
+
+// gherkinRun is the run skeleton built from a feature.
+type gherkinRun struct {
+	UnitID        string
+	Title         string
+	Tags          []string
+	Prerequisites []string
+	Env           map[string]any
+	Run           *domain.Run
+	Skeleton      []string
+	Unmapped      []map[string]any
+}
+
+// buildGherkinRun maps the steps of a feature onto a run. Free-text When steps
+// become named steps without an op; other lines outside the dialect are
+// reported as unmapped.
+func buildGherkinRun(f *gherkinFeature) (*gherkinRun, error) {
+	g := &gherkinRun{
+		Title: f.Title,
+		Run:   &domain.Run{Variables: map[string]any{}, Steps: []*domain.ActionStep{}, DBChecks: []*domain.DbCheck{}},
+	}
+	for _, t := range f.Tags {
+		if id, ok := strings.CutPrefix(t, gherkinUnitTag); ok {
+			g.UnitID = id
+		} else {
+			g.Tags = append(g.Tags, strings.TrimPrefix(t, "@"))
+		}
+	}
+
+	var lastStep *domain.ActionStep
+	var lastCheck *domain.DbCheck
+	for _, st := range f.Steps {
+		fail := func(err error) error { return fmt.Errorf("line %d: %w", st.Line, err) }
+		unmapped := func() {
+			g.Unmapped = append(g.Unmapped, map[string]any{"line": st.Line, "text": st.Keyword + " " + st.Text})
+		}
+
+		if m := reGherkinKV.FindStringSubmatch(st.Text); m != nil {
+			key := unquoteGherkin(m[2])
+			var val any
+			if m[3] != "" {
+				v, err := parseGherkinValue(m[3])
+				if err != nil {
+					return nil, fail(err)
+				}
+				val = v
+			} else if !keyOnlyGherkin[m[1]] {
+				return nil, fail(fmt.Errorf("%s %q needs a value: is <value>", m[1], key))
+			}
+			switch {
+			case m[1] == "prerequisite" && st.Keyword == "Given":
+				g.Prerequisites = append(g.Prerequisites, key)
+			case m[1] == "isolation" && st.Keyword == "Given":
+				g.Run.Isolation = key
+			case m[1] == "setup" && st.Keyword == "Given", m[1] == "teardown" && st.Keyword == "Then":
+				body, err := parseGherkinObject(st.DocString)
+				if err != nil {
+					return nil, fail(err)
+				}
+				body["name"] = key
+				step, err := domain.ParseActionStep(body)
+				if err != nil {
+					return nil, fail(err)
+				}
+				if m[1] == "setup" {
+					g.Run.Setup = append(g.Run.Setup, &step)
+				} else {
+					g.Run.Teardown = append(g.Run.Teardown, &step)
+				}
+			case m[1] == "rows" && st.Keyword == "Then":
+				if lastCheck == nil {
+					return nil, fail(fmt.Errorf("rows without a preceding db check"))
+				}
+				lastCheck.Rows = key
+			case m[1] == "env" && st.Keyword == "Given":
+				if g.Env == nil {
+					g.Env = map[string]any{}
+				}
+				g.Env[key] = val
+			case m[1] == "variable" && st.Keyword == "Given":
+				g.Run.Variables[key] = val
+			case m[1] == "expect" && st.Keyword == "Then":
+				if lastStep == nil {
+					return nil, fail(fmt.Errorf("expect without a preceding When step"))
+				}
+				if lastStep.Expect == nil {
+					lastStep.Expect = map[string]any{}
+				}
+				lastStep.Expect[key] = val
+			case m[1] == "param" && st.Keyword == "Then":
+				if lastCheck == nil {
+					return nil, fail(fmt.Errorf("param without a preceding db check"))
+				}
+				lastCheck.Params[key] = anyToString(val)
+			case m[1] == "column" && st.Keyword == "Then":
+				if lastCheck == nil {
+					return nil, fail(fmt.Errorf("column without a preceding db check"))
+				}
+				lastCheck.Assert[key] = val
+			default:
+				unmapped()
+			}
+			continue
+		}
+
+		switch st.Keyword {
+		case "When":
+			step := map[string]any{"name": st.Text}
+			if m := reGherkinStep.FindStringSubmatch(st.Text); m != nil {
+				params, err := parseGherkinObject(st.DocString)
+				if err != nil {
+					return nil, fail(err)
+				}
+				op := m[2]
+				layer, _, _ := strings.Cut(op, ".")
+				if op != "net.must" {
+					params["op"] = op
+				}
+				step = map[string]any{"name": m[1], layer: params}
+			} else {
+				g.Skeleton = append(g.Skeleton, st.Text)
+			}
+			parsed, err := domain.ParseActionStep(step)
+			if err != nil {
+				return nil, fail(err)
+			}
+			lastStep = &parsed
+			g.Run.Steps = append(g.Run.Steps, lastStep)
+
+		case "Then":
+			if m := reGherkinNet.FindStringSubmatch(st.Text); m != nil {
+				rule, err := parseGherkinObject(st.DocString)
+				if err != nil {
+					return nil, fail(err)
+				}
+				if m[1] != "" {
+					rule["method"] = m[1]
+				}
+				if url := unquoteGherkin(m[2]); url != "" {
+					rule["url_contains"] = url
+				}
+				if m[3] != "" {
+					rule["status"] = json.Number(m[3])
+				}
+				// Rules go to the net layer of the previous step unless it
+				// already runs a net op.
+				if lastStep == nil || (lastStep.Net != nil && lastStep.Net["op"] != nil) {
+					lastStep = &domain.ActionStep{}
+					g.Run.Steps = append(g.Run.Steps, lastStep)
+				}
+				if lastStep.Net == nil {
+					lastStep.Net = map[string]any{}
+				}
+				must, _ := lastStep.Net["must"].([]any)
+				lastStep.Net["must"] = append(must, rule)
+				if err := lastStep.Validate(); err != nil {
+					return nil, fail(err)
+				}
+				continue
+			}
+			if m := reGherkinCheck.FindStringSubmatch(st.Text); m != nil {
+				if st.DocString == nil || strings.TrimSpace(*st.DocString) == "" {
+					return nil, fail(fmt.Errorf("db check needs its SQL as a doc string"))
+				}
+				lastCheck = &domain.DbCheck{
+					Name:   unquoteGherkin(m[1]),
+					DMS:    unquoteGherkin(m[2]),
+					SQL:    strings.TrimSpace(*st.DocString),
+					Params: map[string]string{},
+					Assert: map[string]any{},
+				}
+				g.Run.DBChecks = append(g.Run.DBChecks, lastCheck)
+				continue
+			}
+			if m := reGherkinCount.FindStringSubmatch(st.Text); m != nil {
+				if lastCheck == nil {
+					return nil, fail(fmt.Errorf("row count without a preceding db check"))
+				}
+				v, err := parseGherkinValue(m[1])
+				if err != nil {
+					return nil, fail(err)
+				}
+				lastCheck.RowCount = v
+				continue
+			}
+			if st.Text == "expect rows" {
+				if lastCheck == nil {
+					return nil, fail(fmt.Errorf("expect rows without a preceding db check"))
+				}
+				if st.DocString == nil {
+					return nil, fail(fmt.Errorf("expect rows needs the rows as a JSON doc string"))
+				}
+				dec := json.NewDecoder(strings.NewReader(*st.DocString))
+				dec.UseNumber()
+				rows := []map[string]any{}
+				if err := dec.Decode(&rows); err != nil {
+					return nil, fail(fmt.Errorf("expect rows doc string must be a JSON array of objects: %v", err))
+				}
+				lastCheck.ExpectRows = rows
+				continue
+			}
+			if m := reGherkinRetry.FindStringSubmatch(st.Text); m != nil {
+				if lastCheck == nil {
+					return nil, fail(fmt.Errorf("retries without a preceding db check"))
+				}
+				lastCheck.RetryAttempts, _ = strconv.Atoi(m[1])
+				lastCheck.RetryIntervalMS, _ = strconv.Atoi(m[2])
+				continue
+			}
+			unmapped()
+
+		default:
+			unmapped()
+		}
+	}
+	return g, nil
+}
+
+// GherkinImport turns a feature file (path or content) written in the gherkin
+// template's dialect into a new run of its unit.
+func (s *SyzygyService) GherkinImport(projectKey string, path string, content string, unitID string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	if _, err := s.EnsureProjectInitialized(projectKey); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(content) == "" {
+		if strings.TrimSpace(path) == "" {
+			return nil, NewAppError("invalid_args", "path or content is required")
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		content = string(bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")))
+	}
+
+	f, err := parseGherkin(content)
+	if err != nil {
+		return nil, NewAppError("invalid_gherkin", err.Error())
+	}
+	g, err := buildGherkinRun(f)
+	if err != nil {
+		return nil, NewAppError("invalid_gherkin", err.Error())
+	}
+	if strings.TrimSpace(unitID) != "" {
+		g.UnitID = strings.TrimSpace(unitID)
+	}
+	if g.UnitID == "" {
+		return nil, NewAppError("invalid_args", "unit_id is required (or tag the Feature with "+gherkinUnitTag+"<unit_id>)")
+	}
+	if err := domain.ValidateFixtures(g.Run.Setup, g.Run.Steps, g.Run.Teardown, g.Run.Isolation); err != nil {
+		return nil, NewAppError("invalid_gherkin", err.Error())
+	}
+
+	u, err := s.store.GetOrCreateUnit(projectKey, g.UnitID, g.Title, g.Env)
+	if err != nil {
+		return nil, err
+	}
+	if u.Meta == nil {
+		u.Meta = map[string]any{}
+	}
+	if len(g.Prerequisites) > 0 {
+		// Prerequisites are relative to the feature file; content without a
+		// path has nothing to resolve them from.
+		if strings.TrimSpace(path) == "" {
+			return nil, NewAppError("invalid_args", "path is required to resolve the prerequisites of a feature")
+		}
+		dir, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		u.Meta[metaPrerequisites] = g.Prerequisites
+		u.Meta[metaPrerequisiteDir] = dir
+	}
+	if len(g.Tags) > 0 && u.Meta["tags"] == nil {
+		u.Meta["tags"] = g.Tags
+	}
+
+	run, err := forkRun(g.Run)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		run.Meta["imported_from"] = path
+	}
+	u.Runs = append(u.Runs, run)
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveUnit(projectKey, u); err != nil {
+		return nil, err
+	}
+
+	result := map[string]any{
+		"unit_id":   u.UnitID,
+		"run_id":    run.RunID,
+		"steps":     len(run.Steps),
+		"db_checks": len(run.DBChecks),
+	}
+	if len(g.Skeleton) > 0 {
+		// Named steps without an op; fill them in with syzygy_step_update.
+		result["skeleton_steps"] = g.Skeleton
+	}
+	if len(g.Unmapped) > 0 {
+		result["unmapped"] = g.Unmapped
+	}
+	return result, nil
+}
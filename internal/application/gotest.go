@@ -0,0 +1,763 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// goTestPackage is the package of generated Go tests; the files only contain
+// tests, so they can live in any directory of the backend module.
+const goTestPackage = "e2e"
+
+// goTestHelpersFile is shared by every generated unit test of a directory.
+const goTestHelpersFile = "syzygy_helpers_test.go"
+
+// renderGoTest generates a go test for a spec and its prerequisites. Only
+// db.*, net.call and util.* steps can run without a browser; net.must rules
+// need page traffic and are rejected like ui.* steps, extended DB checks like
+// in the Node runner. Setup steps run before the steps of their spec and
+// teardown steps as test cleanups; rollback isolation is rejected, and so are
+// DB steps and checks a MYSQL_* connection cannot reach.
+func renderGoTest(spec *domain.Spec, prerequisites []*domain.Spec, datasources map[string]domain.Datasource) ([]TemplateOutput, error) {
+	specs := append(append([]*domain.Spec{}, prerequisites...), spec)
+	usesDB := false
+	for _, sp := range specs {
+		if sp.Isolation != "" {
+			return nil, fmt.Errorf("%s: isolation %s is not supported by go_test; replay it with syzygy_replay", sp.UnitID, sp.Isolation)
+		}
+		for _, list := range []struct {
+			name  string
+			steps []*domain.ActionStep
+		}{{"setup", sp.Setup}, {"steps", sp.Steps}, {"teardown", sp.Teardown}} {
+			for i, st := range list.steps {
+				ops, err := st.Ops()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s[%d]: %w", sp.UnitID, list.name, i, err)
+				}
+				for _, op := range ops {
+					switch v := op.(type) {
+					case *domain.DBExec:
+						usesDB = true
+					case *domain.NetCall:
+						if len(v.Must) > 0 {
+							return nil, fmt.Errorf("%s: %s[%d]: net.call must rules need a browser; use playwright_ts", sp.UnitID, list.name, i)
+						}
+					case *domain.UtilGenID, *domain.UtilGenTS:
+					default:
+						return nil, fmt.Errorf("%s: %s[%d]: %s is not supported by go_test (db.*, net.call and util.* only)", sp.UnitID, list.name, i, op.OpName())
+					}
+				}
+			}
+		}
+		if len(sp.DBChecks) > 0 {
+			usesDB = true
+		}
+	}
+	if c := domain.HasExtendedDbChecks(specs...); c != nil {
+		return nil, fmt.Errorf("db check %s: rows, row_count, expect_rows and object asserts are not supported by go_test; replay them with syzygy_replay", c.Name)
+	}
+	if err := generatedDatasources("go_test", datasources, specs); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by syzygy-mcp from unit %s (run %s). DO NOT EDIT.\n\n", spec.UnitID, spec.RunID)
+	fmt.Fprintf(&b, "package %s\n\n", goTestPackage)
+	b.WriteString("import (\n\t\"testing\"\n")
+	if usesDB {
+		b.WriteString("\n\t_ \"github.com/go-sql-driver/mysql\"\n")
+	}
+	b.WriteString(")\n\n")
+
+	title := spec.UnitID
+	if spec.Title != "" {
+		title += ": " + spec.Title
+	}
+	fmt.Fprintf(&b, "// %s\n", title)
+	fmt.Fprintf(&b, "func Test%s(t *testing.T) {\n", exportedIdent(spec.UnitID))
+	fmt.Fprintf(&b, "r := newSyzygyRun(t, %s)\n", goLiteral(orEmptyStringMap(spec.Anchors)))
+	for _, sp := range specs {
+		label := sp.UnitID
+		if sp != spec {
+			label = "prerequisite " + sp.UnitID
+		}
+		fmt.Fprintf(&b, "\n// %s\n", label)
+		fmt.Fprintf(&b, "r.Use(%s, %s)\n", goLiteral(orEmptyMap(sp.Variables)), goLiteral(orEmptyMap(sp.Env)))
+		// Cleanups run last registered first: register teardown in reverse.
+		for i := len(sp.Teardown) - 1; i >= 0; i-- {
+			st := sp.Teardown[i]
+			op, _ := st.Op()
+			if op == nil {
+				continue
+			}
+			fmt.Fprintf(&b, "r.Teardown(%s, func() {\n%s})\n", strconv.Quote(fixtureTitle(st, op)), goTestStep(op))
+		}
+		for _, st := range sp.Setup {
+			op, _ := st.Op()
+			if op == nil {
+				continue
+			}
+			fmt.Fprintf(&b, "r.Step(%s)\n", strconv.Quote("setup: "+fixtureTitle(st, op)))
+			b.WriteString(goTestStep(op))
+		}
+		for _, st := range sp.Steps {
+			op, _ := st.Op()
+			if op == nil {
+				continue
+			}
+			name := st.Name
+			if name == "" {
+				name = op.OpName()
+			}
+			fmt.Fprintf(&b, "r.Step(%s)\n", strconv.Quote(name))
+			b.WriteString(goTestStep(op))
+		}
+		for _, c := range sp.DBChecks {
+			fmt.Fprintf(&b, "r.Check(%s)\n", goDbCheck(c))
+		}
+	}
+	b.WriteString("}\n")
+
+	unitFile, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not parse: %w", err)
+	}
+	helpers, err := format.Source([]byte(strings.Replace(goTestHelpers, "package e2e", "package "+goTestPackage, 1)))
+	if err != nil {
+		return nil, err
+	}
+	return []TemplateOutput{
+		{Artifact: "go_test", File: goTestFileName(spec.UnitID), Content: unitFile},
+		{Artifact: "go_test_helpers", File: goTestHelpersFile, Content: helpers},
+	}, nil
+}
+
+func goTestStep(op domain.StepOp) string {
+	switch v := op.(type) {
+	case *domain.UtilGenID:
+		return fmt.Sprintf("r.GenID(%s)\n", strconv.Quote(v.Key))
+	case *domain.UtilGenTS:
+		return fmt.Sprintf("r.GenTS(%s)\n", strconv.Quote(v.Key))
+	case *domain.DBExec:
+		return fmt.Sprintf("r.Exec(%s, %s)\n", strconv.Quote(v.SQL), goLiteral(orEmptyStringMap(v.Params)))
+	case *domain.NetCall:
+		fields := []string{}
+		if v.Method != "" {
+			fields = append(fields, "Method: "+strconv.Quote(v.Method))
+		}
+		fields = append(fields, "URL: "+strconv.Quote(v.URL))
+		if len(v.Headers) > 0 {
+			fields = append(fields, "Headers: "+goLiteral(v.Headers))
+		}
+		if v.JSON != nil {
+			fields = append(fields, "JSON: "+goLiteral(v.JSON))
+		}
+		if len(v.Form) > 0 {
+			fields = append(fields, "Form: "+goLiteral(v.Form))
+		}
+		if v.Status != "" {
+			fields = append(fields, "Status: "+v.Status.String())
+		}
+		if len(v.ExpectJSON) > 0 {
+			fields = append(fields, "ExpectJSON: "+goLiteral(stringifyValues(v.ExpectJSON)))
+		}
+		if len(v.ExpectJSONPath) > 0 {
+			fields = append(fields, "ExpectJSONPath: "+goLiteral(stringifyValues(v.ExpectJSONPath)))
+		}
+		if len(v.ExpectPresent) > 0 {
+			fields = append(fields, "ExpectPresent: "+goLiteral(v.ExpectPresent))
+		}
+		if v.Anchor != nil {
+			fields = append(fields, fmt.Sprintf("Anchor: &syzygyAnchor{Key: %s, JSONPath: %s}", strconv.Quote(v.Anchor.Key), strconv.Quote(v.Anchor.JSONPath)))
+		}
+		if v.Require != nil {
+			fields = append(fields, fmt.Sprintf("Require: &syzygyRequire{Message: %s, NeedUnit: %s}", strconv.Quote(v.Require.Message), strconv.Quote(v.Require.NeedUnit)))
+		}
+		return "r.Call(syzygyCall{\n" + strings.Join(fields, ",\n") + ",\n})\n"
+	}
+	return ""
+}
+
+func goDbCheck(c *domain.DbCheck) string {
+	fields := []string{
+		"Name: " + strconv.Quote(c.Name),
+		"SQL: " + strconv.Quote(c.SQL),
+	}
+	if len(c.Params) > 0 {
+		fields = append(fields, "Params: "+goLiteral(c.Params))
+	}
+	if len(c.Assert) > 0 {
+		fields = append(fields, "Assert: "+goLiteral(stringifyValues(c.Assert)))
+	}
+	if c.RetryAttempts > 0 {
+		fields = append(fields, "RetryAttempts: "+strconv.Itoa(c.RetryAttempts))
+	}
+	if c.RetryIntervalMS > 0 {
+		fields = append(fields, "RetryIntervalMS: "+strconv.Itoa(c.RetryIntervalMS))
+	}
+	return "syzygyCheck{\n" + strings.Join(fields, ",\n") + ",\n}"
+}
+
+// stringifyValues converts expected values to the strings the runner compares.
+func stringifyValues(m map[string]any) map[string]string {
+	out := map[string]string{}
+	for k, v := range m {
+		out[k] = anyToString(v)
+	}
+	return out
+}
+
+// goLiteral renders JSON-shaped values as Go composite literals.
+func goLiteral(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(t)
+	case map[string]string:
+		keys := sortedKeys(t)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, strconv.Quote(k)+": "+strconv.Quote(t[k]))
+		}
+		return "map[string]string{" + strings.Join(parts, ", ") + "}"
+	case []string:
+		parts := make([]string, 0, len(t))
+		for _, it := range t {
+			parts = append(parts, strconv.Quote(it))
+		}
+		return "[]string{" + strings.Join(parts, ", ") + "}"
+	case map[string]any:
+		keys := sortedKeys(t)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, strconv.Quote(k)+": "+goLiteral(t[k]))
+		}
+		return "map[string]any{" + strings.Join(parts, ", ") + "}"
+	case []any:
+		parts := make([]string, 0, len(t))
+		for _, it := range t {
+			parts = append(parts, goLiteral(it))
+		}
+		return "[]any{" + strings.Join(parts, ", ") + "}"
+	default:
+		return strconv.Quote(fmt.Sprintf("%v", t))
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// goTestFileName turns hazard.create.v2 into hazard_create_v2_test.go.
+func goTestFileName(unitID string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(unitID) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return strings.Trim(sb.String(), "_") + "_test.go"
+}
+
+const goTestHelpers = `// Code generated by syzygy-mcp. DO NOT EDIT.
+
+package e2e
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Same placeholder and named parameter syntax as syzygy-runner.
+var (
+	syzygyPlaceholder = regexp.MustCompile("\\$\\{([^}]+)\\}")
+	syzygyNamedParam  = regexp.MustCompile(":([a-zA-Z_][a-zA-Z0-9_]*)")
+)
+
+type syzygyAnchor struct {
+	Key      string
+	JSONPath string
+}
+
+type syzygyRequire struct {
+	Message  string
+	NeedUnit string
+}
+
+type syzygyCall struct {
+	Method         string
+	URL            string
+	Headers        map[string]any
+	JSON           any
+	Form           map[string]any
+	Status         int
+	ExpectJSON     map[string]string
+	ExpectJSONPath map[string]string
+	ExpectPresent  []string
+	Anchor         *syzygyAnchor
+	Require        *syzygyRequire
+}
+
+type syzygyCheck struct {
+	Name            string
+	SQL             string
+	Params          map[string]string
+	Assert          map[string]string
+	RetryAttempts   int
+	RetryIntervalMS int
+}
+
+// syzygyRun holds the context of a unit: variables and env of the current
+// spec plus anchors shared with its prerequisites.
+type syzygyRun struct {
+	t       *testing.T
+	vars    map[string]any
+	env     map[string]any
+	anchors map[string]string
+	client  *http.Client
+	db      *sql.DB
+}
+
+func newSyzygyRun(t *testing.T, anchors map[string]string) *syzygyRun {
+	jar, _ := cookiejar.New(nil)
+	r := &syzygyRun{t: t, anchors: anchors, client: &http.Client{Jar: jar, Timeout: 30 * time.Second}}
+	t.Cleanup(func() {
+		if r.db != nil {
+			r.db.Close()
+		}
+	})
+	return r
+}
+
+// Use switches to the variables and env of the next spec.
+func (r *syzygyRun) Use(vars, env map[string]any) {
+	r.vars, r.env = vars, env
+}
+
+func (r *syzygyRun) Step(name string) {
+	r.t.Helper()
+	r.t.Logf("step: %s", name)
+}
+
+// Teardown registers a teardown step of the spec in use. Cleanups run last
+// registered first, each one even when an earlier one failed.
+func (r *syzygyRun) Teardown(name string, step func()) {
+	vars, env := r.vars, r.env
+	r.t.Cleanup(func() {
+		r.Use(vars, env)
+		r.Step("teardown: " + name)
+		step()
+	})
+}
+
+func (r *syzygyRun) ctx() map[string]string {
+	out := map[string]string{}
+	for _, m := range []map[string]any{r.vars, r.env} {
+		for k, v := range m {
+			out[k] = fmt.Sprint(v)
+		}
+	}
+	for k, v := range r.anchors {
+		out[k] = v
+	}
+	return out
+}
+
+func (r *syzygyRun) sub(s string) string {
+	ctx := r.ctx()
+	return syzygyPlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+		return ctx[m[2:len(m)-1]]
+	})
+}
+
+func (r *syzygyRun) deepSub(v any) any {
+	switch t := v.(type) {
+	case string:
+		return r.sub(t)
+	case map[string]any:
+		out := map[string]any{}
+		for k, it := range t {
+			out[k] = r.deepSub(it)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, it := range t {
+			out[i] = r.deepSub(it)
+		}
+		return out
+	}
+	return v
+}
+
+func (r *syzygyRun) GenID(key string) {
+	r.anchors[key] = strconv.FormatInt(time.Now().UnixMilli()*1000+rand.Int63n(1000), 10)
+}
+
+func (r *syzygyRun) GenTS(key string) {
+	r.anchors[key] = time.Now().UTC().Format("2006-01-02-15-04-05")
+}
+
+// absURL resolves /path against api_origin or the origin of base_url.
+func (r *syzygyRun) absURL(raw string) string {
+	if !strings.HasPrefix(raw, "/") {
+		return raw
+	}
+	ctx := r.ctx()
+	for _, k := range []string{"api_origin", "API_ORIGIN"} {
+		if o := ctx[k]; strings.HasPrefix(o, "http://") || strings.HasPrefix(o, "https://") {
+			return strings.TrimSuffix(o, "/") + raw
+		}
+	}
+	for _, k := range []string{"base_url", "BASE_URL"} {
+		if u, err := url.Parse(ctx[k]); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return u.Scheme + "://" + u.Host + raw
+		}
+	}
+	return raw
+}
+
+// Call performs a net.call step. Without an Authorization header the
+// SYZYGY_AUTH_TOKEN environment variable is sent as a bearer token.
+func (r *syzygyRun) Call(c syzygyCall) {
+	r.t.Helper()
+	method := strings.ToUpper(c.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	target := r.absURL(r.sub(c.URL))
+	fail := func(format string, args ...any) {
+		r.t.Helper()
+		msg := fmt.Sprintf(format, args...)
+		if c.Require != nil {
+			r.t.Fatalf("Requirement failed: %s need_unit=%s. url=%s err=%s", r.sub(c.Require.Message), c.Require.NeedUnit, target, msg)
+		}
+		r.t.Fatalf("net.call failed: url=%s err=%s", target, msg)
+	}
+
+	var body io.Reader
+	header := http.Header{}
+	if c.JSON != nil {
+		b, err := json.Marshal(r.deepSub(c.JSON))
+		if err != nil {
+			fail("%v", err)
+		}
+		body = bytes.NewReader(b)
+		header.Set("Content-Type", "application/json")
+	} else if c.Form != nil {
+		form := url.Values{}
+		for k, v := range c.Form {
+			form.Set(k, r.sub(fmt.Sprint(v)))
+		}
+		body = strings.NewReader(form.Encode())
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range c.Headers {
+		header.Set(k, r.sub(fmt.Sprint(v)))
+	}
+	if header.Get("Authorization") == "" {
+		if token := os.Getenv("SYZYGY_AUTH_TOKEN"); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		fail("%v", err)
+	}
+	req.Header = header
+	res, err := r.client.Do(req)
+	if err != nil {
+		fail("%v", err)
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		fail("%v", err)
+	}
+	if c.Status != 0 && res.StatusCode != c.Status {
+		fail("status mismatch expected=%d actual=%d", c.Status, res.StatusCode)
+	}
+
+	var doc any
+	if strings.Contains(res.Header.Get("Content-Type"), "application/json") {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		_ = dec.Decode(&doc)
+	}
+	for _, k := range syzygySortedKeys(c.ExpectJSON) {
+		obj, _ := doc.(map[string]any)
+		v, ok := obj[k]
+		if want, got := r.sub(c.ExpectJSON[k]), syzygyString(v, ok); want != got {
+			fail("expect_json mismatch key=%s expected=%s actual=%s", k, want, got)
+		}
+	}
+	for _, jp := range syzygySortedKeys(c.ExpectJSONPath) {
+		v, ok := syzygyJSONPath(doc, jp)
+		if want, got := r.sub(c.ExpectJSONPath[jp]), syzygyString(v, ok); want != got {
+			fail("expect_jsonpath mismatch path=%s expected=%s actual=%s", jp, want, got)
+		}
+	}
+	for _, jp := range c.ExpectPresent {
+		if v, ok := syzygyJSONPath(doc, jp); !ok || v == nil {
+			fail("expect_present missing path=%s", jp)
+		}
+	}
+	if c.Anchor != nil {
+		v, ok := syzygyJSONPath(doc, c.Anchor.JSONPath)
+		if !ok || v == nil {
+			fail("anchor jsonpath not found: %s", c.Anchor.JSONPath)
+		}
+		r.anchors[c.Anchor.Key] = syzygyString(v, true)
+	}
+}
+
+// conn opens the MySQL database from MYSQL_* in the spec env or the process env.
+func (r *syzygyRun) conn() *sql.DB {
+	r.t.Helper()
+	if r.db != nil {
+		return r.db
+	}
+	ctx := r.ctx()
+	get := func(k string) string {
+		if v := ctx[k]; v != "" {
+			return v
+		}
+		if v := ctx[strings.ToLower(k)]; v != "" {
+			return v
+		}
+		return os.Getenv(k)
+	}
+	host, user, database := get("MYSQL_HOST"), get("MYSQL_USER"), get("MYSQL_DATABASE")
+	if host == "" || user == "" || database == "" {
+		r.t.Fatal("Missing MySQL env. Required: MYSQL_HOST, MYSQL_USER, MYSQL_DATABASE (and MYSQL_PASSWORD if needed)")
+	}
+	port := get("MYSQL_PORT")
+	if port == "" {
+		port = "3306"
+	}
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4", user, get("MYSQL_PASSWORD"), host, port, database))
+	if err != nil {
+		r.t.Fatalf("open mysql: %v", err)
+	}
+	r.db = db
+	return db
+}
+
+// positional rewrites :name parameters to ? placeholders.
+func (r *syzygyRun) positional(query string, params map[string]string) (string, []any) {
+	args := []any{}
+	out := syzygyNamedParam.ReplaceAllStringFunc(query, func(m string) string {
+		args = append(args, r.sub(params[m[1:]]))
+		return "?"
+	})
+	return out, args
+}
+
+func (r *syzygyRun) Exec(query string, params map[string]string) {
+	r.t.Helper()
+	q, args := r.positional(query, params)
+	if _, err := r.conn().Exec(q, args...); err != nil {
+		r.t.Fatalf("db.exec failed: sql=%s err=%v", q, err)
+	}
+}
+
+// Check runs a DB check with retries; assertions apply to the first row.
+func (r *syzygyRun) Check(c syzygyCheck) {
+	r.t.Helper()
+	attempts := c.RetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	interval := time.Duration(c.RetryIntervalMS) * time.Millisecond
+	if interval == 0 {
+		interval = 500 * time.Millisecond
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = r.check(c); err == nil {
+			return
+		}
+		if i < attempts-1 {
+			time.Sleep(interval)
+		}
+	}
+	r.t.Fatalf("DB check failed: %s - %v", c.Name, err)
+}
+
+func (r *syzygyRun) check(c syzygyCheck) error {
+	q, args := r.positional(c.SQL, c.Params)
+	rows, err := r.conn().Query(q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return errors.New("no rows returned")
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vals := make([]sql.NullString, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return err
+	}
+	row := map[string]sql.NullString{}
+	for i, col := range cols {
+		row[col] = vals[i]
+	}
+
+	for _, field := range syzygySortedKeys(c.Assert) {
+		want := r.sub(c.Assert[field])
+		got, ok := row[field]
+		switch want {
+		case "not_null":
+			if !ok || !got.Valid {
+				return fmt.Errorf("field %s expected=not_null but was null", field)
+			}
+		case "not_empty":
+			if !ok || !got.Valid || strings.TrimSpace(got.String) == "" {
+				return fmt.Errorf("field %s expected=not_empty but was empty", field)
+			}
+		default:
+			actual := "undefined"
+			if ok {
+				actual = syzygyString(nil, true)
+				if got.Valid {
+					actual = got.String
+				}
+			}
+			if want != actual {
+				return fmt.Errorf("field %s expected=%s actual=%s", field, want, actual)
+			}
+		}
+	}
+	return nil
+}
+
+// syzygyJSONPath resolves the subset of JSONPath used by specs:
+// $.a.b, $.list[0] and $['key'].
+func syzygyJSONPath(doc any, path string) (any, bool) {
+	p := strings.TrimPrefix(path, "$")
+	cur := doc
+	for p != "" {
+		key, index := "", -1
+		switch {
+		case strings.HasPrefix(p, "."):
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key, p = p[:end], p[end:]
+		case strings.HasPrefix(p, "['"), strings.HasPrefix(p, "[\""):
+			end := strings.Index(p[2:], p[1:2]+"]")
+			if end < 0 {
+				return nil, false
+			}
+			key, p = p[2:2+end], p[2+end+2:]
+		case strings.HasPrefix(p, "["):
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, false
+			}
+			n, err := strconv.Atoi(p[1:end])
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			index, p = n, p[end+1:]
+		default:
+			return nil, false
+		}
+		if index >= 0 {
+			arr, ok := cur.([]any)
+			if !ok || index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[index]
+			continue
+		}
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// syzygyString formats a JSON value the way the runner's String() does.
+func syzygyString(v any, ok bool) string {
+	if !ok {
+		return "undefined"
+	}
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func syzygySortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+`
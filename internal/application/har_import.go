@@ -0,0 +1,502 @@
+package application
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// HAR import modes: net.call steps that replay the requests, or net.must rules
+// that expect the responses while the UI steps run.
+const (
+	harModeCall = "call"
+	harModeMust = "must"
+)
+
+var (
+	// harVolatileKey matches fields whose values change on every run.
+	harVolatileKey = regexp.MustCompile(`(?i)(^|_)(id|uuid|guid|token|nonce|sign|signature|trace_?id|request_?id|ts|timestamp|time|date|created(_at)?|updated(_at)?|expired?s?(_at)?)$|[a-z](Id|At|Time|Token)$`)
+	// harVolatileValue matches epoch timestamps, long numeric ids, dates and uuids.
+	harVolatileValue = regexp.MustCompile(`(?i)^(1\d{9}(\d{3})?|\d{15,}|\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}.*|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+	// harIDKey matches fields captured as anchors.
+	harIDKey = regexp.MustCompile(`^(?i:id|uuid)$|_(?i:id)$|[a-z]Id$`)
+	// harIdent keeps anchor and variable names usable in ${name}.
+	harIdent      = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+	harPlainField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	ResourceType string `json:"_resourceType"`
+	Request      struct {
+		Method   string `json:"method"`
+		URL      string `json:"url"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Params   []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// HarImportOptions selects the HAR entries to import and how.
+type HarImportOptions struct {
+	Path        string
+	URLContains []string
+	Methods     []string
+	Mode        string
+	// StepID receives the net.must rules; a new step is appended when empty.
+	StepID string
+}
+
+// harImport carries the values seen so far, so later requests reference
+// earlier responses as ${anchor} instead of recorded literals.
+type harImport struct {
+	run       *domain.Run
+	known     map[string]string // recorded value -> placeholder name
+	anchors   map[string]string // anchor key -> jsonpath
+	variables map[string]any
+}
+
+// HarImport appends net.call steps or net.must rules derived from the JSON
+// requests of a HAR capture. IDs returned by responses become anchors and are
+// referenced by later requests; volatile request values become variables.
+func (s *SyzygyService) HarImport(projectKey string, unitID, runID string, opts HarImportOptions) (map[string]any, error) {
+	mode := strings.TrimSpace(opts.Mode)
+	if mode == "" {
+		mode = harModeMust
+	}
+	if mode != harModeCall && mode != harModeMust {
+		return nil, NewAppError("invalid_args", "mode must be call or must")
+	}
+	if opts.StepID != "" && mode != harModeMust {
+		return nil, NewAppError("invalid_args", "step_id only applies to mode=must")
+	}
+	raw, err := os.ReadFile(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(raw, &har); err != nil {
+		return nil, NewAppError("invalid_har", fmt.Sprintf("%s: %v", opts.Path, err))
+	}
+
+	return s.editRun(projectKey, unitID, runID, "har_import", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		h := &harImport{run: run, known: map[string]string{}, anchors: map[string]string{}, variables: map[string]any{}}
+		for k, v := range run.Anchors {
+			h.remember(v, k)
+		}
+		for k, v := range run.Variables {
+			if sv, ok := v.(string); ok {
+				h.remember(sv, k)
+			}
+		}
+
+		steps := []*domain.ActionStep{}
+		rules := []any{}
+		skipped := 0
+		for _, e := range har.Log.Entries {
+			if !harSelected(e, opts) {
+				skipped++
+				continue
+			}
+			if mode == harModeCall {
+				st, err := h.callStep(e)
+				if err != nil {
+					return nil, nil, err
+				}
+				steps = append(steps, st)
+			} else {
+				rules = append(rules, h.mustRule(e))
+			}
+		}
+
+		if len(rules) > 0 {
+			if opts.StepID != "" {
+				idx, err := findStepIndex(run, opts.StepID)
+				if err != nil {
+					return nil, nil, err
+				}
+				st := run.Steps[idx]
+				if st.Net != nil && st.Net["op"] != nil {
+					return nil, nil, NewAppError("invalid_args", fmt.Sprintf("step %s already runs %v; net.must rules need a step without a net op", opts.StepID, st.Net["op"]))
+				}
+				if st.Net == nil {
+					st.Net = map[string]any{}
+				}
+				must, _ := st.Net["must"].([]any)
+				st.Net["must"] = append(must, rules...)
+				if err := st.Validate(); err != nil {
+					return nil, nil, NewAppError("invalid_step", err.Error())
+				}
+			} else {
+				st, err := domain.ParseActionStep(map[string]any{"name": "har: " + filepath.Base(opts.Path), "net": map[string]any{"must": rules}})
+				if err != nil {
+					return nil, nil, NewAppError("invalid_step", err.Error())
+				}
+				steps = append(steps, &st)
+			}
+		}
+
+		stepIDs := []string{}
+		for _, st := range steps {
+			id, err := domain.NewID("step")
+			if err != nil {
+				return nil, nil, err
+			}
+			st.StepID = id
+			run.Steps = append(run.Steps, st)
+			stepIDs = append(stepIDs, id)
+		}
+		if len(h.variables) > 0 && run.Variables == nil {
+			run.Variables = map[string]any{}
+		}
+		for k, v := range h.variables {
+			run.Variables[k] = v
+		}
+
+		imported := len(har.Log.Entries) - skipped
+		result := map[string]any{
+			"mode":      mode,
+			"imported":  imported,
+			"skipped":   skipped,
+			"step_ids":  stepIDs,
+			"anchors":   h.anchors,
+			"variables": h.variables,
+		}
+		if mode == harModeMust {
+			result["rules"] = len(rules)
+		}
+		return result, map[string]any{"path": opts.Path, "mode": mode, "imported": imported}, nil
+	})
+}
+
+// harSelected keeps JSON API traffic matching the caller's filters.
+func harSelected(e harEntry, opts HarImportOptions) bool {
+	if e.Response.Status == 0 {
+		return false
+	}
+	if len(opts.Methods) > 0 {
+		ok := false
+		for _, m := range opts.Methods {
+			ok = ok || strings.EqualFold(m, e.Request.Method)
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(opts.URLContains) > 0 && !matchesAny(e.Request.URL, opts.URLContains) {
+		return false
+	}
+	rt := strings.ToLower(e.ResourceType)
+	return rt == "xhr" || rt == "fetch" || strings.Contains(e.Response.Content.MimeType, "json")
+}
+
+func (h *harImport) callStep(e harEntry) (*domain.ActionStep, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return nil, NewAppError("invalid_har", err.Error())
+	}
+	target := h.pathOf(u)
+	if u.RawQuery != "" {
+		q := u.Query()
+		for _, k := range sortedKeys(q) {
+			for i, v := range q[k] {
+				q[k][i] = h.literal(k, v)
+			}
+		}
+		target += "?" + strings.NewReplacer("%24%7B", "${", "%7D", "}").Replace(q.Encode())
+	}
+	call := map[string]any{
+		"op":     "net.call",
+		"method": strings.ToUpper(e.Request.Method),
+		"url":    target,
+		"status": json.Number(strconv.Itoa(e.Response.Status)),
+	}
+	if pd := e.Request.PostData; pd != nil {
+		switch {
+		case strings.Contains(pd.MimeType, "json"):
+			var body any
+			dec := json.NewDecoder(strings.NewReader(pd.Text))
+			dec.UseNumber()
+			if err := dec.Decode(&body); err == nil {
+				call["json"] = h.body("", body)
+			}
+		case strings.Contains(pd.MimeType, "x-www-form-urlencoded"):
+			form := map[string]any{}
+			params := pd.Params
+			if len(params) == 0 {
+				if q, err := url.ParseQuery(pd.Text); err == nil {
+					for _, k := range sortedKeys(q) {
+						form[k] = h.literal(k, q.Get(k))
+					}
+				}
+			}
+			for _, p := range params {
+				form[p.Name] = h.literal(p.Name, p.Value)
+			}
+			call["form"] = form
+		}
+	}
+
+	doc := harResponseJSON(e)
+	if expect := harExpectJSON(doc); len(expect) > 0 {
+		call["expect_json"] = expect
+	}
+	if key, path := h.capture(u, doc); key != "" {
+		call["anchor"] = map[string]any{"key": key, "jsonpath": path}
+	}
+	st, err := domain.ParseActionStep(map[string]any{"name": call["method"].(string) + " " + h.pathOf(u), "net": call})
+	if err != nil {
+		return nil, NewAppError("invalid_step", fmt.Sprintf("%s %s: %v", e.Request.Method, e.Request.URL, err))
+	}
+	return &st, nil
+}
+
+func (h *harImport) mustRule(e harEntry) map[string]any {
+	u, err := url.Parse(e.Request.URL)
+	path := e.Request.URL
+	if err == nil {
+		path = h.pathOf(u)
+	}
+	rule := map[string]any{
+		"method":       strings.ToUpper(e.Request.Method),
+		"url_contains": path,
+		"status":       json.Number(strconv.Itoa(e.Response.Status)),
+	}
+	doc := harResponseJSON(e)
+	if expect := harExpectJSON(doc); len(expect) > 0 {
+		rule["expect_json"] = expect
+	}
+	if err == nil {
+		if key, jp := h.capture(u, doc); key != "" {
+			rule["anchor"] = map[string]any{"key": key, "jsonpath": jp}
+		}
+	}
+	return rule
+}
+
+// pathOf returns the URL path with segments recorded earlier replaced by
+// their placeholders.
+func (h *harImport) pathOf(u *url.URL) string {
+	segs := strings.Split(u.Path, "/")
+	for i, seg := range segs {
+		if name, ok := h.known[seg]; ok {
+			segs[i] = "${" + name + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// literal returns the placeholder for a known value, moves a volatile value
+// into a new variable, or keeps the value.
+func (h *harImport) literal(key, v string) string {
+	if name, ok := h.known[v]; ok {
+		return "${" + name + "}"
+	}
+	if v == "" || !(harVolatileKey.MatchString(key) || harVolatileValue.MatchString(v)) {
+		return v
+	}
+	name := h.newName(harIdentName(key, "value"), func(n string) bool {
+		_, taken := h.variables[n]
+		_, inRun := h.run.Variables[n]
+		return taken || inRun
+	})
+	h.variables[name] = v
+	h.remember(v, name)
+	return "${" + name + "}"
+}
+
+// body rewrites the string leaves of a JSON request body with literal.
+func (h *harImport) body(key string, v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := map[string]any{}
+		for k, child := range t {
+			out[k] = h.body(k, child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			out[i] = h.body(key, child)
+		}
+		return out
+	case string:
+		return h.literal(key, t)
+	case json.Number:
+		if name, ok := h.known[t.String()]; ok {
+			return "${" + name + "}"
+		}
+	}
+	return v
+}
+
+// capture picks the shallowest id field of a response object (arrays are not
+// searched) as the anchor of the request.
+func (h *harImport) capture(u *url.URL, doc any) (string, string) {
+	type found struct {
+		path, field, value string
+		depth              int
+	}
+	var best *found
+	var walk func(path string, v any, depth int)
+	walk = func(path string, v any, depth int) {
+		obj, ok := v.(map[string]any)
+		if !ok || depth > 3 {
+			return
+		}
+		for _, k := range sortedKeys(obj) {
+			p := path + "." + k
+			if !harPlainField.MatchString(k) {
+				p = path + "['" + k + "']"
+			}
+			val := ""
+			switch t := obj[k].(type) {
+			case string:
+				val = t
+			case json.Number:
+				val = t.String()
+			}
+			if val != "" && val != "0" && harIDKey.MatchString(k) && (best == nil || depth < best.depth) {
+				best = &found{path: p, field: k, value: val, depth: depth}
+			}
+			walk(p, obj[k], depth+1)
+		}
+	}
+	walk("$", doc, 0)
+	if best == nil {
+		return "", ""
+	}
+	if name, ok := h.known[best.value]; ok {
+		return name, best.path
+	}
+
+	base := harIdentName(best.field, "id")
+	if strings.EqualFold(best.field, "id") || strings.EqualFold(best.field, "uuid") {
+		base = harResource(u) + "_" + strings.ToLower(best.field)
+	}
+	key := h.newName(base, func(n string) bool {
+		_, taken := h.anchors[n]
+		return taken
+	})
+	h.anchors[key] = best.path
+	if h.run.Anchors == nil {
+		h.run.Anchors = map[string]string{}
+	}
+	h.run.Anchors[key] = best.value
+	h.remember(best.value, key)
+	return key, best.path
+}
+
+func (h *harImport) remember(value, name string) {
+	if len(value) >= 4 {
+		if _, ok := h.known[value]; !ok {
+			h.known[value] = name
+		}
+	}
+}
+
+func (h *harImport) newName(base string, taken func(string) bool) string {
+	name := base
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	return name
+}
+
+// harExpectJSON keeps the stable top-level scalars of a response, which is
+// what the runner compares expect_json against.
+func harExpectJSON(doc any) map[string]any {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return nil
+	}
+	out := map[string]any{}
+	for k, v := range obj {
+		var s string
+		switch t := v.(type) {
+		case string:
+			s = t
+		case json.Number:
+			s = t.String()
+		case bool:
+			s = strconv.FormatBool(t)
+		default:
+			continue
+		}
+		if harVolatileKey.MatchString(k) || harVolatileValue.MatchString(s) {
+			continue
+		}
+		out[k] = s
+	}
+	return out
+}
+
+func harResponseJSON(e harEntry) any {
+	text := e.Response.Content.Text
+	if e.Response.Content.Encoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil
+		}
+		text = string(b)
+	}
+	var doc any
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// harResource names the resource of a URL after its last non-numeric path
+// segment, singular: /api/hazards/12/assign -> assign, /api/hazards -> hazard.
+func harResource(u *url.URL) string {
+	segs := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := len(segs) - 1; i >= 0; i-- {
+		seg := segs[i]
+		if seg == "" || strings.Trim(seg, "0123456789") == "" || harVolatileValue.MatchString(seg) {
+			continue
+		}
+		seg = harIdentName(seg, "resource")
+		if len(seg) > 3 && strings.HasSuffix(seg, "s") && !strings.HasSuffix(seg, "ss") {
+			seg = seg[:len(seg)-1]
+		}
+		return seg
+	}
+	return "resource"
+}
+
+func harIdentName(s, fallback string) string {
+	s = strings.Trim(harIdent.ReplaceAllString(s, "_"), "_")
+	if s == "" {
+		return fallback
+	}
+	return s
+}
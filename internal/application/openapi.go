@@ -0,0 +1,417 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// openapiMaxDepth bounds schema walks, which may be recursive through $ref.
+const openapiMaxDepth = 5
+
+var (
+	openapiMethods   = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+	openapiPathParam = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+// openapiDoc is a loaded OpenAPI 3 document with local $ref resolution.
+type openapiDoc struct {
+	raw      map[string]any
+	basePath string
+}
+
+// openapiOperation is one operation of the document.
+type openapiOperation struct {
+	OperationID string `json:"operation_id"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Summary     string `json:"summary,omitempty"`
+
+	op         map[string]any
+	pathParams []any
+}
+
+func loadOpenAPI(path string) (*openapiDoc, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimSpace(bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")))
+	if !bytes.HasPrefix(raw, []byte("{")) {
+		return nil, NewAppError("invalid_openapi", path+": only JSON OpenAPI documents are supported; convert YAML to JSON first")
+	}
+	doc := &openapiDoc{raw: map[string]any{}}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc.raw); err != nil {
+		return nil, NewAppError("invalid_openapi", fmt.Sprintf("%s: %v", path, err))
+	}
+	if v, _ := doc.raw["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		return nil, NewAppError("invalid_openapi", path+": not an OpenAPI 3 document (missing openapi: 3.x)")
+	}
+	// Requests go to the path of the first server, e.g. https://host/api -> /api.
+	if servers, _ := doc.raw["servers"].([]any); len(servers) > 0 {
+		if s, ok := servers[0].(map[string]any); ok {
+			if u, err := url.Parse(anyToString(s["url"])); err == nil {
+				doc.basePath = strings.TrimSuffix(u.Path, "/")
+			}
+		}
+	}
+	return doc, nil
+}
+
+// operations lists the operations of the document by path and method.
+func (d *openapiDoc) operations() []*openapiOperation {
+	paths, _ := d.raw["paths"].(map[string]any)
+	out := []*openapiOperation{}
+	for _, p := range sortedKeys(paths) {
+		item, ok := d.resolve(paths[p]).(map[string]any)
+		if !ok {
+			continue
+		}
+		shared, _ := item["parameters"].([]any)
+		for _, m := range openapiMethods {
+			op, ok := item[m].(map[string]any)
+			if !ok {
+				continue
+			}
+			id, _ := op["operationId"].(string)
+			summary, _ := op["summary"].(string)
+			out = append(out, &openapiOperation{OperationID: id, Method: strings.ToUpper(m), Path: p, Summary: summary, op: op, pathParams: shared})
+		}
+	}
+	return out
+}
+
+// resolve follows local $ref pointers (#/components/...).
+func (d *openapiDoc) resolve(v any) any {
+	for i := 0; i < openapiMaxDepth; i++ {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return v
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+		var cur any = d.raw
+		for _, tok := range strings.Split(ref[2:], "/") {
+			tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return nil
+			}
+			cur = obj[tok]
+		}
+		v = cur
+	}
+	return v
+}
+
+// schema resolves a schema and merges allOf; oneOf/anyOf use their first entry.
+func (d *openapiDoc) schema(v any, depth int) map[string]any {
+	s, _ := d.resolve(v).(map[string]any)
+	if s == nil || depth > openapiMaxDepth {
+		return nil
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if alts, ok := s[k].([]any); ok && len(alts) > 0 {
+			return d.schema(alts[0], depth+1)
+		}
+	}
+	all, ok := s["allOf"].([]any)
+	if !ok {
+		return s
+	}
+	merged := map[string]any{"type": "object"}
+	props := map[string]any{}
+	required := []any{}
+	own := map[string]any{"properties": s["properties"], "required": s["required"]}
+	for _, part := range append([]any{own}, all...) {
+		ps := d.schema(part, depth+1)
+		if ps == nil {
+			continue
+		}
+		if p, ok := ps["properties"].(map[string]any); ok {
+			for k, v := range p {
+				props[k] = v
+			}
+		}
+		if r, ok := ps["required"].([]any); ok {
+			required = append(required, r...)
+		}
+	}
+	merged["properties"] = props
+	merged["required"] = required
+	return merged
+}
+
+// skeleton builds a request value from a schema: example, default or enum when
+// present, ${name} for strings, zero values otherwise. Only the required
+// properties of an object are included when it lists any.
+func (d *openapiDoc) skeleton(v any, name string, depth int) any {
+	s := d.schema(v, depth)
+	if s == nil {
+		return nil
+	}
+	for _, k := range []string{"example", "default", "const"} {
+		if ex, ok := s[k]; ok {
+			return ex
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	props, isObj := s["properties"].(map[string]any)
+	switch t, _ := s["type"].(string); {
+	case isObj || t == "object":
+		out := map[string]any{}
+		keys := sortedKeys(props)
+		if req := toStringSliceAny(s["required"]); len(req) > 0 {
+			keys = req
+		}
+		for _, k := range keys {
+			if p, ok := props[k]; ok {
+				out[k] = d.skeleton(p, k, depth+1)
+			}
+		}
+		return out
+	case t == "array":
+		return []any{d.skeleton(s["items"], name, depth+1)}
+	case t == "integer" || t == "number":
+		return 0
+	case t == "boolean":
+		return false
+	}
+	return "${" + harIdentName(name, "value") + "}"
+}
+
+// requiredScalars walks the required properties of a response schema and
+// reports each required scalar with its JSONPath.
+func (d *openapiDoc) requiredScalars(v any, path string, depth int, visit func(path, field string, s map[string]any)) {
+	s := d.schema(v, depth)
+	if s == nil {
+		return
+	}
+	props, _ := s["properties"].(map[string]any)
+	for _, k := range toStringSliceAny(s["required"]) {
+		ps := d.schema(props[k], depth+1)
+		if ps == nil {
+			continue
+		}
+		p := path + "." + k
+		if !harPlainField.MatchString(k) {
+			p = path + "['" + k + "']"
+		}
+		if _, isObj := ps["properties"]; isObj || ps["type"] == "object" {
+			d.requiredScalars(ps, p, depth+1, visit)
+			continue
+		}
+		if ps["type"] != "array" {
+			visit(p, k, ps)
+		}
+	}
+}
+
+// openapiContent picks the JSON media type of a content map, then form types.
+func openapiContent(content map[string]any) (string, map[string]any) {
+	keys := sortedKeys(content)
+	for _, pref := range []string{"json", "x-www-form-urlencoded", "form-data"} {
+		for _, k := range keys {
+			if strings.Contains(k, pref) {
+				m, _ := content[k].(map[string]any)
+				return k, m
+			}
+		}
+	}
+	return "", nil
+}
+
+// OpenAPIOperations lists the operations of a local OpenAPI 3 JSON document.
+func (s *SyzygyService) OpenAPIOperations(path string) (map[string]any, error) {
+	doc, err := loadOpenAPI(path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"base_path": doc.basePath, "operations": doc.operations()}, nil
+}
+
+// OpenAPIStepAppend appends a net.call step for an operation of a local
+// OpenAPI 3 document and records its path in the unit's touchpoints.api.
+func (s *SyzygyService) OpenAPIStepAppend(projectKey string, unitID, runID, path, operationID string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	doc, err := loadOpenAPI(path)
+	if err != nil {
+		return nil, err
+	}
+	var op *openapiOperation
+	ids := []string{}
+	for _, o := range doc.operations() {
+		if o.OperationID == operationID {
+			op = o
+		}
+		if o.OperationID != "" {
+			ids = append(ids, o.OperationID)
+		}
+	}
+	if op == nil {
+		return nil, NewAppError("operation_not_found", fmt.Sprintf("operation %q not found; available: %s", operationID, strings.Join(ids, ", ")))
+	}
+
+	call := map[string]any{"op": "net.call", "method": op.Method}
+	target := doc.basePath + openapiPathParam.ReplaceAllStringFunc(op.Path, func(m string) string {
+		return "${" + harIdentName(m[1:len(m)-1], "param") + "}"
+	})
+	query := []string{}
+	headers := map[string]any{}
+	for _, p := range append(append([]any{}, op.pathParams...), anySlice(op.op["parameters"])...) {
+		param, _ := doc.resolve(p).(map[string]any)
+		name, _ := param["name"].(string)
+		if required, _ := param["required"].(bool); !required || name == "" {
+			continue
+		}
+		switch param["in"] {
+		case "query":
+			query = append(query, url.QueryEscape(name)+"=${"+harIdentName(name, "param")+"}")
+		case "header":
+			if !strings.EqualFold(name, "Authorization") && !strings.EqualFold(name, "Content-Type") {
+				headers[name] = "${" + harIdentName(name, "header") + "}"
+			}
+		}
+	}
+	if len(query) > 0 {
+		target += "?" + strings.Join(query, "&")
+	}
+	call["url"] = target
+	if len(headers) > 0 {
+		call["headers"] = headers
+	}
+
+	if body, _ := doc.resolve(op.op["requestBody"]).(map[string]any); body != nil {
+		content, _ := body["content"].(map[string]any)
+		mediaType, media := openapiContent(content)
+		if media != nil {
+			value := doc.skeleton(media["schema"], "body", 0)
+			if strings.Contains(mediaType, "json") {
+				call["json"] = value
+			} else if form, ok := value.(map[string]any); ok {
+				call["form"] = form
+			}
+		}
+	}
+
+	// The first documented 2xx response sets the status and the assertions.
+	responses, _ := op.op["responses"].(map[string]any)
+	present := []string{}
+	for _, code := range sortedKeys(responses) {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		status := strings.ToLower(code)
+		if status == "2xx" {
+			status = "200"
+		}
+		call["status"] = json.Number(status)
+		res, _ := doc.resolve(responses[code]).(map[string]any)
+		content, _ := res["content"].(map[string]any)
+		_, media := openapiContent(content)
+		if media == nil {
+			break
+		}
+		expect := map[string]any{}
+		doc.requiredScalars(media["schema"], "$", 0, func(p, field string, fs map[string]any) {
+			if enum, ok := fs["enum"].([]any); ok && len(enum) == 1 {
+				expect[p] = anyToString(enum[0])
+				return
+			}
+			for _, k := range []string{"const", "default"} {
+				if v, ok := fs[k]; ok {
+					expect[p] = anyToString(v)
+					return
+				}
+			}
+			if harIDKey.MatchString(field) && call["anchor"] == nil {
+				// A bare id is named after the resource, e.g. hazard_id.
+				key := harIdentName(field, "id")
+				if strings.EqualFold(field, "id") || strings.EqualFold(field, "uuid") {
+					key = harResource(&url.URL{Path: op.Path}) + "_" + strings.ToLower(field)
+				}
+				call["anchor"] = map[string]any{"key": key, "jsonpath": p}
+				return
+			}
+			present = append(present, p)
+		})
+		if len(expect) > 0 {
+			call["expect_jsonpath"] = expect
+		}
+		if len(present) > 0 {
+			// Required fields without a fixed value only have to be there.
+			call["expect_present"] = present
+		}
+		break
+	}
+
+	name := op.OperationID
+	if op.Summary != "" {
+		name = op.OperationID + ": " + op.Summary
+	}
+	step, err := domain.ParseActionStep(map[string]any{"name": name, "net": call})
+	if err != nil {
+		return nil, NewAppError("invalid_step", fmt.Sprintf("operation %s: %v", operationID, err))
+	}
+
+	touchpoint := doc.basePath + op.Path
+	return s.editUnitRun(projectKey, unitID, runID, "openapi_step_append", func(u *domain.Unit, run *domain.Run) (map[string]any, map[string]any, error) {
+		var err error
+		if step.StepID, err = domain.NewID("step"); err != nil {
+			return nil, nil, err
+		}
+		run.Steps = append(run.Steps, &step)
+
+		if u.Meta == nil {
+			u.Meta = map[string]any{}
+		}
+		touch, _ := u.Meta["touchpoints"].(map[string]any)
+		if touch == nil {
+			touch = map[string]any{}
+		}
+		apis := toStringSliceAny(touch["api"])
+		if !slices.Contains(apis, touchpoint) {
+			apis = append(apis, touchpoint)
+		}
+		touch["api"] = apis
+		u.Meta["touchpoints"] = touch
+
+		b, _ := json.Marshal(step)
+		result := map[string]any{
+			"step_id":      step.StepID,
+			"operation_id": operationID,
+			"method":       op.Method,
+			"url":          target,
+			"touchpoint":   touchpoint,
+			"placeholders": domain.Placeholders(string(b)),
+		}
+		if anchor, ok := call["anchor"]; ok {
+			result["anchor"] = anchor
+		}
+		if len(present) > 0 {
+			result["expect_present"] = present
+		}
+		return result, map[string]any{"step_id": step.StepID, "operation_id": operationID, "openapi": path}, nil
+	})
+}
+
+func anySlice(v any) []any {
+	arr, _ := v.([]any)
+	return arr
+}
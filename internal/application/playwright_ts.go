@@ -0,0 +1,700 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// renderPlaywrightTS generates a standalone Playwright test for a spec and its
+// prerequisites (in runner order). It mirrors the Node runner: ${var}
+// substitution against variables, env and anchors, net.must rules observed
+// while the steps run, and DB checks after the steps of each spec.
+func renderPlaywrightTS(spec *domain.Spec, prerequisites []*domain.Spec, datasources map[string]domain.Datasource) (string, error) {
+	g := &tsGen{}
+	specs := append(append([]*domain.Spec{}, prerequisites...), spec)
+	for _, sp := range specs {
+		if sp.Isolation != "" {
+			return "", fmt.Errorf("%s: isolation %s is not supported by playwright_ts; replay it with syzygy_replay", sp.UnitID, sp.Isolation)
+		}
+		if len(sp.Setup) > 0 || len(sp.Teardown) > 0 {
+			g.usesFixtures = true
+		}
+		for _, st := range concatSteps(sp.Setup, sp.Steps, sp.Teardown) {
+			ops, err := st.Ops()
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", sp.UnitID, err)
+			}
+			for _, op := range ops {
+				switch op.(type) {
+				case *domain.NetCall, *domain.NetExpect:
+					g.usesNet = true
+				case *domain.DBExec:
+					g.usesDB = true
+				}
+			}
+		}
+		if len(sp.DBChecks) > 0 {
+			g.usesDB = true
+		}
+	}
+	if c := domain.HasExtendedDbChecks(specs...); c != nil {
+		return "", fmt.Errorf("db check %s: rows, row_count, expect_rows and object asserts are not supported by playwright_ts; replay them with syzygy_replay", c.Name)
+	}
+	if err := generatedDatasources("playwright_ts", datasources, specs); err != nil {
+		return "", err
+	}
+
+	g.line(0, "// Generated by syzygy-mcp from unit %s (run %s). Re-run syzygy_crystallize instead of editing.", spec.UnitID, spec.RunID)
+	g.line(0, "// Run with: npx playwright test")
+	g.line(0, "import { test, expect, type Page } from '@playwright/test'")
+	if g.usesNet {
+		g.line(0, "import { JSONPath } from 'jsonpath-plus'")
+	}
+	if g.usesDB {
+		g.line(0, "import mysql from 'mysql2/promise'")
+	}
+	g.line(0, "")
+	g.line(0, "type Ctx = Record<string, unknown>")
+	g.line(0, "")
+	g.line(0, "// Anchors recorded on the run; steps add the ones they produce.")
+	g.line(0, "const ANCHORS: Record<string, string> = %s", tsLiteral(orEmptyStringMap(spec.Anchors)))
+	g.line(0, "const anchors: Record<string, string> = { ...ANCHORS }")
+	g.line(0, "const consoleErrors: string[] = []")
+	g.raw(tsHelpers)
+	if g.usesNet {
+		g.raw(tsNetHelpers)
+	}
+	if g.usesDB {
+		g.raw(tsDBHelpers)
+	}
+	if g.usesFixtures {
+		g.raw(tsFixtureHelpers)
+	}
+
+	names := map[string]string{}
+	calls := []string{}
+	for _, sp := range specs {
+		name, ok := names[sp.UnitID]
+		if !ok {
+			name = tsFuncName(sp.UnitID, len(names))
+			names[sp.UnitID] = name
+			if err := g.specFunc(name, sp); err != nil {
+				return "", err
+			}
+		}
+		calls = append(calls, name)
+	}
+
+	if isMobileSpec(spec) {
+		g.line(0, "")
+		g.line(0, "test.use({")
+		g.line(1, "viewport: { width: 390, height: 844 },")
+		g.line(1, "userAgent: 'Mozilla/5.0 (iPhone; CPU iPhone OS 15_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.0 Mobile/15E148 Safari/604.1',")
+		g.line(1, "deviceScaleFactor: 3,")
+		g.line(1, "isMobile: true,")
+		g.line(1, "hasTouch: true,")
+		g.line(0, "})")
+	}
+
+	title := spec.UnitID
+	if spec.Title != "" {
+		title += ": " + spec.Title
+	}
+	g.line(0, "")
+	g.line(0, "test(%s, async ({ page }) => {", tsLiteral(title))
+	g.line(1, "watchConsole(page)")
+	if g.usesFixtures {
+		// Teardown runs for every spec that started, last spec first.
+		g.line(1, "try {")
+		for _, c := range calls {
+			g.line(2, "await %s(page)", c)
+		}
+		g.line(1, "} finally {")
+		g.line(2, "for (const td of teardowns.reverse()) await td()")
+		g.line(2, "expect.soft(teardownErrors, 'teardown errors').toEqual([])")
+		g.line(1, "}")
+	} else {
+		for _, c := range calls {
+			g.line(1, "await %s(page)", c)
+		}
+	}
+	g.line(0, "})")
+	return g.buf.String(), nil
+}
+
+type tsGen struct {
+	buf          strings.Builder
+	usesNet      bool
+	usesDB       bool
+	usesFixtures bool
+}
+
+func (g *tsGen) line(indent int, format string, args ...any) {
+	if format != "" {
+		g.buf.WriteString(strings.Repeat("  ", indent))
+		fmt.Fprintf(&g.buf, format, args...)
+	}
+	g.buf.WriteByte('\n')
+}
+
+func (g *tsGen) raw(s string) {
+	g.buf.WriteString(s)
+}
+
+// specFunc emits one async function running the steps of a spec.
+func (g *tsGen) specFunc(name string, spec *domain.Spec) error {
+	g.line(0, "")
+	g.line(0, "// %s", spec.UnitID)
+	g.line(0, "async function %s(page: Page): Promise<void> {", name)
+	g.line(1, "const VARIABLES: Ctx = %s", tsLiteral(orEmptyMap(spec.Variables)))
+	g.line(1, "const ENV: Ctx = %s", tsLiteral(orEmptyMap(spec.Env)))
+	g.line(1, "const ctx = (): Ctx => ({ ...VARIABLES, ...ENV, ...anchors })")
+	g.line(1, "const sub = (s: string): string => substitute(s, ctx())")
+
+	// net.must rules are watched from the start, like the runner's listener.
+	rules := []domain.NetRule{}
+	hasUI, hasGoto := false, false
+	for _, st := range spec.Steps {
+		r, err := st.NetRules()
+		if err != nil {
+			return fmt.Errorf("%s: %w", spec.UnitID, err)
+		}
+		rules = append(rules, r...)
+		if st.UI != nil {
+			hasUI = true
+			if op, _ := st.UI["op"].(string); op == "ui.goto" {
+				hasGoto = true
+			}
+		}
+	}
+	for i, r := range rules {
+		g.line(1, "const net%d = expectResponse(page, %s, ctx)", i, tsLiteral(r))
+	}
+	if len(spec.Teardown) > 0 {
+		g.line(1, "teardowns.push(async () => {")
+		for i, st := range spec.Teardown {
+			op, err := st.Op()
+			if err != nil {
+				return fmt.Errorf("%s: teardown[%d]: %w", spec.UnitID, i, err)
+			}
+			if op == nil {
+				continue
+			}
+			g.line(2, "await teardownStep(%s, async () => {", tsLiteral("teardown: "+fixtureTitle(st, op)))
+			g.stepBody(3, op)
+			g.line(2, "})")
+		}
+		g.line(1, "})")
+	}
+	for i, st := range spec.Setup {
+		op, err := st.Op()
+		if err != nil {
+			return fmt.Errorf("%s: setup[%d]: %w", spec.UnitID, i, err)
+		}
+		if op == nil {
+			continue
+		}
+		g.line(1, "await test.step(%s, async () => {", tsLiteral("setup: "+fixtureTitle(st, op)))
+		g.stepBody(2, op)
+		g.line(1, "})")
+	}
+	if baseURL, _ := spec.Env["base_url"].(string); hasUI && !hasGoto && baseURL != "" {
+		g.line(1, "await page.goto(sub(%s), { waitUntil: 'domcontentloaded' })", tsLiteral(baseURL))
+	}
+
+	for i, st := range spec.Steps {
+		op, err := st.Op()
+		if err != nil {
+			return fmt.Errorf("%s: steps[%d]: %w", spec.UnitID, i, err)
+		}
+		if op == nil {
+			continue
+		}
+		title := st.Name
+		if title == "" {
+			title = op.OpName()
+		}
+		g.line(1, "await test.step(%s, async () => {", tsLiteral(title))
+		g.stepBody(2, op)
+		g.line(1, "})")
+	}
+
+	for i, r := range rules {
+		g.line(1, "await expect.poll(() => net%d.hit, { message: %s, timeout: 5000 }).toBe(true)", i, tsLiteral("net.must "+describeNetRule(r)))
+	}
+	g.line(1, "expect(consoleErrors, 'console errors').toEqual([])")
+	for _, c := range spec.DBChecks {
+		title := c.Name
+		if title == "" {
+			title = c.CheckID
+		}
+		g.line(1, "await test.step(%s, () => dbCheck(%s, ctx))", tsLiteral("db check: "+title), tsLiteral(c))
+	}
+	g.line(0, "}")
+	return nil
+}
+
+func (g *tsGen) stepBody(in int, op domain.StepOp) {
+	switch v := op.(type) {
+	case *domain.UtilGenID:
+		g.line(in, "anchors[%s] = genId()", tsLiteral(v.Key))
+	case *domain.UtilGenTS:
+		g.line(in, "anchors[%s] = genTs()", tsLiteral(v.Key))
+	case *domain.DBExec:
+		g.line(in, "await dbExec(ctx(), %s, %s)", tsLiteral(v.SQL), tsLiteral(orEmptyStringMap(v.Params)))
+	case *domain.NetCall:
+		g.line(in, "await netCall(page, %s, ctx())", tsLiteral(v))
+	case *domain.UIGoto:
+		g.line(in, "await page.goto(%s, { waitUntil: 'domcontentloaded' })", tsString(v.URL))
+	case *domain.UIHashNavigate:
+		g.line(in, "await page.evaluate((h) => { window.location.hash = h }, %s)", tsString(v.Hash))
+		g.line(in, "await page.waitForTimeout(%s)", tsNumber(v.WaitMS, "2000"))
+	case *domain.UIEval:
+		g.line(in, "await page.evaluate((c) => new Function(c)(), %s)", tsString(v.Code))
+		g.line(in, "await page.waitForTimeout(%s)", tsNumber(v.WaitMS, "1000"))
+	case *domain.UIPickerSelect:
+		g.line(in, "await pickerSelect(page, %s)", tsNumber(v.Index, "0"))
+		g.line(in, "await page.waitForTimeout(%s)", tsNumber(v.WaitMS, "1000"))
+	case *domain.UIClick:
+		if v.Selector != "" {
+			g.line(in, "await clickSelector(page, %s, %t, %s)", tsString(v.Selector), v.UseEval, tsNumber(v.TimeoutMS, "5000"))
+		} else {
+			g.line(in, "await page.getByRole(%s, { name: %s }).click()", tsLiteral(v.Role), tsString(v.Name))
+		}
+	case *domain.UIFill:
+		value := tsString(v.Value)
+		switch {
+		case v.Selector != "":
+			index := "undefined"
+			if v.Index != "" {
+				index = v.Index.String()
+			}
+			g.line(in, "await fillSelector(page, %s, %s, %t, %s)", tsString(v.Selector), index, v.UseEval, value)
+		case v.Label != "":
+			g.line(in, "await page.getByLabel(%s).fill(%s)", tsLiteral(v.Label), value)
+		case v.Index != "":
+			g.line(in, "await fillByIndex(page, %s, %s)", v.Index.String(), value)
+		default:
+			g.line(in, "await page.locator('textarea.uni-textarea-textarea').first().fill(%s)", value)
+		}
+	case *domain.UIClickText:
+		g.line(in, "await page.getByText(%s, { exact: %t }).click()", tsString(v.Text), v.Exact == nil || *v.Exact)
+	case *domain.UIWaitText:
+		g.line(in, "await page.getByText(%s, { exact: %t }).waitFor({ timeout: %s })", tsString(v.Text), v.Exact != nil && *v.Exact, tsNumber(v.TimeoutMS, "15000"))
+	case *domain.UIWaitSelector:
+		state := v.State
+		if state == "" {
+			state = "visible"
+		}
+		g.line(in, "await page.locator(%s).first().waitFor({ state: %s, timeout: %s })", tsString(v.Selector), tsLiteral(state), tsNumber(v.TimeoutMS, "15000"))
+	case *domain.UIFillForm:
+		g.line(in, "await page.locator(%s).first().waitFor({ state: 'visible', timeout: %s })", tsLiteral(v.Selector), tsNumber(v.TimeoutMS, "15000"))
+		g.line(in, "await page.fill(%s, %s)", tsLiteral(v.Selector), tsString(anyToString(v.Value)))
+	case *domain.UIWaitMS:
+		g.line(in, "await page.waitForTimeout(%s)", tsNumber(v.MS, "500"))
+	case *domain.UIVerifyURLContains:
+		g.line(in, "expect(page.url()).toContain(%s)", tsString(v.URLContains))
+	}
+}
+
+func describeNetRule(r domain.NetRule) string {
+	parts := []string{}
+	if r.Method != "" {
+		parts = append(parts, "method="+r.Method)
+	}
+	if r.URLContains != "" {
+		parts = append(parts, "url_contains="+r.URLContains)
+	}
+	if r.Status != "" {
+		parts = append(parts, "status="+r.Status.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// isMobileSpec applies the runner's mobile emulation heuristics.
+func isMobileSpec(spec *domain.Spec) bool {
+	if mobile, _ := spec.Metadata["mobile"].(bool); mobile {
+		return true
+	}
+	if framework, _ := spec.Metadata["framework"].(string); framework == "uni-app" {
+		return true
+	}
+	baseURL, _ := spec.Env["base_url"].(string)
+	return strings.Contains(baseURL, "/h5")
+}
+
+// tsLiteral renders v as a JSON literal, which is valid TypeScript.
+func tsLiteral(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "null"
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// tsString renders a string literal, wrapped in sub() when it has placeholders.
+func tsString(s string) string {
+	if len(domain.Placeholders(s)) == 0 {
+		return tsLiteral(s)
+	}
+	return "sub(" + tsLiteral(s) + ")"
+}
+
+func tsNumber(n json.Number, def string) string {
+	if n == "" {
+		return def
+	}
+	return n.String()
+}
+
+// tsFuncName turns a unit id like hazard.create.v2 into runHazardCreateV2.
+func tsFuncName(unitID string, n int) string {
+	if id := exportedIdent(unitID); id != "" {
+		return "run" + id
+	}
+	return fmt.Sprintf("runSpec%d", n)
+}
+
+// exportedIdent turns a unit id like hazard.create.v2 into HazardCreateV2,
+// dropping everything that is not an ASCII letter or digit.
+func exportedIdent(unitID string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range unitID {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func orEmptyMap(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
+
+func orEmptyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+const tsHelpers = `
+function substitute(template: string, ctx: Ctx): string {
+  return template.replace(/\$\{([^}]+)\}/g, (_m, key: string) => (ctx[key] !== undefined ? String(ctx[key]) : ''))
+}
+
+function deepSubstitute<T>(value: T, ctx: Ctx): T {
+  if (typeof value === 'string') return substitute(value, ctx) as T
+  if (Array.isArray(value)) return value.map((v) => deepSubstitute(v, ctx)) as T
+  if (value && typeof value === 'object') {
+    return Object.fromEntries(Object.entries(value).map(([k, v]) => [k, deepSubstitute(v, ctx)])) as T
+  }
+  return value
+}
+
+// Same shape as the runner's util.gen_id: a bigint-like id within JS safe integers.
+function genId(): string {
+  return String(Date.now() * 1000 + Math.floor(Math.random() * 1000))
+}
+
+function genTs(): string {
+  return new Date().toISOString().replace(/[:.]/g, '-').replace('T', '-').slice(0, 19)
+}
+
+function watchConsole(page: Page): void {
+  page.on('console', (msg) => {
+    if (msg.type() !== 'error') return
+    const text = msg.text()
+    if (/Failed to load resource|Maximum update depth exceeded|Warning:|Missing translation|\[vite\]/i.test(text)) return
+    consoleErrors.push(text)
+  })
+}
+
+async function clickSelector(page: Page, selector: string, useEval: boolean, timeout: number): Promise<void> {
+  const evalClick = () =>
+    page.evaluate((sel) => {
+      ;(document.querySelector(sel) as HTMLElement | null)?.click()
+    }, selector)
+  if (useEval || selector.startsWith('uni-') || selector.includes('uni-button') || selector.includes('uni-view')) {
+    await evalClick()
+    return
+  }
+  try {
+    await page.locator(selector).click({ timeout })
+  } catch {
+    await evalClick()
+  }
+}
+
+async function fillByIndex(page: Page, index: number, value: string): Promise<void> {
+  const inputs = await page.locator('input.uni-input-input').all()
+  expect(inputs[index], 'input index ' + index).toBeTruthy()
+  await inputs[index].fill(value)
+}
+
+async function fillSelector(page: Page, selector: string, index: number | undefined, useEval: boolean, value: string): Promise<void> {
+  if (useEval || selector.includes('uni-input') || selector.includes('.uni-input-input')) {
+    if (index !== undefined) return fillByIndex(page, index, value)
+    return page.locator(selector).fill(value)
+  }
+  if (selector.includes('uni-textarea') || selector.includes('.uni-textarea-textarea')) {
+    return page.locator('textarea.uni-textarea-textarea').first().fill(value)
+  }
+  await page.locator(selector).fill(value)
+}
+
+async function pickerSelect(page: Page, index: number): Promise<void> {
+  await page.evaluate((idx) => {
+    const pickers = document.querySelectorAll('uni-picker')
+    const picker = pickers[idx] || pickers[0]
+    picker?.dispatchEvent(new CustomEvent('change', { detail: { value: idx }, bubbles: true }))
+  }, index)
+}
+`
+
+const tsNetHelpers = `
+type NetRule = {
+  method?: string
+  url_contains?: string
+  status?: number
+  expect_json?: Record<string, unknown>
+  expect_jsonpath?: Record<string, unknown>
+  anchor?: { key: string; jsonpath: string }
+  capture_anchors?: Record<string, string>
+  anchors?: Record<string, string>
+}
+
+type NetCall = NetRule & {
+  url: string
+  headers?: Record<string, unknown>
+  json?: unknown
+  form?: Record<string, unknown>
+  expect_present?: string[]
+  require?: { message?: string; need_unit?: string }
+}
+
+function jsonPathFirst(body: unknown, path: string): unknown {
+  const out = JSONPath({ path, json: body as object })
+  return Array.isArray(out) ? out[0] : out
+}
+
+function absoluteUrl(url: string, ctx: Ctx): string {
+  if (!url || /^https?:\/\//i.test(url) || !url.startsWith('/')) return url
+  const apiOrigin = String(ctx.api_origin || ctx.API_ORIGIN || '')
+  if (/^https?:\/\//i.test(apiOrigin)) return apiOrigin.replace(/\/$/, '') + url
+  const baseUrl = String(ctx.base_url || ctx.BASE_URL || '')
+  if (/^https?:\/\//i.test(baseUrl)) return new URL(baseUrl).origin + url
+  return url
+}
+
+async function responseJson(res: { headers(): Record<string, string>; json(): Promise<unknown> }): Promise<unknown> {
+  const ct = res.headers()['content-type'] || ''
+  return ct.includes('application/json') ? res.json().catch(() => null) : null
+}
+
+function bodyMatches(body: unknown, rule: NetRule, ctx: Ctx): boolean {
+  const obj = body && typeof body === 'object' ? (body as Record<string, unknown>) : null
+  for (const [k, expected] of Object.entries(rule.expect_json || {})) {
+    if (!obj || substitute(String(expected), ctx) !== String(obj[k])) return false
+  }
+  for (const [jp, expected] of Object.entries(rule.expect_jsonpath || {})) {
+    if (!obj || substitute(String(expected), ctx) !== String(jsonPathFirst(obj, jp))) return false
+  }
+  return true
+}
+
+function captureAnchors(body: unknown, rule: NetRule): void {
+  if (!body || typeof body !== 'object') return
+  const cap: Record<string, string> = { ...(rule.capture_anchors || rule.anchors || {}) }
+  if (rule.anchor?.key && rule.anchor?.jsonpath) cap[rule.anchor.key] = rule.anchor.jsonpath
+  for (const [key, jp] of Object.entries(cap)) {
+    const v = jsonPathFirst(body, jp)
+    if (v !== undefined && v !== null) anchors[key] = String(v)
+  }
+}
+
+// expectResponse waits for a response matching a net.must rule while the
+// steps run; hit is asserted once the steps are done.
+function expectResponse(page: Page, rule: NetRule, ctx: () => Ctx): { hit: boolean } {
+  const watch = { hit: false }
+  page
+    .waitForResponse(
+      async (res) => {
+        const c = ctx()
+        if (rule.method && rule.method.toUpperCase() !== res.request().method().toUpperCase()) return false
+        const urlContains = rule.url_contains ? substitute(rule.url_contains, c) : ''
+        if (urlContains && !res.url().includes(urlContains)) return false
+        if (rule.status && Number(rule.status) !== res.status()) return false
+        const body = await responseJson(res)
+        if (!bodyMatches(body, rule, c)) return false
+        captureAnchors(body, rule)
+        watch.hit = true
+        return true
+      },
+      { timeout: 0 },
+    )
+    .catch(() => null)
+  return watch
+}
+
+async function netCall(page: Page, call: NetCall, ctx: Ctx): Promise<void> {
+  const method = (call.method || 'GET').toUpperCase()
+  const url = absoluteUrl(substitute(call.url, ctx), ctx)
+  const headers: Record<string, string> = {}
+  for (const [k, v] of Object.entries(deepSubstitute(call.headers || {}, ctx))) headers[k] = String(v)
+  if (!Object.keys(headers).some((k) => k.toLowerCase() === 'authorization')) {
+    // Bearer token stored by the admin web, as the runner does.
+    const token = await page.evaluate(() => localStorage.getItem('token') || '').catch(() => '')
+    if (token) headers.Authorization = 'Bearer ' + token
+  }
+  try {
+    const res = await page.request.fetch(url, {
+      method,
+      headers,
+      data: call.json !== undefined ? deepSubstitute(call.json, ctx) : undefined,
+      form: call.form !== undefined ? (deepSubstitute(call.form, ctx) as Record<string, string>) : undefined,
+    })
+    if (call.status) expect(res.status(), method + ' ' + url + ' status').toBe(Number(call.status))
+    const body = (await responseJson(res)) as Record<string, unknown> | null
+    for (const [k, expected] of Object.entries(call.expect_json || {})) {
+      expect(String(body?.[k]), 'expect_json ' + k).toBe(substitute(String(expected), ctx))
+    }
+    for (const [jp, expected] of Object.entries(call.expect_jsonpath || {})) {
+      expect(String(jsonPathFirst(body, jp)), 'expect_jsonpath ' + jp).toBe(substitute(String(expected), ctx))
+    }
+    for (const jp of call.expect_present || []) {
+      expect(jsonPathFirst(body, jp) ?? null, 'expect_present ' + jp).not.toBeNull()
+    }
+    if (call.anchor) {
+      const v = jsonPathFirst(body, call.anchor.jsonpath)
+      expect(v ?? null, 'anchor ' + call.anchor.jsonpath).not.toBeNull()
+      anchors[call.anchor.key] = String(v)
+    }
+  } catch (e) {
+    if (call.require) {
+      const msg = call.require.message ? substitute(call.require.message, ctx) : 'prerequisite not satisfied'
+      const need = call.require.need_unit ? ' need_unit=' + call.require.need_unit : ''
+      throw new Error('Requirement failed: ' + msg + need + ': ' + String(e))
+    }
+    throw e
+  }
+}
+`
+
+const tsFixtureHelpers = `
+const teardowns: Array<() => Promise<void>> = []
+const teardownErrors: string[] = []
+
+// Teardown failures are collected so every teardown step still runs.
+async function teardownStep(title: string, body: () => Promise<void>): Promise<void> {
+  try {
+    await test.step(title, body)
+  } catch (e) {
+    teardownErrors.push(title + ': ' + (e instanceof Error ? e.message : String(e)))
+  }
+}
+`
+
+const tsDBHelpers = `
+type DbCheck = {
+  check_id?: string
+  name?: string
+  dms?: string
+  sql: string
+  params?: Record<string, string>
+  assert?: Record<string, unknown>
+  retry_attempts?: number
+  retry_interval_ms?: number
+}
+
+function dbConfig(ctx: Ctx) {
+  const pick = (k: string) => String(ctx[k] ?? ctx[k.toLowerCase()] ?? process.env[k] ?? '')
+  const cfg = {
+    host: pick('MYSQL_HOST'),
+    user: pick('MYSQL_USER'),
+    password: pick('MYSQL_PASSWORD'),
+    database: pick('MYSQL_DATABASE'),
+    port: Number(pick('MYSQL_PORT') || 3306),
+    charset: 'utf8mb4',
+    supportBigNumbers: true,
+    bigNumberStrings: true,
+  }
+  if (!cfg.host || !cfg.user || !cfg.database) {
+    throw new Error('Missing MySQL env. Required: MYSQL_HOST, MYSQL_USER, MYSQL_DATABASE (and MYSQL_PASSWORD if needed)')
+  }
+  return cfg
+}
+
+// toPositionalSQL rewrites :name parameters to ? placeholders, like the runner.
+function toPositionalSQL(sql: string, params: Record<string, string> | undefined, ctx: Ctx) {
+  const names: string[] = []
+  const out = sql.replace(/:([a-zA-Z_][a-zA-Z0-9_]*)/g, (_m, name: string) => {
+    names.push(name)
+    return '?'
+  })
+  return { sql: out, values: names.map((n) => substitute(params?.[n] ?? '', ctx)) }
+}
+
+async function dbExec(ctx: Ctx, sql: string, params: Record<string, string>): Promise<void> {
+  const conn = await mysql.createConnection(dbConfig(ctx))
+  try {
+    const q = toPositionalSQL(sql, params, ctx)
+    await conn.execute(q.sql, q.values)
+  } finally {
+    await conn.end()
+  }
+}
+
+async function dbCheck(check: DbCheck, ctx: () => Ctx): Promise<void> {
+  const conn = await mysql.createConnection(dbConfig(ctx()))
+  const attempts = check.retry_attempts || 1
+  const interval = check.retry_interval_ms || 500
+  let lastErr: unknown = null
+  try {
+    for (let i = 0; i < attempts; i++) {
+      try {
+        const c = ctx()
+        const q = toPositionalSQL(check.sql, check.params, c)
+        const [rows] = await conn.execute(q.sql, q.values)
+        if (!Array.isArray(rows) || rows.length === 0) throw new Error('no rows returned')
+        const row = rows[0] as Record<string, unknown>
+        for (const [field, raw] of Object.entries(check.assert || {})) {
+          const expected = typeof raw === 'string' ? substitute(raw, c) : String(raw)
+          const actual = row[field]
+          if (expected === 'not_null') {
+            if (actual === null || actual === undefined) throw new Error('field ' + field + ' expected=not_null but was null')
+          } else if (expected === 'not_empty') {
+            if (!actual || String(actual).trim() === '') throw new Error('field ' + field + ' expected=not_empty but was empty')
+          } else if (expected !== String(actual)) {
+            throw new Error('field ' + field + ' expected=' + expected + ' actual=' + actual)
+          }
+        }
+        return
+      } catch (e) {
+        lastErr = e
+        if (i < attempts - 1) await new Promise((r) => setTimeout(r, interval))
+      }
+    }
+    throw new Error('DB check failed: ' + (check.name || '') + ' - ' + String(lastErr))
+  } finally {
+    await conn.end()
+  }
+}
+`
@@ -0,0 +1,348 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanPlaceholder matches ${name} like domain.Placeholders; Postman spells
+// it {{name}}.
+var postmanPlaceholder = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+type postmanCollection struct {
+	Info     postmanInfo       `json:"info"`
+	Auth     *postmanAuth      `json:"auth,omitempty"`
+	Event    []postmanEvent    `json:"event,omitempty"`
+	Variable []postmanVariable `json:"variable,omitempty"`
+	Item     []*postmanItem    `json:"item"`
+}
+
+type postmanInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Schema      string `json:"schema"`
+}
+
+// postmanItem is a request, or a folder when Item is set.
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []*postmanItem  `json:"item,omitempty"`
+	Event   []postmanEvent  `json:"event,omitempty"`
+	Request *postmanRequest `json:"request,omitempty"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanVariable `json:"header"`
+	Body   *postmanBody      `json:"body,omitempty"`
+	URL    string            `json:"url"`
+	Auth   *postmanAuth      `json:"auth,omitempty"`
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw,omitempty"`
+	URLEncoded []postmanVariable `json:"urlencoded,omitempty"`
+	Options    map[string]any    `json:"options,omitempty"`
+}
+
+type postmanVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []postmanVariable `json:"bearer,omitempty"`
+}
+
+type postmanEvent struct {
+	Listen string        `json:"listen"`
+	Script postmanScript `json:"script"`
+}
+
+type postmanScript struct {
+	Type string   `json:"type"`
+	Exec []string `json:"exec"`
+}
+
+type postmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []postmanEnvValue `json:"values"`
+	Scope  string            `json:"_postman_variable_scope"`
+}
+
+type postmanEnvValue struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+// postmanOriginScript resolves /path URLs like the runner: api_origin, else
+// the origin of base_url.
+const postmanOriginScript = `const apiOrigin = pm.variables.get('api_origin') || pm.variables.get('API_ORIGIN') || '';
+const baseUrl = pm.variables.get('base_url') || pm.variables.get('BASE_URL') || '';
+const origin = /^https?:\/\//.test(apiOrigin) ? apiOrigin.replace(/\/$/, '') : ((/^(https?:\/\/[^/]+)/.exec(baseUrl) || [])[1] || '');
+pm.variables.set('syzygy_origin', origin);`
+
+// postmanTestHelpers are prepended to every test script: the runner's string
+// comparison and its JSONPath subset ($.a.b, $.a[0], $['a']).
+const postmanTestHelpers = `const str = (v) => v === undefined ? 'undefined' : v === null ? 'null' : typeof v === 'object' ? JSON.stringify(v) : String(v);
+const jsonPath = (doc, path) => {
+  let p = path.replace(/^\$/, '');
+  let cur = doc;
+  while (p) {
+    const m = /^(?:\.([^.[]+)|\[(?:'([^']*)'|"([^"]*)"|(\d+))\])/.exec(p);
+    if (!m || cur === null || typeof cur !== 'object') return undefined;
+    p = p.slice(m[0].length);
+    const key = m[1] !== undefined ? m[1] : m[2] !== undefined ? m[2] : m[3];
+    cur = key !== undefined ? cur[key] : cur[Number(m[4])];
+  }
+  return cur;
+};
+let body;
+try { body = pm.response.json(); } catch (e) { body = undefined; }`
+
+// renderPostman exports the net.call steps of a spec and its prerequisites as
+// a Postman v2.1 collection plus an environment built from the project env.
+// util.* steps become pre-request scripts; other ops need a browser or a
+// database and are rejected. Setup requests come before the steps of their
+// spec and teardown requests last, the last spec's first.
+func renderPostman(spec *domain.Spec, prerequisites []*domain.Spec, cfg *ProjectConfig) ([]TemplateOutput, error) {
+	title := spec.Title
+	if title == "" {
+		title = spec.UnitID
+	}
+	col := &postmanCollection{
+		Info: postmanInfo{Name: title, Description: postmanDescription(spec), Schema: postmanSchema},
+		Auth: &postmanAuth{Type: "bearer", Bearer: []postmanVariable{{Key: "token", Value: "{{SYZYGY_AUTH_TOKEN}}", Type: "string"}}},
+		Event: []postmanEvent{
+			{Listen: "prerequest", Script: postmanJS(postmanOriginScript)},
+		},
+		Item: []*postmanItem{},
+	}
+
+	// Later specs override the context of earlier ones, like shared anchors.
+	vars := map[string]string{}
+	teardowns := [][]*postmanItem{}
+	for _, sp := range append(append([]*domain.Spec{}, prerequisites...), spec) {
+		if sp.Isolation != "" {
+			return nil, fmt.Errorf("%s: isolation %s is not supported by postman; replay it with syzygy_replay", sp.UnitID, sp.Isolation)
+		}
+		setup, pending, err := postmanItems(sp, "setup", sp.Setup, nil)
+		if err != nil {
+			return nil, err
+		}
+		items, pending, err := postmanItems(sp, "steps", sp.Steps, pending)
+		if err != nil {
+			return nil, err
+		}
+		teardown, _, err := postmanItems(sp, "teardown", sp.Teardown, pending)
+		if err != nil {
+			return nil, err
+		}
+		items = append(setup, items...)
+		if sp != spec {
+			col.Item = append(col.Item, &postmanItem{Name: "prerequisite: " + sp.UnitID, Item: items})
+			if len(teardown) > 0 {
+				teardown = []*postmanItem{{Name: "prerequisite teardown: " + sp.UnitID, Item: teardown}}
+			}
+		} else {
+			col.Item = append(col.Item, items...)
+		}
+		teardowns = append(teardowns, teardown)
+		for k, v := range sp.Env {
+			vars[k] = anyToString(v)
+		}
+		for k, v := range sp.Variables {
+			vars[k] = anyToString(v)
+		}
+		for k, v := range sp.Anchors {
+			vars[k] = v
+		}
+	}
+	for i := len(teardowns) - 1; i >= 0; i-- {
+		col.Item = append(col.Item, teardowns[i]...)
+	}
+	for _, k := range sortedKeys(vars) {
+		col.Variable = append(col.Variable, postmanVariable{Key: k, Value: postmanText(vars[k]), Type: "string"})
+	}
+
+	env := &postmanEnvironment{Name: spec.UnitID, Values: []postmanEnvValue{}, Scope: "environment"}
+	if cfg != nil {
+		env.Name = cfg.ProjectKey
+		for _, k := range sortedKeys(cfg.Env) {
+			env.Values = append(env.Values, postmanEnvValue{Key: k, Value: cfg.Env[k], Type: "default", Enabled: true})
+		}
+	}
+	if cfg == nil || cfg.Env["SYZYGY_AUTH_TOKEN"] == "" {
+		env.Values = append(env.Values, postmanEnvValue{Key: "SYZYGY_AUTH_TOKEN", Type: "secret", Enabled: true})
+	}
+
+	colJSON, err := postmanJSON(col)
+	if err != nil {
+		return nil, err
+	}
+	envJSON, err := postmanJSON(env)
+	if err != nil {
+		return nil, err
+	}
+	return []TemplateOutput{
+		{Artifact: "postman_collection", File: spec.UnitID + ".postman_collection.json", Content: colJSON},
+		{Artifact: "postman_environment", File: spec.UnitID + ".postman_environment.json", Content: envJSON},
+	}, nil
+}
+
+// postmanItems turns the steps, setup or teardown steps of one spec into
+// requests. util.* steps run as pre-request scripts of the next request; the
+// scripts still pending after the last request are returned.
+func postmanItems(spec *domain.Spec, field string, steps []*domain.ActionStep, pending []string) ([]*postmanItem, []string, error) {
+	items := []*postmanItem{}
+	for i, st := range steps {
+		op, err := st.Op()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s[%d]: %w", spec.UnitID, field, i, err)
+		}
+		switch v := op.(type) {
+		case *domain.UtilGenID:
+			pending = append(pending, fmt.Sprintf("pm.collectionVariables.set(%s, String(Date.now() * 1000 + Math.floor(Math.random() * 1000)));", strconv.Quote(v.Key)))
+		case *domain.UtilGenTS:
+			pending = append(pending, fmt.Sprintf("pm.collectionVariables.set(%s, new Date().toISOString().slice(0, 19).replace(/[T:]/g, '-'));", strconv.Quote(v.Key)))
+		case *domain.NetCall:
+			if len(v.Must) > 0 {
+				return nil, nil, fmt.Errorf("%s: %s[%d]: net.call must rules need a browser; use playwright_ts", spec.UnitID, field, i)
+			}
+			name := st.Name
+			if name == "" {
+				name = fmt.Sprintf("%s %s", strings.ToUpper(v.Method), v.URL)
+			}
+			if field != "steps" {
+				name = field + ": " + name
+			}
+			item, err := postmanRequestItem(name, v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %s[%d]: %w", spec.UnitID, field, i, err)
+			}
+			if len(pending) > 0 {
+				item.Event = append([]postmanEvent{{Listen: "prerequest", Script: postmanJS(strings.Join(pending, "\n"))}}, item.Event...)
+				pending = nil
+			}
+			items = append(items, item)
+		case nil:
+			return nil, nil, fmt.Errorf("%s: %s[%d]: net.must rules need a browser; use playwright_ts", spec.UnitID, field, i)
+		default:
+			return nil, nil, fmt.Errorf("%s: %s[%d]: %s is not supported by postman (net.call and util.* only)", spec.UnitID, field, i, op.OpName())
+		}
+	}
+	return items, pending, nil
+}
+
+func postmanRequestItem(name string, c *domain.NetCall) (*postmanItem, error) {
+	method := strings.ToUpper(c.Method)
+	if method == "" {
+		method = "GET"
+	}
+	url := postmanText(c.URL)
+	if strings.HasPrefix(c.URL, "/") {
+		url = "{{syzygy_origin}}" + url
+	}
+	req := &postmanRequest{Method: method, Header: []postmanVariable{}, URL: url}
+	for _, k := range sortedKeys(c.Headers) {
+		req.Header = append(req.Header, postmanVariable{Key: k, Value: postmanText(anyToString(c.Headers[k]))})
+		if strings.EqualFold(k, "Authorization") {
+			req.Auth = &postmanAuth{Type: "noauth"}
+		}
+	}
+	switch {
+	case c.JSON != nil:
+		raw, err := json.MarshalIndent(c.JSON, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		req.Body = &postmanBody{Mode: "raw", Raw: postmanText(string(raw)), Options: map[string]any{"raw": map[string]any{"language": "json"}}}
+		req.Header = append(req.Header, postmanVariable{Key: "Content-Type", Value: "application/json"})
+	case c.Form != nil:
+		req.Body = &postmanBody{Mode: "urlencoded", URLEncoded: []postmanVariable{}}
+		for _, k := range sortedKeys(c.Form) {
+			req.Body.URLEncoded = append(req.Body.URLEncoded, postmanVariable{Key: k, Value: postmanText(anyToString(c.Form[k])), Type: "text"})
+		}
+	}
+
+	// Tests mirror the runner's net.call checks; a failed requirement names the
+	// unit that has to pass first.
+	prefix := ""
+	if c.Require != nil {
+		prefix = "[requirement"
+		if c.Require.NeedUnit != "" {
+			prefix += " need_unit=" + c.Require.NeedUnit
+		}
+		prefix += "] "
+	}
+	tests := []string{postmanTestHelpers}
+	if c.Status != "" {
+		tests = append(tests, fmt.Sprintf("pm.test(%s, () => pm.response.to.have.status(%s));", strconv.Quote(prefix+"status "+c.Status.String()), c.Status.String()))
+	}
+	for _, k := range sortedKeys(c.ExpectJSON) {
+		tests = append(tests, fmt.Sprintf("pm.test(%s, () => pm.expect(str(body === undefined || body === null ? undefined : body[%s])).to.eql(pm.variables.replaceIn(%s)));",
+			strconv.Quote(prefix+"expect_json "+k), strconv.Quote(k), strconv.Quote(postmanText(anyToString(c.ExpectJSON[k])))))
+	}
+	for _, jp := range sortedKeys(c.ExpectJSONPath) {
+		tests = append(tests, fmt.Sprintf("pm.test(%s, () => pm.expect(str(jsonPath(body, %s))).to.eql(pm.variables.replaceIn(%s)));",
+			strconv.Quote(prefix+"expect_jsonpath "+jp), strconv.Quote(jp), strconv.Quote(postmanText(anyToString(c.ExpectJSONPath[jp])))))
+	}
+	for _, jp := range c.ExpectPresent {
+		tests = append(tests, fmt.Sprintf("pm.test(%s, () => pm.expect(jsonPath(body, %s)).to.not.be.oneOf([undefined, null]));",
+			strconv.Quote(prefix+"expect_present "+jp), strconv.Quote(jp)))
+	}
+	if c.Anchor != nil {
+		tests = append(tests,
+			fmt.Sprintf("const anchor = jsonPath(body, %s);", strconv.Quote(c.Anchor.JSONPath)),
+			fmt.Sprintf("pm.test(%s, () => pm.expect(anchor).to.not.be.oneOf([undefined, null]));", strconv.Quote(prefix+"anchor "+c.Anchor.Key+" at "+c.Anchor.JSONPath)),
+			fmt.Sprintf("if (anchor !== undefined && anchor !== null) pm.collectionVariables.set(%s, str(anchor));", strconv.Quote(c.Anchor.Key)),
+		)
+	}
+	return &postmanItem{
+		Name:    name,
+		Event:   []postmanEvent{{Listen: "test", Script: postmanJS(strings.Join(tests, "\n"))}},
+		Request: req,
+	}, nil
+}
+
+func postmanDescription(spec *domain.Spec) string {
+	lines := []string{fmt.Sprintf("Generated by syzygy-mcp from unit %s (run %s).", spec.UnitID, spec.RunID)}
+	touch, _ := spec.Metadata["touchpoints"].(map[string]any)
+	if apis := toStringSliceAny(touch["api"]); len(apis) > 0 {
+		lines = append(lines, "APIs: "+strings.Join(apis, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// postmanText rewrites ${name} placeholders as Postman {{name}} variables.
+func postmanText(s string) string {
+	return postmanPlaceholder.ReplaceAllString(s, "{{$1}}")
+}
+
+func postmanJS(src string) postmanScript {
+	return postmanScript{Type: "text/javascript", Exec: strings.Split(src, "\n")}
+}
+
+func postmanJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
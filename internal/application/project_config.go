@@ -4,37 +4,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
 )
 
 type ProjectConfig struct {
+	SchemaVersion int               `json:"schema_version"`
 	ProjectKey    string            `json:"project_key"`
 	Env           map[string]string `json:"env"`
 	RunnerCommand string            `json:"runner_command"`
 	RunnerDir     string            `json:"runner_dir"`
 	ArtifactsDir  string            `json:"artifacts_dir"`
-	UpdatedAt     string            `json:"updated_at"`
-}
-
-func (s *SyzygyService) projectConfigPath(projectKey string) (string, error) {
-	base := s.store.BaseDir()
-	if base == "" {
-		base = os.Getenv("SYZYGY_HOME")
-	}
-	if base == "" {
-		return "", fmt.Errorf("SYZYGY_HOME is empty")
-	}
-	return filepath.Join(base, "projects", safeProjectKey(projectKey), "config.json"), nil
+	SpecsDir      string            `json:"specs_dir,omitempty"`     // crystallize writes <unit_id>.spec.json here
+	TemplatesDir  string            `json:"templates_dir,omitempty"` // user crystallize templates (*.tmpl, text/template)
+	// Datasources are the named databases of DB checks and db.exec steps
+	// (routed by dms) for the in-process replay engine.
+	Datasources map[string]domain.Datasource `json:"datasources,omitempty"`
+	UpdatedAt   string                       `json:"updated_at"`
 }
 
 func (s *SyzygyService) LoadProjectConfig(projectKey string) (*ProjectConfig, error) {
-	p, err := s.projectConfigPath(projectKey)
-	if err != nil {
-		return nil, err
-	}
-	b, err := os.ReadFile(p)
+	b, err := s.store.ReadProjectConfig(projectKey)
 	if err != nil {
 		return nil, err
 	}
@@ -52,26 +44,17 @@ func (s *SyzygyService) SaveProjectConfig(cfg *ProjectConfig) (string, error) {
 	if cfg == nil {
 		return "", fmt.Errorf("nil config")
 	}
-	p, err := s.projectConfigPath(cfg.ProjectKey)
-	if err != nil {
-		return "", err
-	}
 	if cfg.Env == nil {
 		cfg.Env = map[string]string{}
 	}
+	cfg.SchemaVersion = domain.ProjectConfigSchemaVersion
 	cfg.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 
-	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
-		return "", err
-	}
 	b, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return "", err
 	}
-	if err := os.WriteFile(p, b, 0o644); err != nil {
-		return "", err
-	}
-	return p, nil
+	return s.store.WriteProjectConfig(cfg.ProjectKey, b)
 }
 
 func (s *SyzygyService) EnsureProjectInitialized(projectKey string) (*ProjectConfig, error) {
@@ -85,17 +68,6 @@ func (s *SyzygyService) EnsureProjectInitialized(projectKey string) (*ProjectCon
 	return cfg, nil
 }
 
-func safeProjectKey(projectKey string) string {
-	k := strings.TrimSpace(projectKey)
-	if k == "" {
-		return "default"
-	}
-	k = strings.ReplaceAll(k, "..", "")
-	k = strings.ReplaceAll(k, string(filepath.Separator), "-")
-	k = strings.ReplaceAll(k, "/", "-")
-	return k
-}
-
 func anyToString(v any) string {
 	if v == nil {
 		return ""
@@ -0,0 +1,234 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// Replay engines selectable by syzygy_replay. auto uses the in-process
+// engine when neither the spec nor its prerequisites have ui steps.
+const (
+	ReplayEngineAuto = "auto"
+	ReplayEngineNode = "node"
+	ReplayEngineGo   = "go"
+)
+
+// SpecExecutor replays specs in-process. Specs are in execution order with
+// the unit's own spec last.
+type SpecExecutor interface {
+	Replay(specs []*domain.Spec, opts domain.ReplayOptions) *domain.ReplayReport
+	// RunDBChecks runs checks of spec once, without its steps.
+	RunDBChecks(spec *domain.Spec, checks []*domain.DbCheck, opts domain.ReplayOptions) []*domain.DbCheckResult
+	// Snapshot reads [datasource:]table entries for a row-level diff.
+	Snapshot(spec *domain.Spec, tables []string, opts domain.ReplayOptions) []*domain.TableSnapshot
+	// RunnerEnv returns the datasource dms resolves to for spec as the
+	// MYSQL_* settings of the Node runner.
+	RunnerEnv(spec *domain.Spec, dms string, opts domain.ReplayOptions) (map[string]string, error)
+	// RunFixtures runs the setup and teardown steps of specs in one session
+	// around replay, a replay by the Node runner that gets the anchors after
+	// setup and returns its own.
+	RunFixtures(specs []*domain.Spec, opts domain.ReplayOptions, replay func(anchors map[string]string) map[string]string) *domain.ReplayReport
+}
+
+// replaySpecs loads a crystallized spec and its prerequisites in the order the
+// runner executes them, nested prerequisites first. Unlike the template
+// helpers, a missing prerequisite fails the replay as it does in the runner.
+func replaySpecs(specPath string) ([]*domain.Spec, error) {
+	out := []*domain.Spec{}
+	if err := loadReplaySpec(specPath, 0, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func loadReplaySpec(path string, depth int, out *[]*domain.Spec) error {
+	if depth > maxPrerequisiteDepth {
+		return NewAppError("invalid_spec", fmt.Sprintf("%s: prerequisites nest deeper than %d levels", path, maxPrerequisiteDepth))
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return NewAppError("missing_artifact", err.Error())
+	}
+	var spec domain.Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return NewAppError("invalid_spec", fmt.Sprintf("%s: %v", path, err))
+	}
+	for _, rel := range spec.Prerequisites {
+		if err := loadReplaySpec(resolvePrerequisite(filepath.Dir(path), rel), depth+1, out); err != nil {
+			return err
+		}
+	}
+	*out = append(*out, &spec)
+	return nil
+}
+
+// replayEnv is the environment of a replay: the OS env overlaid with project
+// env, string unit env and tool env, in that order.
+func replayEnv(cfg *ProjectConfig, u *domain.Unit, env map[string]any) []string {
+	out := os.Environ()
+	for k, v := range cfg.Env {
+		out = append(out, k+"="+v)
+	}
+	for k, v := range u.Env {
+		if sv, ok := v.(string); ok {
+			out = append(out, k+"="+sv)
+		}
+	}
+	for k, v := range env {
+		if sv := anyToString(v); sv != "" {
+			out = append(out, k+"="+sv)
+		}
+	}
+	return out
+}
+
+// envMap turns KEY=VALUE pairs into a map; later pairs win.
+func envMap(procEnv []string) map[string]string {
+	out := map[string]string{}
+	for _, kv := range procEnv {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// replayNative replays specs with the in-process executor. Artifacts go where
+// the runner would write them; the snapshot tables are read before teardown.
+func (s *SyzygyService) replayNative(cfg *ProjectConfig, specs []*domain.Spec, specPath, cwd string, procEnv []string, run *domain.Run, snapshot []string) (map[string]any, *domain.ReplayReport) {
+	report := s.executor.Replay(specs, domain.ReplayOptions{
+		Env:          envMap(procEnv),
+		ArtifactsDir: runnerArtifactsDir(procEnv, cwd, specPath),
+		Datasources:  cfg.Datasources,
+		Snapshot:     snapshot,
+	})
+
+	output := strings.Join(report.Log, "\n")
+	if report.OK {
+		b, _ := json.MarshalIndent(map[string]any{"ok": true, "anchors": report.Anchors}, "", "  ")
+		output += "\n" + string(b)
+	}
+	result := map[string]any{"ok": report.OK, "engine": ReplayEngineGo, "output": output, "anchors": run.Anchors}
+	if !report.OK {
+		result["error"] = report.Error
+		if len(report.FailureArtifacts) > 0 {
+			result["failure_artifacts"] = report.FailureArtifacts
+		}
+	}
+	if len(report.Teardown) > 0 {
+		result["teardown"] = teardownResult(report.Teardown)
+	}
+	if report.RolledBack {
+		result["rolled_back"] = true
+	}
+	return result, report
+}
+
+// runnerDatasourceEnv returns the MYSQL_* settings that point the Node runner
+// at the project datasource its db.exec steps and basic DB checks resolve to,
+// so the runner and the Go engine read and write the same database. Setup,
+// teardown and extended checks run in the Go engine on any datasource.
+func (s *SyzygyService) runnerDatasourceEnv(specs []*domain.Spec, opts domain.ReplayOptions) ([]string, error) {
+	if len(opts.Datasources) == 0 {
+		return nil, nil
+	}
+	var first *domain.DatasourceUse
+	name := ""
+	for _, u := range domain.DatasourceUses(specs...) {
+		if u.Fixture || (u.Check != nil && u.Check.Extended()) {
+			continue
+		}
+		n, _, _, err := domain.ResolveDatasource(opts.Datasources, u.DMS, u.Spec.Env)
+		if err != nil {
+			return nil, NewAppError("invalid_spec", fmt.Sprintf("%s: %s: %v", u.Spec.UnitID, u.Where, err))
+		}
+		if first == nil {
+			first, name = &u, n
+		} else if n != name {
+			return nil, NewAppError("invalid_args", fmt.Sprintf("the node runner reaches one database, but %s: %s uses datasource %s and %s: %s uses %s", first.Spec.UnitID, first.Where, name, u.Spec.UnitID, u.Where, n))
+		}
+	}
+	if first == nil {
+		return nil, nil
+	}
+	if s.executor == nil {
+		return nil, NewAppError("environment_error", "project datasources need the go replay engine to hand them to the node runner, which is not available")
+	}
+	env, err := s.executor.RunnerEnv(first.Spec, first.DMS, opts)
+	if err != nil {
+		return nil, NewAppError("invalid_args", fmt.Sprintf("%s: %s: %v", first.Spec.UnitID, first.Where, err))
+	}
+	out := []string{}
+	for _, k := range sortedKeys(env) {
+		out = append(out, k+"="+env[k])
+	}
+	return out, nil
+}
+
+// runExtendedDbChecks evaluates the DB checks the Node runner skipped under
+// SYZYGY_DB_CHECKS=basic, with the anchors it reported and the retries of each
+// check, and records them in result. It reports whether one failed.
+func (s *SyzygyService) runExtendedDbChecks(specs []*domain.Spec, result map[string]any, opts domain.ReplayOptions) bool {
+	anchors := runnerReportAnchors(anyToString(result["output"]))
+	results := []*domain.DbCheckResult{}
+	defer func() { result["db_checks"] = results }()
+	for _, sp := range specs {
+		spec := *sp
+		spec.Anchors = map[string]string{}
+		for k, v := range sp.Anchors {
+			spec.Anchors[k] = v
+		}
+		for k, v := range anchors {
+			spec.Anchors[k] = v
+		}
+		for _, c := range sp.DBChecks {
+			if c == nil || !c.Extended() {
+				continue
+			}
+			r := s.runDbCheckRetrying(&spec, c, opts)
+			results = append(results, r)
+			if !r.OK {
+				result["ok"] = false
+				result["error"] = fmt.Sprintf("DB check failed: %s - %s", c.Name, r.Error)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *SyzygyService) runDbCheckRetrying(spec *domain.Spec, c *domain.DbCheck, opts domain.ReplayOptions) *domain.DbCheckResult {
+	interval := time.Duration(c.RetryIntervalMS) * time.Millisecond
+	if interval == 0 {
+		interval = 500 * time.Millisecond
+	}
+	for i := 1; ; i++ {
+		r := s.executor.RunDBChecks(spec, []*domain.DbCheck{c}, opts)[0]
+		if r.OK || i >= c.RetryAttempts {
+			return r
+		}
+		time.Sleep(interval)
+	}
+}
+
+// runnerReportAnchors reads the anchors from the JSON report the Node runner
+// prints last when it passes.
+func runnerReportAnchors(output string) map[string]string {
+	i := strings.LastIndex("\n"+output, "\n{\n")
+	if i < 0 {
+		return nil
+	}
+	var report struct {
+		Anchors map[string]string `json:"anchors"`
+	}
+	if err := json.Unmarshal([]byte(output[i:]), &report); err != nil {
+		return nil
+	}
+	return report.Anchors
+}
@@ -0,0 +1,48 @@
+package application
+
+import "encoding/json"
+
+type ResourceDefinition struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+type ResourceRegistry struct {
+	svc *SyzygyService
+}
+
+func NewResourceRegistry(svc *SyzygyService) *ResourceRegistry {
+	return &ResourceRegistry{svc: svc}
+}
+
+func (r *ResourceRegistry) ListResources() []ResourceDefinition {
+	return []ResourceDefinition{
+		{
+			URI:         SpecSchemaURI,
+			Name:        "Syzygy spec JSON Schema",
+			Description: "JSON Schema of spec.json files produced by syzygy_crystallize (spec.json 的 JSON Schema)",
+			MimeType:    "application/schema+json",
+		},
+	}
+}
+
+func (r *ResourceRegistry) ReadResource(uri string) (*ResourceContent, error) {
+	switch uri {
+	case SpecSchemaURI:
+		b, err := json.MarshalIndent(SpecJSONSchema(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &ResourceContent{URI: uri, MimeType: "application/schema+json", Text: string(b)}, nil
+	default:
+		return nil, NewAppError("resource_not_found", "resource not found: "+uri)
+	}
+}
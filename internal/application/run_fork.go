@@ -0,0 +1,136 @@
+package application
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// RunFork starts a new run of unitID seeded with the steps, DB checks, anchors
+// and variables of an existing run, possibly recorded under another unit.
+func (s *SyzygyService) RunFork(projectKey string, unitID, sourceUnitID, sourceRunID, title string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	if _, err := s.EnsureProjectInitialized(projectKey); err != nil {
+		return nil, err
+	}
+	if sourceUnitID == "" {
+		sourceUnitID = unitID
+	}
+
+	src, err := s.store.GetUnit(projectKey, sourceUnitID)
+	if err != nil {
+		return nil, err
+	}
+	if sourceRunID == "" {
+		sourceRunID = latestRunID(src)
+	}
+	srcRun, err := findRun(src, sourceRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An existing target unit keeps its own env and title; a new one starts
+	// from the source unit's.
+	u, err := s.store.GetUnit(projectKey, unitID)
+	if err != nil {
+		if title == "" {
+			title = src.Title
+		}
+		if u, err = s.store.GetOrCreateUnit(projectKey, unitID, title, src.Env); err != nil {
+			return nil, err
+		}
+	}
+
+	run, err := forkRun(srcRun)
+	if err != nil {
+		return nil, err
+	}
+	run.Meta["forked_from"] = map[string]any{
+		"unit_id": sourceUnitID,
+		"run_id":  sourceRunID,
+	}
+
+	u.Runs = append(u.Runs, run)
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveUnit(projectKey, u); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"unit_id":     unitID,
+		"run_id":      run.RunID,
+		"forked_from": run.Meta["forked_from"],
+		"steps":       len(run.Steps),
+		"db_checks":   len(run.DBChecks),
+	}, nil
+}
+
+// forkRun deep-copies the recorded content of src into a fresh in_progress run
+// with new run, step and check IDs.
+func forkRun(src *domain.Run) (*domain.Run, error) {
+	runID, err := domain.NewID("run")
+	if err != nil {
+		return nil, err
+	}
+	run := &domain.Run{
+		RunID:     runID,
+		Status:    domain.RunStatusInProgress,
+		Variables: map[string]any{},
+		Steps:     []*domain.ActionStep{},
+		Anchors:   map[string]string{},
+		DBChecks:  []*domain.DbCheck{},
+		Artifacts: map[string]string{},
+		StartedAt: time.Now().UTC(),
+		Meta:      map[string]any{},
+	}
+	if err := deepCopyJSON(src.Variables, &run.Variables); err != nil {
+		return nil, err
+	}
+	if err := deepCopyJSON(src.Steps, &run.Steps); err != nil {
+		return nil, err
+	}
+	if err := deepCopyJSON(src.DBChecks, &run.DBChecks); err != nil {
+		return nil, err
+	}
+	if err := deepCopyJSON(src.Setup, &run.Setup); err != nil {
+		return nil, err
+	}
+	if err := deepCopyJSON(src.Teardown, &run.Teardown); err != nil {
+		return nil, err
+	}
+	run.Isolation = src.Isolation
+	for k, v := range src.Anchors {
+		run.Anchors[k] = v
+	}
+	if run.Variables == nil {
+		run.Variables = map[string]any{}
+	}
+	if run.Steps == nil {
+		run.Steps = []*domain.ActionStep{}
+	}
+	if run.DBChecks == nil {
+		run.DBChecks = []*domain.DbCheck{}
+	}
+
+	steps := append(append(append([]*domain.ActionStep{}, run.Setup...), run.Steps...), run.Teardown...)
+	for _, st := range steps {
+		if st.StepID, err = domain.NewID("step"); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range run.DBChecks {
+		if c.CheckID, err = domain.NewID("db"); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+func deepCopyJSON(src any, dst any) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
@@ -0,0 +1,97 @@
+package application
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// transitionRun moves run to status `to`, enforcing the lifecycle defined in
+// domain and recording every change in run.Meta["status_history"].
+func transitionRun(run *domain.Run, to string, reason string) error {
+	from := run.Status
+	if from == "" {
+		from = domain.RunStatusInProgress
+	}
+	if !domain.CanTransitionRun(from, to) {
+		return NewAppError("invalid_run_transition", fmt.Sprintf("run %s cannot move from %s to %s", run.RunID, from, to))
+	}
+
+	now := time.Now().UTC()
+	run.Status = to
+	if domain.IsRunClosed(to) {
+		run.EndedAt = &now
+	}
+	if run.Meta == nil {
+		run.Meta = map[string]any{}
+	}
+	history, _ := run.Meta["status_history"].([]any)
+	run.Meta["status_history"] = append(history, map[string]any{
+		"from":   from,
+		"to":     to,
+		"at":     now.Format(time.RFC3339),
+		"reason": reason,
+	})
+	return nil
+}
+
+func ensureRunOpen(run *domain.Run) error {
+	if domain.IsRunClosed(run.Status) {
+		return NewAppError("run_closed", fmt.Sprintf("run %s is %s; start or fork a new run to record changes", run.RunID, run.Status))
+	}
+	return nil
+}
+
+// markRunEdited guards a mutation of the run's recorded content. Runs that
+// were already crystallized go back to in_progress since their artifacts are stale.
+func markRunEdited(run *domain.Run, reason string) error {
+	if err := ensureRunOpen(run); err != nil {
+		return err
+	}
+	if run.Status == "" || run.Status == domain.RunStatusInProgress {
+		run.Status = domain.RunStatusInProgress
+		return nil
+	}
+	return transitionRun(run, domain.RunStatusInProgress, reason)
+}
+
+// RunFinish closes a run as accepted (requires a passed replay) or abandoned.
+func (s *SyzygyService) RunFinish(projectKey string, unitID, runID, status, reason string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	status = strings.TrimSpace(status)
+	if status == "" {
+		status = domain.RunStatusAccepted
+	}
+	if status != domain.RunStatusAccepted && status != domain.RunStatusAbandoned {
+		return nil, NewAppError("invalid_args", "status must be accepted or abandoned")
+	}
+
+	u, err := s.store.GetUnit(projectKey, unitID)
+	if err != nil {
+		return nil, err
+	}
+	run, err := findRun(u, runID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureRunOpen(run); err != nil {
+		return nil, err
+	}
+	if status == domain.RunStatusAccepted && run.Status != domain.RunStatusReplayPassed {
+		return nil, NewAppError("invalid_run_transition", fmt.Sprintf("run %s is %s; only a run with a passed replay can be accepted", runID, run.Status))
+	}
+	if reason == "" {
+		reason = "syzygy_run_finish"
+	}
+	if err := transitionRun(run, status, reason); err != nil {
+		return nil, err
+	}
+
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveUnit(projectKey, u); err != nil {
+		return nil, err
+	}
+	return map[string]any{"unit_id": unitID, "run_id": runID, "status": run.Status, "ended_at": run.EndedAt}, nil
+}
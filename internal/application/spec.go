@@ -0,0 +1,123 @@
+package application
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// buildSpec assembles the crystallized spec of a run.
+func buildSpec(u *domain.Unit, run *domain.Run) *domain.Spec {
+	return &domain.Spec{
+		SpecVersion:   domain.SpecSchemaVersion,
+		UnitID:        u.UnitID,
+		RunID:         run.RunID,
+		Title:         u.Title,
+		Prerequisites: specPrerequisites(u),
+		Metadata:      specMetadata(u),
+		Env:           u.Env,
+		Variables:     run.Variables,
+		Anchors:       run.Anchors,
+		Steps:         run.Steps,
+		DBChecks:      run.DBChecks,
+		Setup:         run.Setup,
+		Teardown:      run.Teardown,
+		Isolation:     run.Isolation,
+	}
+}
+
+// rebasePrerequisites rewrites the prerequisites recorded relative to the
+// directory of the unit (see prerequisiteDir) so they resolve from dir.
+func rebasePrerequisites(u *domain.Unit, dir string) []string {
+	prerequisites := specPrerequisites(u)
+	base := prerequisiteDir(u)
+	if base == "" || len(prerequisites) == 0 {
+		return prerequisites
+	}
+	srcDir, err1 := filepath.Abs(base)
+	dstDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil || srcDir == dstDir {
+		return prerequisites
+	}
+	out := make([]string, len(prerequisites))
+	for i, p := range prerequisites {
+		p = resolvePrerequisite(srcDir, p)
+		if rel, err := filepath.Rel(dstDir, p); err == nil {
+			p = rel
+		}
+		out[i] = filepath.ToSlash(p)
+	}
+	return out
+}
+
+// specMetadata merges the metadata imported with the unit and the unit meta
+// that describes it (touchpoints, tags) plus its title.
+func specMetadata(u *domain.Unit) map[string]any {
+	out := map[string]any{}
+	if imported, ok := u.Meta[metaSpecMetadata].(map[string]any); ok {
+		for k, v := range imported {
+			out[k] = v
+		}
+	}
+	for _, k := range []string{"touchpoints", "tags"} {
+		if v, ok := u.Meta[k]; ok && v != nil {
+			out[k] = v
+		}
+	}
+	if u.Title != "" {
+		out["title"] = u.Title
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// marshalValidSpec renders spec as indented JSON, refusing specs that do not
+// conform to the published schema. Struct fields keep their declared order and
+// encoding/json sorts map keys, so the same run always renders the same bytes.
+func marshalValidSpec(spec *domain.Spec) ([]byte, error) {
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	if issues := domain.ValidateSpec(b); len(issues) > 0 {
+		return nil, specIssuesError(issues)
+	}
+	return b, nil
+}
+
+func specIssuesError(issues []domain.SpecIssue) error {
+	msgs := []string{}
+	for _, it := range issues {
+		msgs = append(msgs, it.String())
+	}
+	return NewAppError("invalid_spec", "spec does not match "+SpecSchemaID+": "+strings.Join(msgs, "; "))
+}
+
+// SpecValidate validates a hand-edited spec given by path or inline JSON.
+func (s *SyzygyService) SpecValidate(specPath string, specJSON string) (map[string]any, error) {
+	raw := []byte(specJSON)
+	if specPath != "" {
+		b, err := os.ReadFile(specPath)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	if len(raw) == 0 {
+		return nil, NewAppError("invalid_args", "spec_path or spec_json is required")
+	}
+
+	issues := domain.ValidateSpec(raw)
+	return map[string]any{
+		"valid":     len(issues) == 0,
+		"schema_id": SpecSchemaID,
+		"spec_path": specPath,
+		"issues":    issues,
+	}, nil
+}
@@ -0,0 +1,235 @@
+package application
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// maxPrerequisiteDepth mirrors the runner, which refuses deeper chains.
+const maxPrerequisiteDepth = 2
+
+// Unit meta keys written by spec import and read back by crystallize.
+const (
+	metaSourceSpecPath = "source_spec_path"
+	metaPrerequisites  = "prerequisites"
+	metaSpecMetadata   = "metadata"
+	// metaPrerequisiteDir is the directory prerequisites resolve from when they
+	// were not imported from a spec file, e.g. by gherkin import.
+	metaPrerequisiteDir = "prerequisite_dir"
+)
+
+// SpecImport turns a spec.json file, or every *.spec.json file of a directory,
+// into a unit with a fresh run. Invalid specs are reported and skipped.
+func (s *SyzygyService) SpecImport(projectKey string, path string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	if _, err := s.EnsureProjectInitialized(projectKey); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(path) == "" {
+		return nil, NewAppError("invalid_args", "path is required")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{abs}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(abs, "*.spec.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	results := []map[string]any{}
+	imported := 0
+	for _, f := range files {
+		res := s.importSpecFile(projectKey, f)
+		if ok, _ := res["ok"].(bool); ok {
+			imported++
+		}
+		results = append(results, res)
+	}
+	return map[string]any{
+		"imported": imported,
+		"failed":   len(files) - imported,
+		"results":  results,
+	}, nil
+}
+
+func (s *SyzygyService) importSpecFile(projectKey string, path string) map[string]any {
+	res := map[string]any{"path": path, "ok": false}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		res["error"] = err.Error()
+		return res
+	}
+	if issues := domain.ValidateSpec(raw); len(issues) > 0 {
+		res["error"] = "spec does not match " + SpecSchemaID
+		res["issues"] = issues
+		return res
+	}
+	var spec domain.Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		res["error"] = err.Error()
+		return res
+	}
+	res["unit_id"] = spec.UnitID
+
+	u, err := s.store.GetOrCreateUnit(projectKey, spec.UnitID, spec.Title, spec.Env)
+	if err != nil {
+		res["error"] = err.Error()
+		return res
+	}
+	if u.Meta == nil {
+		u.Meta = map[string]any{}
+	}
+	u.Meta[metaSourceSpecPath] = path
+	delete(u.Meta, metaPrerequisiteDir)
+	delete(u.Meta, metaPrerequisites)
+	delete(u.Meta, metaSpecMetadata)
+	if len(spec.Prerequisites) > 0 {
+		u.Meta[metaPrerequisites] = spec.Prerequisites
+	}
+	if len(spec.Metadata) > 0 {
+		u.Meta[metaSpecMetadata] = spec.Metadata
+		// touchpoints and tags drive impact planning, which reads them from unit meta.
+		for _, k := range []string{"touchpoints", "tags"} {
+			if v, ok := spec.Metadata[k]; ok && u.Meta[k] == nil {
+				u.Meta[k] = v
+			}
+		}
+	}
+
+	steps := spec.Steps
+	// Runs have no top-level net rules; the runner checks the net.must of every
+	// step for the whole replay, so a net-only step is equivalent.
+	if rules := spec.TopNetRules(); len(rules) > 0 {
+		st := &domain.ActionStep{Name: "net rules"}
+		if err := deepCopyJSON(domain.NetExpect{Must: rules}, &st.Net); err != nil {
+			res["error"] = err.Error()
+			return res
+		}
+		steps = append(append([]*domain.ActionStep{}, steps...), st)
+	}
+	run, err := forkRun(&domain.Run{
+		Variables: spec.Variables,
+		Steps:     steps,
+		Anchors:   spec.Anchors,
+		DBChecks:  spec.DBChecks,
+		Setup:     spec.Setup,
+		Teardown:  spec.Teardown,
+		Isolation: spec.Isolation,
+	})
+	if err != nil {
+		res["error"] = err.Error()
+		return res
+	}
+	run.Meta["imported_from"] = path
+
+	u.Runs = append(u.Runs, run)
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveUnit(projectKey, u); err != nil {
+		res["error"] = err.Error()
+		return res
+	}
+
+	res["ok"] = true
+	res["run_id"] = run.RunID
+	res["steps"] = len(run.Steps)
+	res["db_checks"] = len(run.DBChecks)
+	return res
+}
+
+// specPrerequisites returns the prerequisites recorded on the unit by import.
+func specPrerequisites(u *domain.Unit) []string {
+	switch v := u.Meta[metaPrerequisites].(type) {
+	case []string:
+		return v
+	case []any:
+		return toStringSliceAny(v)
+	default:
+		return nil
+	}
+}
+
+// prerequisiteKeys lists the anchors produced by the prerequisite specs of an
+// imported unit; the runner shares them with the unit's own steps.
+func prerequisiteKeys(u *domain.Unit) []string {
+	keys := []string{}
+	for _, spec := range prerequisiteSpecs(u) {
+		for k := range spec.Anchors {
+			keys = append(keys, k)
+		}
+		for _, list := range [][]*domain.ActionStep{spec.Setup, spec.Steps} {
+			for _, st := range list {
+				keys = append(keys, domain.StepDefines(st)...)
+			}
+		}
+	}
+	return keys
+}
+
+// prerequisiteSpecs loads the prerequisite specs of an imported unit in the
+// order the runner executes them, nested prerequisites first. Unreadable files
+// are skipped.
+func prerequisiteSpecs(u *domain.Unit) []*domain.Spec {
+	dir := prerequisiteDir(u)
+	if dir == "" {
+		return nil
+	}
+	out := []*domain.Spec{}
+	collectPrerequisiteSpecs(dir, specPrerequisites(u), 0, &out)
+	return out
+}
+
+// prerequisiteDir is the directory the prerequisites of a unit are relative
+// to: the one recorded by gherkin import, else that of the imported spec.
+func prerequisiteDir(u *domain.Unit) string {
+	if dir, _ := u.Meta[metaPrerequisiteDir].(string); dir != "" {
+		return dir
+	}
+	if source, _ := u.Meta[metaSourceSpecPath].(string); source != "" {
+		return filepath.Dir(source)
+	}
+	return ""
+}
+
+// resolvePrerequisite joins a prerequisite path to the directory it is
+// relative to; absolute paths are kept.
+func resolvePrerequisite(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+func collectPrerequisiteSpecs(dir string, prerequisites []string, depth int, out *[]*domain.Spec) {
+	if depth >= maxPrerequisiteDepth {
+		return
+	}
+	for _, rel := range prerequisites {
+		p := resolvePrerequisite(dir, rel)
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var spec domain.Spec
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			continue
+		}
+		collectPrerequisiteSpecs(filepath.Dir(p), spec.Prerequisites, depth+1, out)
+		*out = append(*out, &spec)
+	}
+}
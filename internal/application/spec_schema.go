@@ -0,0 +1,119 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+var (
+	SpecSchemaID  = fmt.Sprintf("https://github.com/cookchen233/syzygy-mcp-go/schemas/spec.v%d.json", domain.SpecSchemaVersion)
+	SpecSchemaURI = fmt.Sprintf("syzygy://schemas/spec.v%d.json", domain.SpecSchemaVersion)
+)
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+// SpecJSONSchema generates the JSON Schema of spec.json from domain.Spec and
+// the typed step ops, so the published schema never drifts from validation.
+func SpecJSONSchema() map[string]any {
+	defs := map[string]any{}
+	root := structSchema(reflect.TypeOf(domain.Spec{}), defs)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = SpecSchemaID
+	root["title"] = "Syzygy spec"
+	root["$defs"] = defs
+	return root
+}
+
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	if t == jsonNumberType {
+		return map[string]any{"type": []string{"number", "string"}}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		out := map[string]any{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			out["additionalProperties"] = typeSchema(t.Elem(), defs)
+		}
+		return out
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := defs[name]; !ok {
+			defs[name] = map[string]any{} // placeholder for recursive types
+			defs[name] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		props[name] = typeSchema(f.Type, defs)
+		if f.Tag.Get("schema") == "required" {
+			required = append(required, name)
+		}
+	}
+	if t == reflect.TypeOf(domain.ActionStep{}) {
+		for _, layer := range domain.StepLayers {
+			props[layer] = layerSchema(layer, defs)
+		}
+		expect := structSchema(reflect.TypeOf(domain.StepExpect{}), defs)
+		expect["properties"].(map[string]any)["console"] = map[string]any{"enum": []string{domain.ExpectConsoleNoError}}
+		props["expect"] = expect
+	}
+	out := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+// layerSchema is a oneOf over every op registered for the layer; a net layer
+// may also carry only net.must expectations.
+func layerSchema(layer string, defs map[string]any) map[string]any {
+	variants := []any{}
+	for _, name := range domain.StepOpNames(layer) {
+		op, _ := domain.NewStepOp(name)
+		s := structSchema(reflect.TypeOf(op).Elem(), defs)
+		s["properties"].(map[string]any)["op"] = map[string]any{"const": name}
+		req, _ := s["required"].([]string)
+		s["required"] = append([]string{"op"}, req...)
+		s["title"] = name
+		variants = append(variants, s)
+	}
+	if layer == "net" {
+		s := structSchema(reflect.TypeOf(domain.NetExpect{}), defs)
+		s["title"] = "net.must"
+		variants = append(variants, s)
+	}
+	return map[string]any{"oneOf": variants}
+}
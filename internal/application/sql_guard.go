@@ -0,0 +1,41 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// guardSQL parses the SQL of specs before they are crystallized or replayed:
+// DB checks must be a single SELECT, and db.exec steps routed to a protected
+// datasource must not write. Unknown datasources are left to the replay to
+// report.
+func guardSQL(datasources map[string]domain.Datasource, specs ...*domain.Spec) error {
+	for _, spec := range specs {
+		for _, c := range spec.DBChecks {
+			if err := domain.CheckSelectSQL(c.SQL); err != nil {
+				return NewAppError("unsafe_sql", fmt.Sprintf("%s: db check %s: %v", spec.UnitID, c.Name, err))
+			}
+		}
+		for _, list := range [][]*domain.ActionStep{spec.Setup, spec.Steps, spec.Teardown} {
+			for _, st := range list {
+				op, err := st.Op()
+				if err != nil {
+					continue
+				}
+				exec, ok := op.(*domain.DBExec)
+				if !ok {
+					continue
+				}
+				name, ds, ok, err := domain.ResolveDatasource(datasources, exec.DMS, spec.Env)
+				if err != nil || !ok || !ds.Protected {
+					continue
+				}
+				if err := domain.CheckReadOnlySQL(exec.SQL); err != nil {
+					return NewAppError("unsafe_sql", fmt.Sprintf("%s: db.exec step %s on protected datasource %s: %v", spec.UnitID, st.Name, name, err))
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package application
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// editRun loads a run, applies edit to it and saves the unit with a history
+// item describing the change. edit returns the tool result and history detail.
+func (s *SyzygyService) editRun(projectKey string, unitID, runID, action string, edit func(run *domain.Run) (map[string]any, map[string]any, error)) (map[string]any, error) {
+	return s.editUnitRun(projectKey, unitID, runID, action, func(_ *domain.Unit, run *domain.Run) (map[string]any, map[string]any, error) {
+		return edit(run)
+	})
+}
+
+// editUnitRun is editRun for edits that also change the unit, e.g. its meta.
+func (s *SyzygyService) editUnitRun(projectKey string, unitID, runID, action string, edit func(u *domain.Unit, run *domain.Run) (map[string]any, map[string]any, error)) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	u, err := s.store.GetUnit(projectKey, unitID)
+	if err != nil {
+		return nil, err
+	}
+	run, err := findRun(u, runID)
+	if err != nil {
+		return nil, err
+	}
+	if err := markRunEdited(run, action); err != nil {
+		return nil, err
+	}
+
+	result, detail, err := edit(u, run)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	u.History = append(u.History, &domain.HistoryItem{
+		At:     now,
+		RunID:  runID,
+		Action: action,
+		Detail: detail,
+	})
+	u.UpdatedAt = now
+	if err := s.store.SaveUnit(projectKey, u); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func findStepIndex(run *domain.Run, stepID string) (int, error) {
+	for i, st := range run.Steps {
+		if st.StepID == stepID {
+			return i, nil
+		}
+	}
+	return -1, NewAppError("step_not_found", fmt.Sprintf("step not found: %s", stepID))
+}
+
+func findDbCheckIndex(run *domain.Run, checkID string) (int, error) {
+	for i, c := range run.DBChecks {
+		if c.CheckID == checkID {
+			return i, nil
+		}
+	}
+	return -1, NewAppError("dbcheck_not_found", fmt.Sprintf("db check not found: %s", checkID))
+}
+
+// insertionIndex resolves a position ("before"/"after", default "after")
+// relative to the step at anchor into an index in the step list.
+func insertionIndex(anchor int, position string) (int, error) {
+	switch position {
+	case "", "after":
+		return anchor + 1, nil
+	case "before":
+		return anchor, nil
+	default:
+		return 0, NewAppError("invalid_args", "position must be before or after")
+	}
+}
+
+func insertStep(steps []*domain.ActionStep, at int, step *domain.ActionStep) []*domain.ActionStep {
+	steps = append(steps, nil)
+	copy(steps[at+1:], steps[at:])
+	steps[at] = step
+	return steps
+}
+
+// StepUpdate replaces the content of a step while keeping its StepID.
+func (s *SyzygyService) StepUpdate(projectKey string, unitID, runID, stepID string, step domain.ActionStep) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "step_update", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		i, err := findStepIndex(run, stepID)
+		if err != nil {
+			return nil, nil, err
+		}
+		before := run.Steps[i]
+		step.StepID = stepID
+		run.Steps[i] = &step
+		return map[string]any{"step_id": stepID, "index": i},
+			map[string]any{"step_id": stepID, "before": before, "after": &step}, nil
+	})
+}
+
+// StepInsert inserts a new step before or after an existing one.
+func (s *SyzygyService) StepInsert(projectKey string, unitID, runID, anchorStepID, position string, step domain.ActionStep) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "step_insert", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		anchor, err := findStepIndex(run, anchorStepID)
+		if err != nil {
+			return nil, nil, err
+		}
+		at, err := insertionIndex(anchor, position)
+		if err != nil {
+			return nil, nil, err
+		}
+		stepID, err := domain.NewID("step")
+		if err != nil {
+			return nil, nil, err
+		}
+		step.StepID = stepID
+		run.Steps = insertStep(run.Steps, at, &step)
+		return map[string]any{"step_id": stepID, "index": at},
+			map[string]any{"step_id": stepID, "anchor_step_id": anchorStepID, "position": position, "after": &step}, nil
+	})
+}
+
+// StepMove moves a step before or after another step.
+func (s *SyzygyService) StepMove(projectKey string, unitID, runID, stepID, anchorStepID, position string) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "step_move", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		if stepID == anchorStepID {
+			return nil, nil, NewAppError("invalid_args", "step_id and anchor_step_id must differ")
+		}
+		from, err := findStepIndex(run, stepID)
+		if err != nil {
+			return nil, nil, err
+		}
+		step := run.Steps[from]
+		run.Steps = append(run.Steps[:from], run.Steps[from+1:]...)
+
+		anchor, err := findStepIndex(run, anchorStepID)
+		if err != nil {
+			return nil, nil, err
+		}
+		at, err := insertionIndex(anchor, position)
+		if err != nil {
+			return nil, nil, err
+		}
+		run.Steps = insertStep(run.Steps, at, step)
+		return map[string]any{"step_id": stepID, "from_index": from, "to_index": at},
+			map[string]any{"step_id": stepID, "from_index": from, "to_index": at}, nil
+	})
+}
+
+func (s *SyzygyService) StepDelete(projectKey string, unitID, runID, stepID string) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "step_delete", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		i, err := findStepIndex(run, stepID)
+		if err != nil {
+			return nil, nil, err
+		}
+		before := run.Steps[i]
+		run.Steps = append(run.Steps[:i], run.Steps[i+1:]...)
+		return map[string]any{"ok": true, "step_id": stepID},
+			map[string]any{"step_id": stepID, "index": i, "before": before}, nil
+	})
+}
+
+// StepsReplace swaps the whole step list of a run in a single save.
+func (s *SyzygyService) StepsReplace(projectKey string, unitID, runID string, steps []domain.ActionStep) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "steps_replace", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		next, err := newSteps(steps)
+		if err != nil {
+			return nil, nil, err
+		}
+		stepIDs := make([]string, 0, len(next))
+		for _, st := range next {
+			stepIDs = append(stepIDs, st.StepID)
+		}
+		before := run.Steps
+		run.Steps = next
+		return map[string]any{"step_ids": stepIDs},
+			map[string]any{"before": before, "after": next}, nil
+	})
+}
+
+// FixturesSet replaces the setup and teardown steps and the isolation mode of
+// a run. Setup runs before the steps of every replay and teardown after them,
+// even when the replay fails.
+func (s *SyzygyService) FixturesSet(projectKey string, unitID, runID string, setup, teardown []domain.ActionStep, isolation string) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "fixtures_set", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		nextSetup, err := newSteps(setup)
+		if err != nil {
+			return nil, nil, err
+		}
+		nextTeardown, err := newSteps(teardown)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := domain.ValidateFixtures(nextSetup, run.Steps, nextTeardown, isolation); err != nil {
+			return nil, nil, NewAppError("invalid_args", err.Error())
+		}
+		before := map[string]any{"setup": run.Setup, "teardown": run.Teardown, "isolation": run.Isolation}
+		run.Setup, run.Teardown, run.Isolation = nextSetup, nextTeardown, isolation
+		after := map[string]any{"setup": run.Setup, "teardown": run.Teardown, "isolation": run.Isolation}
+		return map[string]any{"unit_id": unitID, "run_id": run.RunID, "setup": len(nextSetup), "teardown": len(nextTeardown), "isolation": isolation},
+			map[string]any{"before": before, "after": after}, nil
+	})
+}
+
+// newSteps copies steps with fresh step IDs.
+func newSteps(steps []domain.ActionStep) ([]*domain.ActionStep, error) {
+	out := make([]*domain.ActionStep, 0, len(steps))
+	for i := range steps {
+		stepID, err := domain.NewID("step")
+		if err != nil {
+			return nil, err
+		}
+		step := steps[i]
+		step.StepID = stepID
+		out = append(out, &step)
+	}
+	return out, nil
+}
+
+func (s *SyzygyService) DbCheckDelete(projectKey string, unitID, runID, checkID string) (map[string]any, error) {
+	return s.editRun(projectKey, unitID, runID, "dbcheck_delete", func(run *domain.Run) (map[string]any, map[string]any, error) {
+		i, err := findDbCheckIndex(run, checkID)
+		if err != nil {
+			return nil, nil, err
+		}
+		before := run.DBChecks[i]
+		run.DBChecks = append(run.DBChecks[:i], run.DBChecks[i+1:]...)
+		return map[string]any{"ok": true, "dbcheck_id": checkID},
+			map[string]any{"dbcheck_id": checkID, "index": i, "before": before}, nil
+	})
+}
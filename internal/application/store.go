@@ -7,5 +7,8 @@ type Store interface {
 	GetUnit(projectKey string, unitID string) (*domain.Unit, error)
 	SaveUnit(projectKey string, u *domain.Unit) error
 	ListUnitIDs(projectKey string) ([]string, error)
+	ReadProjectConfig(projectKey string) ([]byte, error)
+	WriteProjectConfig(projectKey string, b []byte) (string, error)
+	Migrate(projectKey string) ([]map[string]any, error)
 	BaseDir() string
 }
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"strings"
 	"time"
@@ -16,15 +18,18 @@ type AgentResult struct {
 }
 
 type SyzygyService struct {
-	store  Store
-	logger *log.Logger
+	store    Store
+	executor SpecExecutor
+	logger   *log.Logger
 }
 
-func NewSyzygyService(store Store, logger *log.Logger) *SyzygyService {
+// NewSyzygyService creates the service; a nil executor replays everything
+// through the Node runner.
+func NewSyzygyService(store Store, executor SpecExecutor, logger *log.Logger) *SyzygyService {
 	if logger == nil {
 		logger = log.Default()
 	}
-	return &SyzygyService{store: store, logger: logger}
+	return &SyzygyService{store: store, executor: executor, logger: logger}
 }
 
 func (s *SyzygyService) UnitStart(projectKey string, unitID, title string, env map[string]any, variables map[string]any) (map[string]any, error) {
@@ -45,7 +50,7 @@ func (s *SyzygyService) UnitStart(projectKey string, unitID, title string, env m
 
 	run := &domain.Run{
 		RunID:     runID,
-		Status:    "in_progress",
+		Status:    domain.RunStatusInProgress,
 		Variables: variables,
 		Steps:     []*domain.ActionStep{},
 		Anchors:   map[string]string{},
@@ -64,7 +69,7 @@ func (s *SyzygyService) UnitStart(projectKey string, unitID, title string, env m
 	return map[string]any{"unit_id": unitID, "run_id": runID}, nil
 }
 
-func (s *SyzygyService) ProjectInit(projectKey string, env map[string]any, runnerCommand string, runnerDir string, artifactsDir string) (map[string]any, error) {
+func (s *SyzygyService) ProjectInit(projectKey string, env map[string]any, runnerCommand string, runnerDir string, artifactsDir string, specsDir string, templatesDir string, datasources map[string]any) (map[string]any, error) {
 	projectKey = defaultProjectKey(projectKey)
 	cfg := &ProjectConfig{
 		ProjectKey:    projectKey,
@@ -72,10 +77,25 @@ func (s *SyzygyService) ProjectInit(projectKey string, env map[string]any, runne
 		RunnerCommand: normalizeRunnerCommand(runnerCommand),
 		RunnerDir:     strings.TrimSpace(runnerDir),
 		ArtifactsDir:  strings.TrimSpace(artifactsDir),
+		SpecsDir:      strings.TrimSpace(specsDir),
+		TemplatesDir:  strings.TrimSpace(templatesDir),
 	}
 	for k, v := range env {
 		cfg.Env[k] = anyToString(v)
 	}
+	if len(datasources) > 0 {
+		b, _ := json.Marshal(datasources)
+		if err := json.Unmarshal(b, &cfg.Datasources); err != nil {
+			return nil, NewAppError("invalid_args", fmt.Sprintf("datasources: %v", err))
+		}
+		for name, ds := range cfg.Datasources {
+			if err := ds.Validate(); err != nil {
+				return nil, NewAppError("invalid_args", fmt.Sprintf("datasource %s: %v", name, err))
+			}
+			ds.Driver = domain.NormalizeDriver(ds.Driver)
+			cfg.Datasources[name] = ds
+		}
+	}
 	if cfg.RunnerCommand == "" {
 		cfg.RunnerCommand = "syzygy-runner"
 	}
@@ -90,6 +110,26 @@ func (s *SyzygyService) ProjectInit(projectKey string, env map[string]any, runne
 	}, nil
 }
 
+// StoreMigrate upgrades persisted units and project configs to the latest schema
+// version. An empty projectKey migrates every project under the store.
+func (s *SyzygyService) StoreMigrate(projectKey string) (map[string]any, error) {
+	files, err := s.store.Migrate(strings.TrimSpace(projectKey))
+	if err != nil {
+		return nil, NewAppError("migrate_failed", err.Error())
+	}
+	migrated := 0
+	for _, f := range files {
+		if ok, _ := f["migrated"].(bool); ok {
+			migrated++
+		}
+	}
+	return map[string]any{
+		"ok":       true,
+		"files":    files,
+		"migrated": migrated,
+	}, nil
+}
+
 func defaultProjectKey(projectKey string) string {
 	projectKey = strings.TrimSpace(projectKey)
 	if projectKey == "" {
@@ -109,6 +149,9 @@ func (s *SyzygyService) StepAppend(projectKey string, unitID, runID string, step
 	if err != nil {
 		return nil, err
 	}
+	if err := markRunEdited(run, "step appended"); err != nil {
+		return nil, err
+	}
 
 	stepID, err := domain.NewID("step")
 	if err != nil {
@@ -134,6 +177,9 @@ func (s *SyzygyService) AnchorSet(projectKey string, unitID, runID, key, value,
 	if err != nil {
 		return nil, err
 	}
+	if err := markRunEdited(run, "anchor set"); err != nil {
+		return nil, err
+	}
 	if run.Anchors == nil {
 		run.Anchors = map[string]string{}
 	}
@@ -152,6 +198,12 @@ func (s *SyzygyService) AnchorSet(projectKey string, unitID, runID, key, value,
 
 func (s *SyzygyService) DbCheckAppend(projectKey string, unitID, runID string, check domain.DbCheck) (map[string]any, error) {
 	projectKey = defaultProjectKey(projectKey)
+	if err := check.Validate(); err != nil {
+		return nil, NewAppError("invalid_db_check", err.Error())
+	}
+	if err := domain.CheckSelectSQL(check.SQL); err != nil {
+		return nil, NewAppError("unsafe_sql", err.Error())
+	}
 	u, err := s.store.GetUnit(projectKey, unitID)
 	if err != nil {
 		return nil, err
@@ -160,6 +212,9 @@ func (s *SyzygyService) DbCheckAppend(projectKey string, unitID, runID string, c
 	if err != nil {
 		return nil, err
 	}
+	if err := markRunEdited(run, "db check appended"); err != nil {
+		return nil, err
+	}
 
 	checkID, err := domain.NewID("db")
 	if err != nil {
@@ -204,6 +259,23 @@ func (s *SyzygyService) SetUnitMeta(projectKey string, unitID string, meta map[s
 	return map[string]any{"ok": true}, nil
 }
 
+// projectUnits loads every readable unit of a project in unit id order.
+func (s *SyzygyService) projectUnits(projectKey string) ([]*domain.Unit, error) {
+	ids, err := s.store.ListUnitIDs(projectKey)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]*domain.Unit, 0, len(ids))
+	for _, id := range ids {
+		u, err := s.store.GetUnit(projectKey, id)
+		if err != nil {
+			continue
+		}
+		units = append(units, u)
+	}
+	return units, nil
+}
+
 func findRun(u *domain.Unit, runID string) (*domain.Run, error) {
 	for _, r := range u.Runs {
 		if r.RunID == runID {
@@ -233,12 +305,17 @@ func (s *SyzygyService) SelfCheck(projectKey string, unitID, runID string) (map[
 	runStatusCheck := map[string]any{
 		"name":     "run_status",
 		"category": "development",
-		"passed":   run.Status != "",
-		"message":  "Run status is set",
+		"passed":   true,
+		"status":   run.Status,
+		"message":  "Run status is " + run.Status,
 	}
-	if run.Status == "" {
+	if !domain.IsKnownRunStatus(run.Status) {
+		runStatusCheck["passed"] = false
+		runStatusCheck["message"] = "❌ Run status is unknown: " + run.Status
+		allPassed = false
+	} else if run.Status == domain.RunStatusAbandoned {
 		runStatusCheck["passed"] = false
-		runStatusCheck["message"] = "Run status is empty"
+		runStatusCheck["message"] = "❌ Run has been abandoned"
 		allPassed = false
 	}
 	checks = append(checks, runStatusCheck)
@@ -328,6 +405,21 @@ func (s *SyzygyService) SelfCheck(projectKey string, unitID, runID string) (map[
 	}
 	checks = append(checks, alignmentCheck)
 
+	// 4.2.1 变量解析检查 - 静态检查 ${var} 占位符是否都有定义
+	varIssues := domain.AnalyzeVariables(u.Env, run, prerequisiteKeys(u))
+	variablesCheck := map[string]any{
+		"name":     "variables_resolved",
+		"category": "development",
+		"passed":   len(varIssues) == 0,
+		"message":  "✅ All ${var} placeholders are defined before use",
+	}
+	if len(varIssues) > 0 {
+		variablesCheck["message"] = fmt.Sprintf("❌ %d ${var} placeholder(s) undefined or used before defined", len(varIssues))
+		variablesCheck["issues"] = varIssues
+		allPassed = false
+	}
+	checks = append(checks, variablesCheck)
+
 	// 4.3.4 性能记录检查 - 检查是否有超时相关记录
 	hasPerformanceRecord := false
 	if perfData, exists := run.Meta["performance"]; exists && perfData != nil {
@@ -377,6 +469,15 @@ func (s *SyzygyService) SelfCheck(projectKey string, unitID, runID string) (map[
 
 	if allPassed {
 		result["summary"] = "🟢 SYZYGY SELFCHECK PASSED - All checks completed successfully"
+		if run.Status == domain.RunStatusReplayPassed {
+			if err := transitionRun(run, domain.RunStatusAccepted, "selfcheck passed"); err != nil {
+				return nil, err
+			}
+			u.UpdatedAt = time.Now().UTC()
+			if err := s.store.SaveUnit(projectKey, u); err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		failedChecks := []string{}
 		for _, c := range checks {
@@ -387,5 +488,7 @@ func (s *SyzygyService) SelfCheck(projectKey string, unitID, runID string) (map[
 		result["summary"] = "🔴 SYZYGY SELFCHECK FAILED - Failed checks: " + strings.Join(failedChecks, ", ")
 	}
 
+	result["run_status"] = run.Status
+
 	return result, nil
 }
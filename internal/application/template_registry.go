@@ -0,0 +1,240 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// userTemplateExt marks text/template files in a project's templates_dir. The
+// file name without the extension is both the template name and the file it
+// writes, e.g. checklist.md.tmpl renders checklist.md.
+const userTemplateExt = ".tmpl"
+
+// TemplateData is what every crystallize template renders from.
+type TemplateData struct {
+	Unit          *domain.Unit
+	Run           *domain.Run
+	Config        *ProjectConfig
+	Spec          *domain.Spec
+	Prerequisites []*domain.Spec
+	// Units are all units of the project, for project-level outputs.
+	Units     []*domain.Unit
+	OutputDir string
+	RootDir   string
+}
+
+func (d *TemplateData) datasources() map[string]domain.Datasource {
+	if d.Config == nil {
+		return nil
+	}
+	return d.Config.Datasources
+}
+
+// generatedDatasources rejects specs a generated test cannot run: it reaches
+// one MySQL database through MYSQL_*, so every db.exec step and DB check must
+// use the default datasource, which must be MySQL when the project has one.
+func generatedDatasources(template string, datasources map[string]domain.Datasource, specs []*domain.Spec) error {
+	for _, u := range domain.DatasourceUses(specs...) {
+		name := u.DMS
+		if name == "" {
+			name, _ = u.Spec.Env["dms"].(string)
+		}
+		if name != "" && name != domain.DefaultDatasource {
+			return fmt.Errorf("%s: %s: datasource %s is not supported by %s, which only reaches MYSQL_*; replay it with syzygy_replay", u.Spec.UnitID, u.Where, name, template)
+		}
+		if ds, ok := datasources[domain.DefaultDatasource]; ok && domain.NormalizeDriver(ds.Driver) != domain.DriverMySQL {
+			return fmt.Errorf("%s: %s: the default datasource is %s; %s only reaches MySQL through MYSQL_*; replay it with syzygy_replay", u.Spec.UnitID, u.Where, domain.NormalizeDriver(ds.Driver), template)
+		}
+	}
+	return nil
+}
+
+// TemplateOutput is one file produced by a template, relative to the output
+// directory (or to the artifacts root when Root is set), recorded in run
+// artifacts under Artifact.
+type TemplateOutput struct {
+	Artifact string
+	File     string
+	Content  []byte
+	Root     bool
+}
+
+// CrystallizeTemplate renders artifacts of a run into its output directory.
+type CrystallizeTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Source      string `json:"source"`
+	Error       string `json:"error,omitempty"`
+
+	Render func(d *TemplateData) ([]TemplateOutput, error) `json:"-"`
+}
+
+// templateSpecJSON is the default template; it is the only one that writes
+// spec.json and crystallizes the run.
+const templateSpecJSON = "spec_json"
+
+var builtinTemplates = []*CrystallizeTemplate{
+	{
+		Name:        templateSpecJSON,
+		Source:      "builtin",
+		Description: "spec.json; crystallizes the run (other templates only render their artifacts)",
+		Render:      func(d *TemplateData) ([]TemplateOutput, error) { return nil, nil },
+	},
+	{
+		Name:        "playwright_ts",
+		Source:      "builtin",
+		Description: "Standalone Playwright test (e2e.spec.ts) runnable with npx playwright test",
+		Render: func(d *TemplateData) ([]TemplateOutput, error) {
+			ts, err := renderPlaywrightTS(d.Spec, d.Prerequisites, d.datasources())
+			if err != nil {
+				return nil, err
+			}
+			return []TemplateOutput{{Artifact: "playwright_ts", File: "e2e.spec.ts", Content: []byte(ts)}}, nil
+		},
+	},
+	{
+		Name:        "go_test",
+		Source:      "builtin",
+		Description: "Go test (<unit>_test.go + " + goTestHelpersFile + ") for units with only db.*, net.call and util.* steps",
+		Render: func(d *TemplateData) ([]TemplateOutput, error) {
+			return renderGoTest(d.Spec, d.Prerequisites, d.datasources())
+		},
+	},
+	{
+		Name:        "postman",
+		Source:      "builtin",
+		Description: "Postman v2.1 collection (<unit>.postman_collection.json) and environment from the project env, for units with only net.call and util.* steps",
+		Render: func(d *TemplateData) ([]TemplateOutput, error) {
+			return renderPostman(d.Spec, d.Prerequisites, d.Config)
+		},
+	},
+	{
+		Name:        "gherkin",
+		Source:      "builtin",
+		Description: "Gherkin feature file (<unit>.feature) that syzygy_gherkin_import reads back",
+		Render: func(d *TemplateData) ([]TemplateOutput, error) {
+			// Prerequisites resolve from the feature file, as gherkin import reads them.
+			spec := *d.Spec
+			spec.Prerequisites = rebasePrerequisites(d.Unit, d.OutputDir)
+			feature, err := renderGherkin(&spec)
+			if err != nil {
+				return nil, err
+			}
+			return []TemplateOutput{{Artifact: "gherkin", File: d.Unit.UnitID + ".feature", Content: []byte(feature)}}, nil
+		},
+	},
+	{
+		Name:        "markdown",
+		Source:      "builtin",
+		Description: "Markdown documentation (<unit>.md) plus a project " + docsIndexMarkdown + " in the artifacts root",
+		Render: func(d *TemplateData) ([]TemplateOutput, error) {
+			return renderDocs(d, false)
+		},
+	},
+	{
+		Name:        "markdown_html",
+		Source:      "builtin",
+		Description: "Markdown and HTML documentation (<unit>.md, <unit>.html) plus " + docsIndexMarkdown + " and " + docsIndexHTML + " in the artifacts root",
+		Render: func(d *TemplateData) ([]TemplateOutput, error) {
+			return renderDocs(d, true)
+		},
+	},
+}
+
+// loadTemplates returns the built-in templates followed by the user templates
+// of the project in file name order. User templates that fail to parse or clash
+// with a built-in are listed with Error set and cannot be used.
+func loadTemplates(cfg *ProjectConfig) []*CrystallizeTemplate {
+	out := append([]*CrystallizeTemplate{}, builtinTemplates...)
+	if cfg == nil || strings.TrimSpace(cfg.TemplatesDir) == "" {
+		return out
+	}
+	files, _ := filepath.Glob(filepath.Join(strings.TrimSpace(cfg.TemplatesDir), "*"+userTemplateExt))
+	sort.Strings(files)
+	for _, f := range files {
+		t := userTemplate(f)
+		for _, b := range builtinTemplates {
+			if b.Name == t.Name {
+				t.Error = "name is reserved by a built-in template"
+			}
+		}
+		if t.Name == "spec.json" {
+			t.Error = "spec.json is written by the " + templateSpecJSON + " template"
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func findTemplate(cfg *ProjectConfig, name string) (*CrystallizeTemplate, error) {
+	names := []string{}
+	for _, t := range loadTemplates(cfg) {
+		if t.Name == name {
+			if t.Error != "" {
+				return nil, NewAppError("invalid_template", fmt.Sprintf("template %s (%s): %s", name, t.Source, t.Error))
+			}
+			return t, nil
+		}
+		if t.Error == "" {
+			names = append(names, t.Name)
+		}
+	}
+	return nil, NewAppError("unknown_template", fmt.Sprintf("unknown template %q; available: %s", name, strings.Join(names, ", ")))
+}
+
+// templateFuncs are available to user templates.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		return string(b), err
+	},
+	"join": strings.Join,
+}
+
+func userTemplate(path string) *CrystallizeTemplate {
+	name := strings.TrimSuffix(filepath.Base(path), userTemplateExt)
+	t := &CrystallizeTemplate{
+		Name:        name,
+		Description: "User template " + filepath.Base(path),
+		Source:      path,
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	tpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	t.Render = func(d *TemplateData) ([]TemplateOutput, error) {
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, d); err != nil {
+			return nil, err
+		}
+		return []TemplateOutput{{Artifact: name, File: name, Content: buf.Bytes()}}, nil
+	}
+	return t
+}
+
+// TemplatesList lists the crystallize templates available to a project.
+func (s *SyzygyService) TemplatesList(projectKey string) (map[string]any, error) {
+	projectKey = defaultProjectKey(projectKey)
+	cfg, err := s.EnsureProjectInitialized(projectKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"templates_dir": cfg.TemplatesDir,
+		"templates":     loadTemplates(cfg),
+	}, nil
+}
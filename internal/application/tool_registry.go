@@ -30,6 +30,20 @@ func (r *ToolRegistry) ListTools() []ToolDefinition {
 					"runner_command": map[string]any{"type": "string"},
 					"runner_dir":     map[string]any{"type": "string"},
 					"artifacts_dir":  map[string]any{"type": "string"},
+					"specs_dir":      map[string]any{"type": "string", "description": "crystallize writes <specs_dir>/<unit_id>.spec.json with stable key order; overwriting returns spec_diff"},
+					"templates_dir":  map[string]any{"type": "string", "description": "user templates: *.tmpl (Go text/template over .Unit/.Run/.Config/.Spec/.Units); name.md.tmpl renders name.md"},
+					"datasources":    map[string]any{"type": "object", "description": "name -> {driver: mysql|postgres|sqlite, dsn, read_only, protected, time_zone}; sqlite needs cgo; ${VAR} in dsn comes from the replay env; read_only rejects db.exec, protected only allows reading db.exec steps; time_zone (default: the database session zone) reads zone-less timestamps for within; DB checks and db.exec pick one by dms, then the spec env dms, then \"default\"; :name params bind as $n on postgres; without datasources MYSQL_* is used, with them the node runner gets MYSQL_* from the one MySQL datasource it uses"},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			Name:        "syzygy_store_migrate",
+			Description: "Migrate stored units and project configs to the latest schema version (迁移存储文件到最新 schema 版本)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string", "description": "empty = all projects"},
 				},
 				"required": []string{},
 			},
@@ -49,6 +63,21 @@ func (r *ToolRegistry) ListTools() []ToolDefinition {
 				"required": []string{"unit_id"},
 			},
 		},
+		{
+			Name:        "syzygy_run_fork",
+			Description: "Fork a new run from an existing run, optionally of another unit (从已有 run 派生新 run)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key":    map[string]any{"type": "string"},
+					"unit_id":        map[string]any{"type": "string"},
+					"title":          map[string]any{"type": "string", "description": "title of unit_id when the fork creates it"},
+					"source_unit_id": map[string]any{"type": "string"},
+					"source_run_id":  map[string]any{"type": "string"},
+				},
+				"required": []string{"unit_id"},
+			},
+		},
 		{
 			Name:        "syzygy_unit_meta_set",
 			Description: "Set unit meta (设置单元元数据/触点)",
@@ -157,36 +186,296 @@ func (r *ToolRegistry) ListTools() []ToolDefinition {
 		},
 		{
 			Name:        "syzygy_dbcheck_append",
-			Description: "Append a DB check (追加数据库断言)",
+			Description: "Append a DB check; assert values are strings (equality, not_null, not_empty) or {op, value|values, path} objects, with rows (first|all|any), row_count and expect_rows (追加数据库断言)",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"project_key": map[string]any{"type": "string"},
 					"unit_id":  map[string]any{"type": "string"},
 					"run_id":   map[string]any{"type": "string"},
-					"db_check": map[string]any{"type": "object"},
+					"db_check": map[string]any{"type": "object", "description": "{name, dms, sql, params, assert, rows, row_count, expect_rows, retry_attempts, retry_interval_ms}; sql must be a single read-only SELECT or WITH ... SELECT (unsafe_sql otherwise); assert ops: eq, ne, gt, gte, lt, lte, between (values: [min, max]), in, not_in, regex, null, within (e.g. \"60s\" before the run started); path reads a JSON column by JSONPath; the node runner skips the extended forms and the go engine evaluates them after it"},
 				},
 				"required": []string{"unit_id", "run_id", "db_check"},
 			},
 		},
+		{
+			Name:        "syzygy_step_update",
+			Description: "Replace the content of a step by step_id (按 step_id 修改步骤)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"step_id":     map[string]any{"type": "string"},
+					"step":        map[string]any{"type": "object"},
+				},
+				"required": []string{"unit_id", "run_id", "step_id", "step"},
+			},
+		},
+		{
+			Name:        "syzygy_step_insert",
+			Description: "Insert a step before/after an existing step (在指定步骤前/后插入步骤)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key":    map[string]any{"type": "string"},
+					"unit_id":        map[string]any{"type": "string"},
+					"run_id":         map[string]any{"type": "string"},
+					"anchor_step_id": map[string]any{"type": "string"},
+					"position":       map[string]any{"type": "string", "enum": []string{"before", "after"}},
+					"step":           map[string]any{"type": "object"},
+				},
+				"required": []string{"unit_id", "run_id", "anchor_step_id", "step"},
+			},
+		},
+		{
+			Name:        "syzygy_step_move",
+			Description: "Move a step before/after another step (移动步骤位置)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key":    map[string]any{"type": "string"},
+					"unit_id":        map[string]any{"type": "string"},
+					"run_id":         map[string]any{"type": "string"},
+					"step_id":        map[string]any{"type": "string"},
+					"anchor_step_id": map[string]any{"type": "string"},
+					"position":       map[string]any{"type": "string", "enum": []string{"before", "after"}},
+				},
+				"required": []string{"unit_id", "run_id", "step_id", "anchor_step_id"},
+			},
+		},
+		{
+			Name:        "syzygy_step_delete",
+			Description: "Delete a step by step_id (删除步骤)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"step_id":     map[string]any{"type": "string"},
+				},
+				"required": []string{"unit_id", "run_id", "step_id"},
+			},
+		},
+		{
+			Name:        "syzygy_steps_replace",
+			Description: "Replace the full step list of a run atomically (整体替换步骤列表)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"steps": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"type": "object"},
+					},
+				},
+				"required": []string{"unit_id", "run_id", "steps"},
+			},
+		},
+		{
+			Name:        "syzygy_fixtures_set",
+			Description: "Set setup/teardown steps and isolation of a run; teardown runs even when the replay fails (设置前置/清理步骤与隔离模式)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"setup": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "object"},
+						"description": "steps without ui run before the steps; the node runner gets their anchors through SYZYGY_ANCHORS",
+					},
+					"teardown": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "object"},
+						"description": "steps without ui run in reverse order after the replay, even when it fails; reported under teardown without affecting ok, and see the anchors of setup and the runner",
+					},
+					"isolation": map[string]any{"type": "string", "enum": []string{"", domain.IsolationRollback}, "description": "rollback replays units with only db.* / util.* steps in one transaction per datasource that is rolled back (go engine only); test templates reject it"},
+				},
+				"required": []string{"unit_id", "run_id"},
+			},
+		},
+		{
+			Name:        "syzygy_dbcheck_delete",
+			Description: "Delete a DB check by dbcheck_id (删除数据库断言)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"dbcheck_id":  map[string]any{"type": "string"},
+				},
+				"required": []string{"unit_id", "run_id", "dbcheck_id"},
+			},
+		},
+		{
+			Name:        "syzygy_dbcheck_run",
+			Description: "Run one or all DB checks of a run now, without its steps, against the configured datasource, with ${var} from the run's variables / anchors and the unit env; returns the bound SQL, up to 100 rows, per-field results and warnings for unresolved ${var} (立即执行数据库断言)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"dbcheck_id":  map[string]any{"type": "string", "description": "empty = all checks of the run"},
+					"env":         map[string]any{"type": "object"},
+				},
+				"required": []string{"unit_id"},
+			},
+		},
+		{
+			Name:        "syzygy_dbcheck_suggest",
+			Description: "Suggest DB checks from the row-level diff of a snapshot replay, locating rows by columns holding an anchor value where possible and by primary key otherwise (根据回放前后的数据差异建议数据库断言)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"path":        map[string]any{"type": "string", "description": "db-diff.json; default: the run's db_diff artifact"},
+				},
+				"required": []string{"unit_id"},
+			},
+		},
 		{
 			Name:        "syzygy_crystallize",
-			Description: "Generate artifacts (生成固化产物)",
+			Description: "Generate artifacts; the default spec_json template writes <unit_id>.spec.json (to specs_dir when configured) and crystallizes the run, other templates only render their artifacts; dry_run previews (生成固化产物)",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"project_key": map[string]any{"type": "string"},
 					"unit_id":    map[string]any{"type": "string"},
 					"run_id":     map[string]any{"type": "string"},
-					"template":   map[string]any{"type": "string"},
+					"template":   map[string]any{"type": "string", "description": "spec_json (default); playwright_ts (e2e.spec.ts for npx playwright test, needs @playwright/test, jsonpath-plus, mysql2); go_test (<unit>_test.go in package e2e for units with only db.* / net.call / util.* steps, MySQL via github.com/go-sql-driver/mysql); postman (v2.1 collection and environment for units with only net.call / util.* steps); gherkin (<unit>.feature, readable by syzygy_gherkin_import); markdown / markdown_html (<unit>.md and index.md, plus .html); or a templates_dir template. Test templates reach the database through MYSQL_* only, refuse named or non-MySQL datasources, extended DB assertions and isolation, and use SYZYGY_AUTH_TOKEN for bearer auth"},
 					"output_dir": map[string]any{"type": "string"},
+					"dry_run":    map[string]any{"type": "boolean", "description": "preview without writing"},
 				},
 				"required": []string{"unit_id", "run_id"},
 			},
 		},
+		{
+			Name:        "syzygy_templates_list",
+			Description: "List crystallize templates: built-in and project templates_dir (列出固化模板)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			Name:        "syzygy_spec_schema",
+			Description: "Get the versioned JSON Schema of spec.json (获取 spec.json 的 JSON Schema)",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+				"required":   []string{},
+			},
+		},
+		{
+			Name:        "syzygy_spec_validate",
+			Description: "Validate a spec.json file or JSON string against the spec schema (校验 spec.json)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"spec_path": map[string]any{"type": "string"},
+					"spec_json": map[string]any{"type": "string"},
+				},
+				"required": []string{},
+			},
+		},
+		{
+			Name:        "syzygy_spec_import",
+			Description: "Import a spec.json file or a directory of *.spec.json into units and runs (导入已有 spec 文件为单元)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"path":        map[string]any{"type": "string"},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "syzygy_gherkin_import",
+			Description: "Import a Gherkin feature (template=gherkin dialect) as a run skeleton; free-text When steps become steps without op and other unrecognised lines are returned as unmapped (导入 Gherkin 为运行骨架)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"path":        map[string]any{"type": "string", "description": "required for features with prerequisites, whose paths are relative to the .feature file"},
+					"content":     map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+				},
+			},
+		},
+		{
+			Name:        "syzygy_har_import",
+			Description: "Import JSON requests of a HAR file as net.call steps (mode=call) or net.must rules (mode=must); expect_json comes from stable top-level response fields, response ids become anchors reused by later requests, volatile request values become variables (导入 HAR 为网络步骤/期望)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key":  map[string]any{"type": "string"},
+					"unit_id":      map[string]any{"type": "string"},
+					"run_id":       map[string]any{"type": "string"},
+					"path":         map[string]any{"type": "string"},
+					"url_contains": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"methods":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"mode":         map[string]any{"type": "string", "enum": []string{"must", "call"}},
+					"step_id":      map[string]any{"type": "string", "description": "mode=must: add the rules to this step instead of a new one"},
+				},
+				"required": []string{"unit_id", "path"},
+			},
+		},
+		{
+			Name:        "syzygy_openapi_operations",
+			Description: "List the operations (operation id, method, path, summary) of a local OpenAPI 3 JSON document and the base path of its first server (列出 OpenAPI 文档中的接口)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string"},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "syzygy_openapi_step_append",
+			Description: "Append a net.call step for an OpenAPI operation and add its path to touchpoints.api: path and required query/header params become ${var}, the body is a skeleton of the request schema, the first 2xx response sets status, required response fields with a fixed value become expect_jsonpath and the others expect_present, and the first required id becomes an anchor; JSON documents only (按 OpenAPI 接口追加网络步骤)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key":  map[string]any{"type": "string"},
+					"unit_id":      map[string]any{"type": "string"},
+					"run_id":       map[string]any{"type": "string"},
+					"path":         map[string]any{"type": "string"},
+					"operation_id": map[string]any{"type": "string"},
+				},
+				"required": []string{"unit_id", "path", "operation_id"},
+			},
+		},
+		{
+			Name:        "syzygy_contract_check",
+			Description: "Check the net.call / net.must rules of the latest run of every unit against a local OpenAPI 3 JSON document: unknown_path, wrong_method, removed_field (fields referenced by expect_json / expect_jsonpath / anchors) and status_changed; url_contains matches any part of a documented path, free-form objects and filtered JSONPaths are not checked (对照 OpenAPI 检查接口契约漂移)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"path":        map[string]any{"type": "string"},
+					"unit_ids":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "default: every unit"},
+				},
+				"required": []string{"path"},
+			},
+		},
 		{
 			Name:        "syzygy_replay",
-			Description: "Replay a crystallized unit (回放固化用例)",
+			Description: "Replay a crystallized unit; units whose spec and prerequisites have no ui steps run in-process with the runner's substitution, retry and assertion semantics unless engine=node. With the node runner, fixtures and extended DB checks run in the go engine around it (回放固化用例)",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -200,6 +489,23 @@ func (r *ToolRegistry) ListTools() []ToolDefinition {
 					},
 					"cwd": map[string]any{"type": "string"},
 					"env": map[string]any{"type": "object"},
+					"engine": map[string]any{"type": "string", "enum": []string{ReplayEngineAuto, ReplayEngineNode, ReplayEngineGo}, "description": "node forces the node runner; go fails instead of falling back to it"},
+					"snapshot": map[string]any{"type": "boolean", "description": "snapshot touchpoints.db_tables ([datasource:]table) before the replay and before teardown and record a row-level db_diff artifact; tables are read newest primary key first up to 10000 rows, past the limit only the key range both snapshots read is compared, and a table without a primary key or an alignable range reports an error"},
+				},
+				"required": []string{"unit_id", "run_id"},
+			},
+		},
+		{
+			Name:        "syzygy_run_finish",
+			Description: "Finish a run as accepted (requires passed replay) or abandoned (结束 run：验收或放弃)",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"project_key": map[string]any{"type": "string"},
+					"unit_id":     map[string]any{"type": "string"},
+					"run_id":      map[string]any{"type": "string"},
+					"status":      map[string]any{"type": "string", "enum": []string{"accepted", "abandoned"}},
+					"reason":      map[string]any{"type": "string"},
 				},
 				"required": []string{"unit_id", "run_id"},
 			},
@@ -253,27 +559,50 @@ func matchesAny(hay string, needles []string) bool {
 	return false
 }
 
-func parseActionStepFromMap(stepRaw map[string]any) domain.ActionStep {
-	step := domain.ActionStep{}
-	if v, ok := stepRaw["name"].(string); ok {
-		step.Name = v
+// resolveRunID falls back to the latest run of the unit when runID is empty.
+func (r *ToolRegistry) resolveRunID(projectKey, unitID, runID string) string {
+	if runID != "" {
+		return runID
 	}
-	if v, ok := stepRaw["util"].(map[string]any); ok {
-		step.Util = v
+	u, err := r.svc.GetUnit(projectKey, unitID)
+	if err != nil {
+		return ""
 	}
-	if v, ok := stepRaw["db"].(map[string]any); ok {
-		step.DB = v
+	return latestRunID(u)
+}
+
+// parseStepList parses an optional step list such as steps, setup or teardown.
+func parseStepList(raw any, name string) ([]domain.ActionStep, error) {
+	steps := []domain.ActionStep{}
+	if raw == nil {
+		return steps, nil
 	}
-	if v, ok := stepRaw["ui"].(map[string]any); ok {
-		step.UI = v
+	arr, ok := raw.([]any)
+	if !ok {
+		return nil, NewAppError("invalid_steps", name+" must be array")
 	}
-	if v, ok := stepRaw["net"].(map[string]any); ok {
-		step.Net = v
+	for _, it := range arr {
+		m, ok := it.(map[string]any)
+		if !ok {
+			return nil, NewAppError("invalid_steps", "each step in "+name+" must be object")
+		}
+		step, err := parseActionStepFromMap(m)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
 	}
-	if v, ok := stepRaw["expect"].(map[string]any); ok {
-		step.Expect = v
+	return steps, nil
+}
+
+// parseActionStepFromMap builds a step from tool arguments and validates every
+// layer against the typed ops in domain, so typos fail at record time.
+func parseActionStepFromMap(stepRaw map[string]any) (domain.ActionStep, error) {
+	step, err := domain.ParseActionStep(stepRaw)
+	if err != nil {
+		return step, NewAppError("invalid_step", err.Error())
 	}
-	return step
+	return step, nil
 }
 
 func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
@@ -284,10 +613,16 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		runnerCommand, _ := args["runner_command"].(string)
 		runnerDir, _ := args["runner_dir"].(string)
 		artifactsDir, _ := args["artifacts_dir"].(string)
+		specsDir, _ := args["specs_dir"].(string)
+		templatesDir, _ := args["templates_dir"].(string)
+		datasources, _ := args["datasources"].(map[string]any)
 		if env == nil {
 			env = map[string]any{}
 		}
-		return r.svc.ProjectInit(projectKey, env, runnerCommand, runnerDir, artifactsDir)
+		return r.svc.ProjectInit(projectKey, env, runnerCommand, runnerDir, artifactsDir, specsDir, templatesDir, datasources)
+	case "syzygy_store_migrate":
+		projectKey, _ := args["project_key"].(string)
+		return r.svc.StoreMigrate(projectKey)
 	case "syzygy_unit_start":
 		projectKey, _ := args["project_key"].(string)
 		unitID, _ := args["unit_id"].(string)
@@ -298,6 +633,16 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 			return nil, NewAppError("invalid_unit_id", "unit_id is required")
 		}
 		return r.svc.UnitStart(projectKey, unitID, title, env, vars)
+	case "syzygy_run_fork":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		title, _ := args["title"].(string)
+		sourceUnitID, _ := args["source_unit_id"].(string)
+		sourceRunID, _ := args["source_run_id"].(string)
+		if unitID == "" {
+			return nil, NewAppError("invalid_unit_id", "unit_id is required")
+		}
+		return r.svc.RunFork(projectKey, unitID, sourceUnitID, sourceRunID, title)
 	case "syzygy_unit_meta_set":
 		projectKey, _ := args["project_key"].(string)
 		unitID, _ := args["unit_id"].(string)
@@ -405,7 +750,10 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		if !ok {
 			return nil, NewAppError("invalid_step", "step must be object; missing or wrong type")
 		}
-		step := parseActionStepFromMap(stepRaw)
+		step, err := parseActionStepFromMap(stepRaw)
+		if err != nil {
+			return nil, err
+		}
 		return r.svc.StepAppend(projectKey, unitID, runID, step)
 	case "syzygy_step_append_json":
 		projectKey, _ := args["project_key"].(string)
@@ -419,7 +767,10 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		}
 		// Prefer step object if provided
 		if stepRaw, ok := args["step"].(map[string]any); ok {
-			step := parseActionStepFromMap(stepRaw)
+			step, err := parseActionStepFromMap(stepRaw)
+			if err != nil {
+				return nil, err
+			}
 			return r.svc.StepAppend(projectKey, unitID, runID, step)
 		}
 
@@ -440,7 +791,10 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		if err := json.Unmarshal([]byte(stepJSON), &raw); err != nil {
 			return nil, NewAppError("invalid_step_json", fmt.Sprintf("invalid step_json: %v", err))
 		}
-		step := parseActionStepFromMap(raw)
+		step, err := parseActionStepFromMap(raw)
+		if err != nil {
+			return nil, err
+		}
 		return r.svc.StepAppend(projectKey, unitID, runID, step)
 	case "syzygy_steps_append_batch":
 		projectKey, _ := args["project_key"].(string)
@@ -456,13 +810,21 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		if !ok {
 			return nil, NewAppError("invalid_steps", "steps must be array")
 		}
-		stepIDs := []string{}
-		for _, it := range arr {
+		// Validate the whole batch before appending anything.
+		steps := []domain.ActionStep{}
+		for i, it := range arr {
 			m, ok := it.(map[string]any)
 			if !ok {
 				return nil, NewAppError("invalid_steps", "each step must be object")
 			}
-			step := parseActionStepFromMap(m)
+			step, err := parseActionStepFromMap(m)
+			if err != nil {
+				return nil, NewAppError("invalid_step", fmt.Sprintf("steps[%d]: %v", i, err))
+			}
+			steps = append(steps, step)
+		}
+		stepIDs := []string{}
+		for _, step := range steps {
 			res, err := r.svc.StepAppend(projectKey, unitID, runID, step)
 			if err != nil {
 				return nil, err
@@ -514,7 +876,144 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		if v, ok := checkRaw["assert"].(map[string]any); ok {
 			check.Assert = v
 		}
+		if v, ok := checkRaw["retry_attempts"].(float64); ok {
+			check.RetryAttempts = int(v)
+		}
+		if v, ok := checkRaw["retry_interval_ms"].(float64); ok {
+			check.RetryIntervalMS = int(v)
+		}
+		if v, ok := checkRaw["rows"].(string); ok {
+			check.Rows = v
+		}
+		check.RowCount = checkRaw["row_count"]
+		if v, ok := checkRaw["expect_rows"].([]any); ok {
+			for i, row := range v {
+				m, ok := row.(map[string]any)
+				if !ok {
+					return nil, NewAppError("invalid_db_check", fmt.Sprintf("expect_rows[%d] must be object", i))
+				}
+				check.ExpectRows = append(check.ExpectRows, m)
+			}
+		}
 		return r.svc.DbCheckAppend(projectKey, unitID, runID, check)
+	case "syzygy_step_update":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		stepID, _ := args["step_id"].(string)
+		stepRaw, ok := args["step"].(map[string]any)
+		if !ok {
+			return nil, NewAppError("invalid_step", "step must be object; missing or wrong type")
+		}
+		if stepID == "" {
+			return nil, NewAppError("invalid_args", "step_id is required")
+		}
+		step, err := parseActionStepFromMap(stepRaw)
+		if err != nil {
+			return nil, err
+		}
+		return r.svc.StepUpdate(projectKey, unitID, runID, stepID, step)
+	case "syzygy_step_insert":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		anchorStepID, _ := args["anchor_step_id"].(string)
+		position, _ := args["position"].(string)
+		stepRaw, ok := args["step"].(map[string]any)
+		if !ok {
+			return nil, NewAppError("invalid_step", "step must be object; missing or wrong type")
+		}
+		if anchorStepID == "" {
+			return nil, NewAppError("invalid_args", "anchor_step_id is required")
+		}
+		step, err := parseActionStepFromMap(stepRaw)
+		if err != nil {
+			return nil, err
+		}
+		return r.svc.StepInsert(projectKey, unitID, runID, anchorStepID, position, step)
+	case "syzygy_step_move":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		stepID, _ := args["step_id"].(string)
+		anchorStepID, _ := args["anchor_step_id"].(string)
+		position, _ := args["position"].(string)
+		if stepID == "" || anchorStepID == "" {
+			return nil, NewAppError("invalid_args", "step_id and anchor_step_id are required")
+		}
+		return r.svc.StepMove(projectKey, unitID, runID, stepID, anchorStepID, position)
+	case "syzygy_step_delete":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		stepID, _ := args["step_id"].(string)
+		if stepID == "" {
+			return nil, NewAppError("invalid_args", "step_id is required")
+		}
+		return r.svc.StepDelete(projectKey, unitID, runID, stepID)
+	case "syzygy_steps_replace":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		if args["steps"] == nil {
+			return nil, NewAppError("invalid_steps", "steps must be array")
+		}
+		steps, err := parseStepList(args["steps"], "steps")
+		if err != nil {
+			return nil, err
+		}
+		return r.svc.StepsReplace(projectKey, unitID, runID, steps)
+	case "syzygy_fixtures_set":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		setup, err := parseStepList(args["setup"], "setup")
+		if err != nil {
+			return nil, err
+		}
+		teardown, err := parseStepList(args["teardown"], "teardown")
+		if err != nil {
+			return nil, err
+		}
+		isolation, _ := args["isolation"].(string)
+		return r.svc.FixturesSet(projectKey, unitID, runID, setup, teardown, isolation)
+	case "syzygy_dbcheck_delete":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		checkID, _ := args["dbcheck_id"].(string)
+		if checkID == "" {
+			return nil, NewAppError("invalid_args", "dbcheck_id is required")
+		}
+		return r.svc.DbCheckDelete(projectKey, unitID, runID, checkID)
+	case "syzygy_dbcheck_run":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		checkID, _ := args["dbcheck_id"].(string)
+		env, _ := args["env"].(map[string]any)
+		if unitID == "" {
+			return nil, NewAppError("invalid_args", "unit_id is required")
+		}
+		return r.svc.DbCheckRun(projectKey, unitID, runID, checkID, env)
+	case "syzygy_dbcheck_suggest":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		path, _ := args["path"].(string)
+		if unitID == "" {
+			return nil, NewAppError("invalid_args", "unit_id is required")
+		}
+		return r.svc.DbCheckSuggest(projectKey, unitID, runID, path)
 	case "syzygy_crystallize":
 		projectKey, _ := args["project_key"].(string)
 		unitID, _ := args["unit_id"].(string)
@@ -527,10 +1026,70 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		}
 		tpl, _ := args["template"].(string)
 		outDir, _ := args["output_dir"].(string)
+		dryRun, _ := args["dry_run"].(bool)
 		if unitID == "" || runID == "" {
 			return nil, NewAppError("invalid_args", "unit_id and run_id are required")
 		}
-		return r.svc.Crystallize(projectKey, unitID, runID, tpl, outDir)
+		return r.svc.Crystallize(projectKey, unitID, runID, tpl, outDir, dryRun)
+	case "syzygy_templates_list":
+		projectKey, _ := args["project_key"].(string)
+		return r.svc.TemplatesList(projectKey)
+	case "syzygy_spec_schema":
+		return SpecJSONSchema(), nil
+	case "syzygy_spec_validate":
+		specPath, _ := args["spec_path"].(string)
+		specJSON, _ := args["spec_json"].(string)
+		return r.svc.SpecValidate(specPath, specJSON)
+	case "syzygy_spec_import":
+		projectKey, _ := args["project_key"].(string)
+		path, _ := args["path"].(string)
+		return r.svc.SpecImport(projectKey, path)
+	case "syzygy_har_import":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		opts := HarImportOptions{
+			URLContains: toStringSliceAny(args["url_contains"]),
+			Methods:     toStringSliceAny(args["methods"]),
+		}
+		opts.Path, _ = args["path"].(string)
+		opts.Mode, _ = args["mode"].(string)
+		opts.StepID, _ = args["step_id"].(string)
+		if unitID == "" || opts.Path == "" {
+			return nil, NewAppError("invalid_args", "unit_id and path are required")
+		}
+		return r.svc.HarImport(projectKey, unitID, runID, opts)
+	case "syzygy_gherkin_import":
+		projectKey, _ := args["project_key"].(string)
+		path, _ := args["path"].(string)
+		content, _ := args["content"].(string)
+		unitID, _ := args["unit_id"].(string)
+		return r.svc.GherkinImport(projectKey, path, content, unitID)
+	case "syzygy_openapi_operations":
+		path, _ := args["path"].(string)
+		if path == "" {
+			return nil, NewAppError("invalid_args", "path is required")
+		}
+		return r.svc.OpenAPIOperations(path)
+	case "syzygy_openapi_step_append":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		runID = r.resolveRunID(projectKey, unitID, runID)
+		path, _ := args["path"].(string)
+		operationID, _ := args["operation_id"].(string)
+		if unitID == "" || path == "" || operationID == "" {
+			return nil, NewAppError("invalid_args", "unit_id, path and operation_id are required")
+		}
+		return r.svc.OpenAPIStepAppend(projectKey, unitID, runID, path, operationID)
+	case "syzygy_contract_check":
+		projectKey, _ := args["project_key"].(string)
+		path, _ := args["path"].(string)
+		if path == "" {
+			return nil, NewAppError("invalid_args", "path is required")
+		}
+		return r.svc.ContractCheck(projectKey, path, toStringSliceAny(args["unit_ids"]))
 	case "syzygy_replay":
 		projectKey, _ := args["project_key"].(string)
 		unitID, _ := args["unit_id"].(string)
@@ -554,7 +1113,25 @@ func (r *ToolRegistry) CallTool(name string, args map[string]any) (any, error) {
 		if unitID == "" || runID == "" {
 			return nil, NewAppError("invalid_args", "unit_id and run_id are required")
 		}
-		return r.svc.Replay(projectKey, unitID, runID, cmd, argv, cwd, env)
+		engine, _ := args["engine"].(string)
+		snapshot, _ := args["snapshot"].(bool)
+		return r.svc.Replay(projectKey, unitID, runID, cmd, argv, cwd, env, engine, snapshot)
+	case "syzygy_run_finish":
+		projectKey, _ := args["project_key"].(string)
+		unitID, _ := args["unit_id"].(string)
+		runID, _ := args["run_id"].(string)
+		if runID == "" {
+			u, err := r.svc.GetUnit(projectKey, unitID)
+			if err == nil {
+				runID = latestRunID(u)
+			}
+		}
+		status, _ := args["status"].(string)
+		reason, _ := args["reason"].(string)
+		if unitID == "" || runID == "" {
+			return nil, NewAppError("invalid_args", "unit_id and run_id are required")
+		}
+		return r.svc.RunFinish(projectKey, unitID, runID, status, reason)
 	case "syzygy_selfcheck":
 		projectKey, _ := args["project_key"].(string)
 		unitID, _ := args["unit_id"].(string)
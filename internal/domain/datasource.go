@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Datasource drivers supported by the in-process replay engine.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+var driverAliases = map[string]string{
+	"mysql":      DriverMySQL,
+	"postgres":   DriverPostgres,
+	"postgresql": DriverPostgres,
+	"pg":         DriverPostgres,
+	"sqlite":     DriverSQLite,
+	"sqlite3":    DriverSQLite,
+}
+
+// DefaultDatasource is used by DB checks and db.exec steps without dms when
+// the unit env does not name one either.
+const DefaultDatasource = "default"
+
+// Datasource is a named database of a project. DB checks (DbCheck.DMS) and
+// db.exec steps are routed to it by name; DSN may reference ${VAR} from the
+// replay environment so secrets stay out of the config file. ReadOnly
+// rejects every db.exec; Protected (e.g. production) only the writing ones,
+// before crystallize and replay. TimeZone is the zone of timestamps returned
+// without one ("UTC", "Local" or an IANA name); by default the session zone
+// of the database is used.
+type Datasource struct {
+	Driver    string `json:"driver"`
+	DSN       string `json:"dsn"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+	Protected bool   `json:"protected,omitempty"`
+	TimeZone  string `json:"time_zone,omitempty"`
+}
+
+// NormalizeDriver maps driver aliases (postgresql, sqlite3, ...) to the
+// canonical name, or returns "" for unsupported drivers.
+func NormalizeDriver(driver string) string {
+	return driverAliases[strings.ToLower(strings.TrimSpace(driver))]
+}
+
+// Validate checks that the driver is supported and a DSN is set.
+func (d *Datasource) Validate() error {
+	if NormalizeDriver(d.Driver) == "" {
+		return fmt.Errorf("unsupported driver %q (supported: %s, %s, %s)", d.Driver, DriverMySQL, DriverPostgres, DriverSQLite)
+	}
+	if strings.TrimSpace(d.DSN) == "" {
+		return errors.New("dsn is required")
+	}
+	if d.TimeZone != "" {
+		if _, err := time.LoadLocation(d.TimeZone); err != nil {
+			return fmt.Errorf("time_zone: %v", err)
+		}
+	}
+	return nil
+}
+
+// ResolveDatasource picks the datasource for a DB check or db.exec step: its
+// own dms, then the "dms" of the spec env, then DefaultDatasource. ok is false
+// when the project defines no datasources, in which case the MYSQL_* env used
+// by the Node runner applies.
+func ResolveDatasource(datasources map[string]Datasource, dms string, env map[string]any) (string, Datasource, bool, error) {
+	if len(datasources) == 0 {
+		return "", Datasource{}, false, nil
+	}
+	name := strings.TrimSpace(dms)
+	if name == "" {
+		name, _ = env["dms"].(string)
+	}
+	if name == "" {
+		name = DefaultDatasource
+	}
+	ds, found := datasources[name]
+	if !found {
+		names := make([]string, 0, len(datasources))
+		for k := range datasources {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return name, Datasource{}, false, fmt.Errorf("unknown datasource %q; configured: %s", name, strings.Join(names, ", "))
+	}
+	return name, ds, true, nil
+}
+
+// DatasourceUse is a db.exec step or DB check of a spec and the dms it names.
+type DatasourceUse struct {
+	Spec *Spec
+	DMS  string
+	// Where names the step or check, e.g. "setup step seed".
+	Where string
+	// Fixture marks setup and teardown steps; Check is set for DB checks.
+	Fixture bool
+	Check   *DbCheck
+}
+
+// DatasourceUses lists the db.exec steps (setup and teardown included) and DB
+// checks of specs, in run order.
+func DatasourceUses(specs ...*Spec) []DatasourceUse {
+	out := []DatasourceUse{}
+	for _, spec := range specs {
+		steps := func(kind string, list []*ActionStep) {
+			for _, st := range list {
+				if st == nil {
+					continue
+				}
+				ops, _ := st.Ops()
+				for _, op := range ops {
+					if x, ok := op.(*DBExec); ok {
+						out = append(out, DatasourceUse{Spec: spec, DMS: x.DMS, Where: strings.TrimSpace(kind + " " + st.Name), Fixture: kind != "step"})
+					}
+				}
+			}
+		}
+		steps("setup step", spec.Setup)
+		steps("step", spec.Steps)
+		for _, c := range spec.DBChecks {
+			if c != nil {
+				out = append(out, DatasourceUse{Spec: spec, DMS: c.DMS, Where: "db check " + c.Name, Check: c})
+			}
+		}
+		steps("teardown step", spec.Teardown)
+	}
+	return out
+}
@@ -0,0 +1,555 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Row modes of a DbCheck: the rows its Assert applies to.
+const (
+	RowsFirst = "first" // rows[0], as in the Node runner (default)
+	RowsAll   = "all"   // every row
+	RowsAny   = "any"   // at least one row satisfies every field
+)
+
+// Operators of a FieldAssert.
+const (
+	AssertEq       = "eq"
+	AssertNe       = "ne"
+	AssertGt       = "gt"
+	AssertGte      = "gte"
+	AssertLt       = "lt"
+	AssertLte      = "lte"
+	AssertBetween  = "between"
+	AssertIn       = "in"
+	AssertNotIn    = "not_in"
+	AssertRegex    = "regex"
+	AssertNull     = "null"
+	AssertNotNull  = "not_null"
+	AssertNotEmpty = "not_empty"
+	AssertWithin   = "within"
+)
+
+var assertOps = map[string]string{
+	AssertEq: AssertEq, "=": AssertEq, "==": AssertEq,
+	AssertNe: AssertNe, "!=": AssertNe, "<>": AssertNe,
+	AssertGt: AssertGt, ">": AssertGt,
+	AssertGte: AssertGte, ">=": AssertGte,
+	AssertLt: AssertLt, "<": AssertLt,
+	AssertLte: AssertLte, "<=": AssertLte,
+	AssertBetween: AssertBetween,
+	AssertIn:      AssertIn,
+	AssertNotIn:   AssertNotIn,
+	AssertRegex:   AssertRegex, "matches": AssertRegex,
+	AssertNull:     AssertNull,
+	AssertNotNull:  AssertNotNull,
+	AssertNotEmpty: AssertNotEmpty,
+	AssertWithin:   AssertWithin,
+}
+
+// timeLayouts are the textual timestamps within accepts; layouts without a
+// zone are read in the zone of the datasource, see ParseDBTime.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// FieldAssert is one expectation on a column value (or the row count). In a
+// DbCheck it is written either as a plain value, compared as a string or
+// naming the not_null / not_empty keywords as the Node runner does, or as an
+// object:
+//
+//	{"op": ">", "value": 0}
+//	{"op": "between", "values": [1, 10]}
+//	{"op": "in", "values": ["paid", "shipped"]}
+//	{"op": "regex", "value": "^ORD-[0-9]+$"}
+//	{"op": "within", "value": "60s"}
+//	{"op": "eq", "path": "$.items[0].sku", "value": "A1"}
+//
+// Numeric values compare numerically, path reads a JSON column, and within
+// holds for times no further than value before the run started (or after
+// now). ${var} in values is substituted before evaluation.
+type FieldAssert struct {
+	Op     string `json:"op"`
+	Value  any    `json:"value,omitempty"`
+	Values []any  `json:"values,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// ParseFieldAssert reads an assertion in any of the forms FieldAssert accepts
+// and validates it.
+func ParseFieldAssert(raw any) (*FieldAssert, error) {
+	var a FieldAssert
+	switch t := raw.(type) {
+	case nil:
+		a.Op = AssertNull
+	case string:
+		switch t {
+		case AssertNotNull, AssertNotEmpty:
+			a.Op = t
+		default:
+			a.Op, a.Value = AssertEq, t
+		}
+	case float64, json.Number, int, int64, bool:
+		a.Op, a.Value = AssertEq, t
+	case map[string]any:
+		b, _ := json.Marshal(t)
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		dec.UseNumber()
+		if err := dec.Decode(&a); err != nil {
+			return nil, fmt.Errorf("invalid assertion: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("assertion must be a string, number, null or object, got %T", raw)
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Validate normalizes the operator and checks its operands. Operands holding
+// ${var} are only checked once substituted.
+func (a *FieldAssert) Validate() error {
+	op, ok := assertOps[strings.ToLower(strings.TrimSpace(a.Op))]
+	if !ok {
+		return fmt.Errorf("unknown assertion op %q", a.Op)
+	}
+	a.Op = op
+	if a.Path != "" && !strings.HasPrefix(a.Path, "$") {
+		return fmt.Errorf("path %q must start with $", a.Path)
+	}
+	switch a.Op {
+	case AssertEq, AssertNe:
+		if a.Value == nil {
+			return fmt.Errorf("%s needs a value", a.Op)
+		}
+	case AssertGt, AssertGte, AssertLt, AssertLte:
+		if _, err := assertNumber(a.Value); err != nil {
+			return fmt.Errorf("%s: %v", a.Op, err)
+		}
+	case AssertBetween:
+		if len(a.Values) != 2 {
+			return errors.New("between needs values [min, max]")
+		}
+		for _, v := range a.Values {
+			if _, err := assertNumber(v); err != nil {
+				return fmt.Errorf("between: %v", err)
+			}
+		}
+	case AssertIn, AssertNotIn:
+		if len(a.Values) == 0 {
+			return fmt.Errorf("%s needs values", a.Op)
+		}
+	case AssertRegex:
+		s := assertText(a.Value)
+		if a.Value == nil || s == "" {
+			return errors.New("regex needs a value")
+		}
+		if !strings.Contains(s, "${") {
+			if _, err := regexp.Compile(s); err != nil {
+				return fmt.Errorf("regex: %v", err)
+			}
+		}
+	case AssertWithin:
+		if _, err := assertDuration(a.Value); err != nil {
+			return fmt.Errorf("within: %v", err)
+		}
+	}
+	return nil
+}
+
+// String renders the expectation the way failure messages quote it; a string
+// equality is the bare value, as in the Node runner.
+func (a *FieldAssert) String() string {
+	var s string
+	switch a.Op {
+	case AssertEq:
+		s = assertText(a.Value)
+		if a.Path != "" {
+			s = "= " + s
+		}
+	case AssertNull, AssertNotNull, AssertNotEmpty:
+		s = a.Op
+	case AssertBetween:
+		s = fmt.Sprintf("between %s and %s", assertText(a.Values[0]), assertText(a.Values[1]))
+	case AssertIn, AssertNotIn:
+		parts := make([]string, len(a.Values))
+		for i, v := range a.Values {
+			parts[i] = assertText(v)
+		}
+		s = fmt.Sprintf("%s [%s]", a.Op, strings.Join(parts, ", "))
+	default:
+		sym := map[string]string{AssertNe: "!=", AssertGt: ">", AssertGte: ">=", AssertLt: "<", AssertLte: "<="}[a.Op]
+		if sym == "" {
+			sym = a.Op
+		}
+		s = sym + " " + assertText(a.Value)
+	}
+	if a.Path != "" {
+		s = a.Path + " " + s
+	}
+	return s
+}
+
+// Check evaluates the assertion against a column value: a string, or nil for
+// NULL, with present false when the column is missing. since is the start of
+// the run for within. It returns the actual value as text and, when the
+// assertion does not hold, an error describing why.
+func (a *FieldAssert) Check(value any, present bool, since, now time.Time) (string, error) {
+	if present && a.Path != "" && value != nil {
+		var doc any
+		dec := json.NewDecoder(strings.NewReader(assertText(value)))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return assertText(value), fmt.Errorf("expected=%s but the column is not JSON", a)
+		}
+		value, present = JSONPath(doc, a.Path)
+	}
+	actual := "undefined"
+	if present {
+		actual = assertText(value)
+	}
+	isNull := !present || value == nil
+	fail := fmt.Errorf("expected=%s actual=%s", a, actual)
+
+	ok := false
+	switch a.Op {
+	case AssertNull:
+		ok = present && value == nil
+	case AssertNotNull:
+		if ok = !isNull; !ok {
+			return actual, errors.New("expected=not_null but was null")
+		}
+	case AssertNotEmpty:
+		if ok = !isNull && strings.TrimSpace(actual) != ""; !ok {
+			return actual, errors.New("expected=not_empty but was empty")
+		}
+	case AssertEq:
+		ok = present && assertEqual(actual, a.Value)
+	case AssertNe:
+		ok = present && !assertEqual(actual, a.Value)
+	case AssertGt, AssertGte, AssertLt, AssertLte:
+		x, err1 := assertNumber(actual)
+		y, err2 := assertNumber(a.Value)
+		if isNull || err1 != nil || err2 != nil {
+			break
+		}
+		switch a.Op {
+		case AssertGt:
+			ok = x > y
+		case AssertGte:
+			ok = x >= y
+		case AssertLt:
+			ok = x < y
+		case AssertLte:
+			ok = x <= y
+		}
+	case AssertBetween:
+		x, err := assertNumber(actual)
+		lo, err1 := assertNumber(a.Values[0])
+		hi, err2 := assertNumber(a.Values[1])
+		ok = !isNull && err == nil && err1 == nil && err2 == nil && lo <= x && x <= hi
+	case AssertIn, AssertNotIn:
+		found := false
+		for _, v := range a.Values {
+			if assertEqual(actual, v) {
+				found = true
+				break
+			}
+		}
+		ok = !isNull && found == (a.Op == AssertIn)
+	case AssertRegex:
+		re, err := regexp.Compile(assertText(a.Value))
+		if err != nil {
+			return actual, fmt.Errorf("regex: %v", err)
+		}
+		ok = !isNull && re.MatchString(actual)
+	case AssertWithin:
+		d, err := assertDuration(a.Value)
+		if err != nil {
+			return actual, fmt.Errorf("within: %v", err)
+		}
+		t, err := ParseDBTime(actual, now.Location())
+		ok = !isNull && err == nil && !t.Before(since.Add(-d)) && !t.After(now.Add(d))
+	}
+	if !ok {
+		return actual, fail
+	}
+	return actual, nil
+}
+
+// Evaluate applies the check's assertions to the rows it fetched (values are
+// strings, or nil for NULL). resolve substitutes ${var} in an assertion before
+// it is parsed; timestamps without a zone are read in the location of now.
+// The first failing assertion is returned as the error; without any row-count
+// expectation an empty result fails with "no rows returned".
+func (c *DbCheck) Evaluate(rows []map[string]any, resolve func(any) any, since, now time.Time) ([]DbAssertResult, error) {
+	out := []DbAssertResult{}
+	var failure error
+	add := func(rs ...DbAssertResult) {
+		for _, r := range rs {
+			out = append(out, r)
+			if !r.OK && failure == nil {
+				failure = errors.New(r.Message)
+			}
+		}
+	}
+
+	counted := c.RowCount != nil || len(c.ExpectRows) > 0
+	if !counted && len(rows) == 0 {
+		return out, errors.New("no rows returned")
+	}
+	if c.RowCount != nil {
+		add(checkValue("row_count", "row_count", -1, c.RowCount, strconv.Itoa(len(rows)), true, resolve, since, now))
+	} else if len(c.ExpectRows) > 0 {
+		add(checkValue("row_count", "row_count", -1, len(c.ExpectRows), strconv.Itoa(len(rows)), true, resolve, since, now))
+	}
+	for i, want := range c.ExpectRows {
+		var row map[string]any
+		if i < len(rows) {
+			row = rows[i]
+		}
+		add(checkRow(want, row, i, fmt.Sprintf("row %d: ", i), resolve, since, now)...)
+	}
+
+	if len(c.Assert) == 0 {
+		return out, failure
+	}
+	switch c.Rows {
+	case RowsAll:
+		var picked []DbAssertResult
+		for i, row := range rows {
+			rs := checkRow(c.Assert, row, i, fmt.Sprintf("row %d: ", i), resolve, since, now)
+			if picked == nil || !assertsOK(rs) {
+				picked = rs
+			}
+			if !assertsOK(rs) {
+				break
+			}
+		}
+		add(picked...)
+	case RowsAny:
+		if len(rows) == 0 {
+			add(checkRow(c.Assert, nil, 0, "no row matches: ", resolve, since, now)...)
+			break
+		}
+		var picked []DbAssertResult
+		for i, row := range rows {
+			if rs := checkRow(c.Assert, row, i, "no row matches: ", resolve, since, now); assertsOK(rs) {
+				picked = rs
+				break
+			}
+		}
+		if picked == nil {
+			picked = checkRow(c.Assert, rows[0], 0, "no row matches: ", resolve, since, now)
+		}
+		add(picked...)
+	default:
+		var row map[string]any
+		if len(rows) > 0 {
+			row = rows[0]
+		}
+		add(checkRow(c.Assert, row, 0, "", resolve, since, now)...)
+	}
+	return out, failure
+}
+
+// Validate checks a DB check before it is stored: SQL is required, the row
+// mode must be known and every assertion must parse.
+func (c *DbCheck) Validate() error {
+	if strings.TrimSpace(c.SQL) == "" {
+		return errors.New("sql is required")
+	}
+	switch c.Rows {
+	case "", RowsFirst, RowsAll, RowsAny:
+	default:
+		return fmt.Errorf("rows must be %s, %s or %s", RowsFirst, RowsAll, RowsAny)
+	}
+	for _, field := range sortedFields(c.Assert) {
+		if _, err := ParseFieldAssert(c.Assert[field]); err != nil {
+			return fmt.Errorf("assert.%s: %w", field, err)
+		}
+	}
+	if c.RowCount != nil {
+		if _, err := ParseFieldAssert(c.RowCount); err != nil {
+			return fmt.Errorf("row_count: %w", err)
+		}
+	}
+	for i, row := range c.ExpectRows {
+		for _, field := range sortedFields(row) {
+			if _, err := ParseFieldAssert(row[field]); err != nil {
+				return fmt.Errorf("expect_rows[%d].%s: %w", i, field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Extended reports whether the check uses assertions beyond the rows[0]
+// string equality and not_null / not_empty keywords of the Node runner.
+func (c *DbCheck) Extended() bool {
+	if (c.Rows != "" && c.Rows != RowsFirst) || c.RowCount != nil || len(c.ExpectRows) > 0 {
+		return true
+	}
+	for _, v := range c.Assert {
+		if _, ok := v.(string); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasExtendedDbChecks returns the first DB check of specs that only the Go
+// engine can evaluate, or nil.
+func HasExtendedDbChecks(specs ...*Spec) *DbCheck {
+	for _, spec := range specs {
+		for _, c := range spec.DBChecks {
+			if c != nil && c.Extended() {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func checkRow(want map[string]any, row map[string]any, index int, prefix string, resolve func(any) any, since, now time.Time) []DbAssertResult {
+	out := []DbAssertResult{}
+	for _, field := range sortedFields(want) {
+		v, present := row[field]
+		out = append(out, checkValue(field, prefix+"field "+field, index, want[field], v, present, resolve, since, now))
+	}
+	return out
+}
+
+func checkValue(field, label string, row int, raw, value any, present bool, resolve func(any) any, since, now time.Time) DbAssertResult {
+	r := DbAssertResult{Field: field, Row: row, Expected: assertText(raw)}
+	a, err := ParseFieldAssert(resolve(raw))
+	if err != nil {
+		r.Message = fmt.Sprintf("%s %v", label, err)
+		return r
+	}
+	r.Expected = a.String()
+	r.Actual, err = a.Check(value, present, since, now)
+	r.OK = err == nil
+	if err != nil {
+		r.Message = fmt.Sprintf("%s %v", label, err)
+	}
+	return r
+}
+
+func assertsOK(rs []DbAssertResult) bool {
+	for _, r := range rs {
+		if !r.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// assertEqual compares numerically when the expectation is a number and as
+// strings otherwise.
+func assertEqual(actual string, want any) bool {
+	switch want.(type) {
+	case float64, json.Number, int, int64:
+		x, err1 := assertNumber(actual)
+		y, err2 := assertNumber(want)
+		return err1 == nil && err2 == nil && x == y
+	}
+	return actual == assertText(want)
+}
+
+func assertText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case json.Number:
+		return t.String()
+	case int:
+		return strconv.Itoa(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// assertNumber reads a numeric operand; unresolved ${var} is accepted as 0 so
+// validation can run before substitution.
+func assertNumber(v any) (float64, error) {
+	s := strings.TrimSpace(assertText(v))
+	if v == nil {
+		return 0, errors.New("needs a number")
+	}
+	if strings.Contains(s, "${") {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return f, nil
+}
+
+// assertDuration reads a Go duration ("60s", "5m") or a number of seconds.
+func assertDuration(v any) (time.Duration, error) {
+	s := strings.TrimSpace(assertText(v))
+	if v == nil || s == "" {
+		return 0, errors.New("needs a duration such as \"60s\"")
+	}
+	if strings.Contains(s, "${") {
+		return 0, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration", s)
+	}
+	return d, nil
+}
+
+// ParseDBTime reads a timestamp column: one of timeLayouts (in loc unless the
+// text has a zone), or Unix seconds or milliseconds.
+func ParseDBTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e11 {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time", s)
+}
+
+func sortedFields(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
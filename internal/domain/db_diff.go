@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// tableRef is a snapshot table: [datasource:]table, the table optionally
+// schema-qualified.
+var tableRef = regexp.MustCompile(`^(?:([A-Za-z0-9_.-]+):)?([A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*)?)$`)
+
+// ParseTableRef splits a touchpoints.db_tables entry into the datasource name
+// (empty for the default) and the table.
+func ParseTableRef(ref string) (string, string, error) {
+	m := tableRef.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return "", "", fmt.Errorf("invalid table %q; expected [datasource:]table", ref)
+	}
+	return m[1], m[2], nil
+}
+
+// TableSnapshot is the content of one table at one point of a replay. Values
+// are strings, or nil for NULL.
+type TableSnapshot struct {
+	Table      string           `json:"table"`
+	Datasource string           `json:"datasource,omitempty"`
+	PrimaryKey []string         `json:"primary_key"`
+	Rows       []map[string]any `json:"rows"`
+	Truncated  bool             `json:"truncated,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// RowChange is a row whose primary key survived a replay with other values.
+type RowChange struct {
+	Key     map[string]any `json:"key"`
+	Before  map[string]any `json:"before"`
+	After   map[string]any `json:"after"`
+	Changed []string       `json:"changed"`
+}
+
+// TableDiff is the row-level difference of a table across a replay. Rows are
+// matched by primary key; without one, a changed row shows up as deleted and
+// inserted.
+type TableDiff struct {
+	Table      string           `json:"table"`
+	Datasource string           `json:"datasource,omitempty"`
+	PrimaryKey []string         `json:"primary_key"`
+	Inserted   []map[string]any `json:"inserted"`
+	Updated    []RowChange      `json:"updated"`
+	Deleted    []map[string]any `json:"deleted"`
+	// Truncated means a snapshot hit the row limit; only the rows both
+	// snapshots cover were compared.
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Empty reports whether the replay left the table unchanged.
+func (d *TableDiff) Empty() bool {
+	return len(d.Inserted) == 0 && len(d.Updated) == 0 && len(d.Deleted) == 0
+}
+
+// DiffTable compares the snapshots of one table taken before and after a
+// replay.
+func DiffTable(before, after *TableSnapshot) TableDiff {
+	d := TableDiff{
+		Table:      after.Table,
+		Datasource: after.Datasource,
+		PrimaryKey: after.PrimaryKey,
+		Inserted:   []map[string]any{},
+		Updated:    []RowChange{},
+		Deleted:    []map[string]any{},
+		Truncated:  before.Truncated || after.Truncated,
+	}
+	if before.Error != "" || after.Error != "" {
+		d.Error = before.Error
+		if d.Error == "" {
+			d.Error = after.Error
+		}
+		return d
+	}
+
+	pk := after.PrimaryKey
+	if len(pk) == 0 || !slices.Equal(pk, before.PrimaryKey) {
+		pk = nil
+	}
+	beforeRows, afterRows := before.Rows, after.Rows
+	if d.Truncated {
+		// Snapshots list the newest keys first; rows past the last key of the
+		// other snapshot were not read on both sides.
+		if pk == nil {
+			d.Error = fmt.Sprintf("%s has more rows than the snapshot limit and no primary key; the diff would be incomplete", d.Table)
+			return d
+		}
+		cut := false
+		if before.Truncated {
+			if rows, ok := rowsThrough(after.Rows, before.Rows[len(before.Rows)-1], pk); ok {
+				afterRows, cut = rows, true
+			}
+		}
+		if !cut && after.Truncated {
+			if rows, ok := rowsThrough(before.Rows, after.Rows[len(after.Rows)-1], pk); ok {
+				beforeRows, cut = rows, true
+			}
+		}
+		if !cut {
+			d.Error = fmt.Sprintf("%s has more rows than the snapshot limit and the snapshots share no boundary row; the diff would be incomplete", d.Table)
+			return d
+		}
+	}
+	// Keyless rows are matched as a multiset of whole rows.
+	old := map[string][]map[string]any{}
+	for _, row := range beforeRows {
+		k := rowKey(row, pk)
+		old[k] = append(old[k], row)
+	}
+	for _, row := range afterRows {
+		k := rowKey(row, pk)
+		prev := old[k]
+		if len(prev) == 0 {
+			d.Inserted = append(d.Inserted, row)
+			continue
+		}
+		old[k] = prev[1:]
+		if changed := changedColumns(prev[0], row); len(changed) > 0 {
+			key := map[string]any{}
+			for _, c := range pk {
+				key[c] = row[c]
+			}
+			d.Updated = append(d.Updated, RowChange{Key: key, Before: prev[0], After: row, Changed: changed})
+		}
+	}
+	for _, row := range beforeRows {
+		k := rowKey(row, pk)
+		if rest := old[k]; len(rest) > 0 {
+			d.Deleted = append(d.Deleted, rest[0])
+			old[k] = rest[1:]
+		}
+	}
+	return d
+}
+
+// rowsThrough cuts rows after the row with the primary key of last; it
+// reports false when no row has that key.
+func rowsThrough(rows []map[string]any, last map[string]any, pk []string) ([]map[string]any, bool) {
+	key := rowKey(last, pk)
+	for i, row := range rows {
+		if rowKey(row, pk) == key {
+			return rows[:i+1], true
+		}
+	}
+	return nil, false
+}
+
+func rowKey(row map[string]any, pk []string) string {
+	if len(pk) == 0 {
+		b, _ := json.Marshal(row)
+		return string(b)
+	}
+	vals := make([]any, len(pk))
+	for i, c := range pk {
+		vals[i] = row[c]
+	}
+	b, _ := json.Marshal(vals)
+	return string(b)
+}
+
+func changedColumns(before, after map[string]any) []string {
+	out := []string{}
+	for _, c := range sortedFields(after) {
+		if prev, ok := before[c]; !ok || prev != after[c] {
+			out = append(out, c)
+		}
+	}
+	for _, c := range sortedFields(before) {
+		if _, ok := after[c]; !ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
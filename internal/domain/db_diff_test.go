@@ -0,0 +1,204 @@
+package domain
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func row(kv ...any) map[string]any {
+	m := map[string]any{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		m[kv[i].(string)] = kv[i+1]
+	}
+	return m
+}
+
+func rows(r ...map[string]any) []map[string]any {
+	if r == nil {
+		return []map[string]any{}
+	}
+	return r
+}
+
+func TestDiffTable(t *testing.T) {
+	pk := []string{"id"}
+	tests := []struct {
+		name      string
+		before    TableSnapshot
+		after     TableSnapshot
+		inserted  []map[string]any
+		updated   []RowChange
+		deleted   []map[string]any
+		truncated bool
+		err       string
+	}{
+		{
+			name:     "unchanged",
+			before:   TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "1", "v", "a"))},
+			after:    TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "1", "v", "a"))},
+			inserted: rows(), updated: []RowChange{}, deleted: rows(),
+		},
+		{
+			name:     "insert update delete by primary key",
+			before:   TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "2", "v", "b"), row("id", "1", "v", "a"))},
+			after:    TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "3", "v", "c"), row("id", "2", "v", "B"))},
+			inserted: rows(row("id", "3", "v", "c")),
+			updated: []RowChange{{
+				Key:     row("id", "2"),
+				Before:  row("id", "2", "v", "b"),
+				After:   row("id", "2", "v", "B"),
+				Changed: []string{"v"},
+			}},
+			deleted: rows(row("id", "1", "v", "a")),
+		},
+		{
+			name:     "NULL to value is a change",
+			before:   TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "1", "v", nil))},
+			after:    TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "1", "v", "x"))},
+			inserted: rows(),
+			updated: []RowChange{{
+				Key:     row("id", "1"),
+				Before:  row("id", "1", "v", nil),
+				After:   row("id", "1", "v", "x"),
+				Changed: []string{"v"},
+			}},
+			deleted: rows(),
+		},
+		{
+			name:     "composite primary key",
+			before:   TableSnapshot{PrimaryKey: []string{"a", "b"}, Rows: rows(row("a", "1", "b", "2", "v", "x"))},
+			after:    TableSnapshot{PrimaryKey: []string{"a", "b"}, Rows: rows(row("a", "1", "b", "2", "v", "y"), row("a", "12", "b", "", "v", "x"))},
+			inserted: rows(row("a", "12", "b", "", "v", "x")),
+			updated: []RowChange{{
+				Key:     row("a", "1", "b", "2"),
+				Before:  row("a", "1", "b", "2", "v", "x"),
+				After:   row("a", "1", "b", "2", "v", "y"),
+				Changed: []string{"v"},
+			}},
+			deleted: rows(),
+		},
+		{
+			name:     "keyless rows are a multiset",
+			before:   TableSnapshot{Rows: rows(row("v", "a"), row("v", "a"), row("v", "b"))},
+			after:    TableSnapshot{Rows: rows(row("v", "a"), row("v", "b"), row("v", "b"))},
+			inserted: rows(row("v", "b")),
+			updated:  []RowChange{},
+			deleted:  rows(row("v", "a")),
+		},
+		{
+			name:     "keyless changed row is deleted and inserted",
+			before:   TableSnapshot{Rows: rows(row("v", "a"))},
+			after:    TableSnapshot{Rows: rows(row("v", "z"))},
+			inserted: rows(row("v", "z")),
+			updated:  []RowChange{},
+			deleted:  rows(row("v", "a")),
+		},
+		{
+			name:     "primary key change falls back to whole rows",
+			before:   TableSnapshot{PrimaryKey: []string{"id"}, Rows: rows(row("id", "1", "v", "a"))},
+			after:    TableSnapshot{PrimaryKey: []string{"v"}, Rows: rows(row("id", "1", "v", "b"))},
+			inserted: rows(row("id", "1", "v", "b")),
+			updated:  []RowChange{},
+			deleted:  rows(row("id", "1", "v", "a")),
+		},
+		{
+			name:      "truncated before is cut at its last key",
+			before:    TableSnapshot{PrimaryKey: pk, Truncated: true, Rows: rows(row("id", "3"), row("id", "2"))},
+			after:     TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "4"), row("id", "3"), row("id", "2"), row("id", "1"))},
+			inserted:  rows(row("id", "4")),
+			updated:   []RowChange{},
+			deleted:   rows(),
+			truncated: true,
+		},
+		{
+			name:      "truncated after is cut at its last key",
+			before:    TableSnapshot{PrimaryKey: pk, Rows: rows(row("id", "3"), row("id", "2"), row("id", "1"))},
+			after:     TableSnapshot{PrimaryKey: pk, Truncated: true, Rows: rows(row("id", "4"), row("id", "3"))},
+			inserted:  rows(row("id", "4")),
+			updated:   []RowChange{},
+			deleted:   rows(),
+			truncated: true,
+		},
+		{
+			name:      "deleted boundary row uses the other boundary",
+			before:    TableSnapshot{PrimaryKey: pk, Truncated: true, Rows: rows(row("id", "3"), row("id", "2"))},
+			after:     TableSnapshot{PrimaryKey: pk, Truncated: true, Rows: rows(row("id", "4"), row("id", "3"))},
+			inserted:  rows(row("id", "4")),
+			updated:   []RowChange{},
+			deleted:   rows(),
+			truncated: true,
+		},
+		{
+			name:      "deleted boundary row with no shared boundary",
+			before:    TableSnapshot{Table: "t", PrimaryKey: pk, Truncated: true, Rows: rows(row("id", "3"), row("id", "2"))},
+			after:     TableSnapshot{Table: "t", PrimaryKey: pk, Truncated: true, Rows: rows(row("id", "3"), row("id", "1"))},
+			truncated: true,
+			err:       "share no boundary row",
+		},
+		{
+			name:      "truncated keyless table",
+			before:    TableSnapshot{Table: "t", Truncated: true, Rows: rows(row("v", "a"))},
+			after:     TableSnapshot{Table: "t", Rows: rows(row("v", "a"))},
+			truncated: true,
+			err:       "no primary key",
+		},
+		{
+			name:   "snapshot error",
+			before: TableSnapshot{Error: "no such table"},
+			after:  TableSnapshot{},
+			err:    "no such table",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffTable(&tt.before, &tt.after)
+			if d.Truncated != tt.truncated {
+				t.Errorf("truncated = %v, want %v", d.Truncated, tt.truncated)
+			}
+			if tt.err != "" {
+				if !strings.Contains(d.Error, tt.err) {
+					t.Fatalf("error = %q, want it to contain %q", d.Error, tt.err)
+				}
+				return
+			}
+			if d.Error != "" {
+				t.Fatalf("unexpected error %q", d.Error)
+			}
+			if !reflect.DeepEqual(d.Inserted, tt.inserted) {
+				t.Errorf("inserted = %v, want %v", d.Inserted, tt.inserted)
+			}
+			if !reflect.DeepEqual(d.Updated, tt.updated) {
+				t.Errorf("updated = %v, want %v", d.Updated, tt.updated)
+			}
+			if !reflect.DeepEqual(d.Deleted, tt.deleted) {
+				t.Errorf("deleted = %v, want %v", d.Deleted, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestParseTableRef(t *testing.T) {
+	tests := []struct {
+		ref, ds, table string
+		ok             bool
+	}{
+		{"orders", "", "orders", true},
+		{"ro:orders", "ro", "orders", true},
+		{"pg-main:public.orders", "pg-main", "public.orders", true},
+		{" orders ", "", "orders", true},
+		{"orders;drop", "", "", false},
+		{"1orders", "", "", false},
+		{"a.b.c", "", "", false},
+	}
+	for _, tt := range tests {
+		ds, table, err := ParseTableRef(tt.ref)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseTableRef(%q) error = %v, want ok %v", tt.ref, err, tt.ok)
+			continue
+		}
+		if ds != tt.ds || table != tt.table {
+			t.Errorf("ParseTableRef(%q) = %q, %q, want %q, %q", tt.ref, ds, table, tt.ds, tt.table)
+		}
+	}
+}
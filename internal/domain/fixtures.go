@@ -0,0 +1,48 @@
+package domain
+
+import "fmt"
+
+// IsolationRollback replays a unit whose steps only touch databases inside one
+// transaction per datasource, rolled back once teardown has run.
+const IsolationRollback = "rollback"
+
+// ValidateFixtures checks the setup and teardown steps and the isolation mode
+// of a run or spec: fixtures cannot drive the browser, and rollback isolation
+// needs every step to be db.* or util.*.
+func ValidateFixtures(setup, steps, teardown []*ActionStep, isolation string) error {
+	for _, fx := range []struct {
+		name  string
+		steps []*ActionStep
+	}{{"setup", setup}, {"teardown", teardown}} {
+		for i, st := range fx.steps {
+			if st == nil {
+				return fmt.Errorf("%s[%d] is empty", fx.name, i)
+			}
+			if _, err := st.Op(); err != nil {
+				return fmt.Errorf("%s[%d] %s: %w", fx.name, i, st.Name, err)
+			}
+			if st.UI != nil {
+				return fmt.Errorf("%s[%d] %s: fixtures cannot have ui steps", fx.name, i, st.Name)
+			}
+		}
+	}
+	switch isolation {
+	case "":
+	case IsolationRollback:
+		for _, list := range [][]*ActionStep{setup, steps, teardown} {
+			for _, st := range list {
+				if st != nil && (st.UI != nil || st.Net != nil) {
+					return fmt.Errorf("isolation %s needs db-only steps; step %s is not", IsolationRollback, st.Name)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown isolation %q; supported: %s", isolation, IsolationRollback)
+	}
+	return nil
+}
+
+// ValidateFixtures checks the fixtures of the spec, see ValidateFixtures.
+func (s *Spec) ValidateFixtures() error {
+	return ValidateFixtures(s.Setup, s.Steps, s.Teardown, s.Isolation)
+}
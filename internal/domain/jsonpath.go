@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+)
+
+// JSONPath resolves the subset of JSONPath used by specs: $.a.b, $.list[0]
+// and $['key'].
+func JSONPath(doc any, path string) (any, bool) {
+	p := strings.TrimPrefix(path, "$")
+	cur := doc
+	for p != "" {
+		key, index := "", -1
+		switch {
+		case strings.HasPrefix(p, "."):
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			key, p = p[:end], p[end:]
+		case strings.HasPrefix(p, "['"), strings.HasPrefix(p, `["`):
+			end := strings.Index(p[2:], p[1:2]+"]")
+			if end < 0 {
+				return nil, false
+			}
+			key, p = p[2:2+end], p[2+end+2:]
+		case strings.HasPrefix(p, "["):
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, false
+			}
+			n, err := strconv.Atoi(p[1:end])
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			index, p = n, p[end+1:]
+		default:
+			return nil, false
+		}
+		if index >= 0 {
+			arr, ok := cur.([]any)
+			if !ok || index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[index]
+			continue
+		}
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
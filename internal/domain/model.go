@@ -5,13 +5,23 @@ import (
 )
 
 type Unit struct {
-	UnitID    string         `json:"unit_id"`
-	Title     string         `json:"title"`
-	Env       map[string]any `json:"env"`
-	Meta      map[string]any `json:"meta,omitempty"`
-	Runs      []*Run         `json:"runs"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	SchemaVersion int            `json:"schema_version"`
+	UnitID        string         `json:"unit_id"`
+	Title         string         `json:"title"`
+	Env           map[string]any `json:"env"`
+	Meta          map[string]any `json:"meta,omitempty"`
+	Runs          []*Run         `json:"runs"`
+	History       []*HistoryItem `json:"history,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// HistoryItem records one edit made to a run of the unit after it was recorded.
+type HistoryItem struct {
+	At     time.Time      `json:"at"`
+	RunID  string         `json:"run_id"`
+	Action string         `json:"action"`
+	Detail map[string]any `json:"detail,omitempty"`
 }
 
 type Run struct {
@@ -25,6 +35,11 @@ type Run struct {
 	StartedAt time.Time              `json:"started_at"`
 	EndedAt   *time.Time             `json:"ended_at,omitempty"`
 	Meta      map[string]any         `json:"meta,omitempty"`
+	// Setup runs before Steps and Teardown after them, even when the replay
+	// fails; Isolation "rollback" replays DB-only units in a transaction.
+	Setup     []*ActionStep `json:"setup,omitempty"`
+	Teardown  []*ActionStep `json:"teardown,omitempty"`
+	Isolation string        `json:"isolation,omitempty"`
 }
 
 type ActionStep struct {
@@ -38,10 +53,17 @@ type ActionStep struct {
 }
 
 type DbCheck struct {
-	CheckID string            `json:"check_id"`
-	Name    string            `json:"name"`
-	DMS     string            `json:"dms"`
-	SQL     string            `json:"sql"`
-	Params  map[string]string `json:"params"`
-	Assert  map[string]any    `json:"assert"`
+	CheckID         string            `json:"check_id"`
+	Name            string            `json:"name"`
+	DMS             string            `json:"dms"`
+	SQL             string            `json:"sql" schema:"required"`
+	Params          map[string]string `json:"params"`
+	Assert          map[string]any    `json:"assert"`
+	RetryAttempts   int               `json:"retry_attempts,omitempty"`
+	RetryIntervalMS int               `json:"retry_interval_ms,omitempty"`
+	// Rows selects the rows Assert applies to (first, all, any); RowCount and
+	// ExpectRows (exact rows in order) are FieldAssert expectations too.
+	Rows       string           `json:"rows,omitempty"`
+	RowCount   any              `json:"row_count,omitempty"`
+	ExpectRows []map[string]any `json:"expect_rows,omitempty"`
 }
@@ -0,0 +1,89 @@
+package domain
+
+import "time"
+
+// ReplayOptions configures an in-process replay.
+type ReplayOptions struct {
+	// Env is the process environment the Node runner would see: the OS env
+	// overlaid with project, unit and tool env. It supplies MYSQL_* when the
+	// spec env does not, and SYZYGY_AUTH_TOKEN.
+	Env map[string]string
+	// ArtifactsDir receives net-call and *-failed.json artifacts; empty
+	// disables them.
+	ArtifactsDir string
+	// Datasources are the project datasources DB checks and db.exec steps
+	// are routed to by dms; without any, MYSQL_* is used.
+	Datasources map[string]Datasource
+	// Since is the start of the run that within assertions are relative to;
+	// zero means when the replay starts.
+	Since time.Time
+	// Snapshot lists [datasource:]tables read once the steps and DB checks
+	// ran, before teardown, into ReplayReport.Snapshot.
+	Snapshot []string
+}
+
+// ReplayReport is the outcome of an in-process replay.
+type ReplayReport struct {
+	OK               bool              `json:"ok"`
+	Error            string            `json:"error,omitempty"`
+	Anchors          map[string]string `json:"anchors"`
+	Log              []string          `json:"log"`
+	FailureArtifacts []string          `json:"failure_artifacts,omitempty"`
+	// Setup holds the outcome of the setup steps run around the Node runner.
+	Setup []StepOutcome `json:"setup,omitempty"`
+	// Teardown holds the outcome of every teardown step; it does not affect OK.
+	Teardown   []StepOutcome    `json:"teardown,omitempty"`
+	RolledBack bool             `json:"rolled_back,omitempty"`
+	Snapshot   []*TableSnapshot `json:"snapshot,omitempty"`
+}
+
+// StepOutcome is the result of one setup or teardown step.
+type StepOutcome struct {
+	UnitID string `json:"unit_id,omitempty"`
+	StepID string `json:"step_id,omitempty"`
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DbCheckResult is the outcome of running one DB check once.
+type DbCheckResult struct {
+	CheckID string `json:"check_id,omitempty"`
+	Name    string `json:"name"`
+	// Datasource describes the database the check ran against.
+	Datasource map[string]any `json:"datasource,omitempty"`
+	// SQL is the statement after :name parameters were bound; Args are the
+	// bound values.
+	SQL       string           `json:"sql"`
+	Args      []any            `json:"args"`
+	Rows      []map[string]any `json:"rows"`
+	Truncated bool             `json:"truncated,omitempty"`
+	Asserts   []DbAssertResult `json:"asserts"`
+	OK        bool             `json:"ok"`
+	Error     string           `json:"error,omitempty"`
+	// Warnings name ${var} references that did not resolve.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DbAssertResult is the outcome of one assertion of a DbCheck. Row is the
+// index of the row it was evaluated on, or -1 for row_count.
+type DbAssertResult struct {
+	Field    string `json:"field"`
+	Row      int    `json:"row"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	OK       bool   `json:"ok"`
+	Message  string `json:"message,omitempty"`
+}
+
+// HasUISteps reports whether any step of the specs drives the browser.
+func HasUISteps(specs ...*Spec) bool {
+	for _, spec := range specs {
+		for _, st := range spec.Steps {
+			if st != nil && st.UI != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package domain
+
+const (
+	RunStatusInProgress   = "in_progress"
+	RunStatusCrystallized = "crystallized"
+	RunStatusReplayPassed = "replay_passed"
+	RunStatusReplayFailed = "replay_failed"
+	RunStatusAccepted     = "accepted"
+	RunStatusAbandoned    = "abandoned"
+)
+
+// runTransitions lists the statuses reachable from each non-terminal status.
+// Editing a crystallized run sends it back to in_progress because its
+// artifacts no longer match the recorded steps.
+var runTransitions = map[string][]string{
+	RunStatusInProgress: {RunStatusCrystallized, RunStatusAbandoned},
+	RunStatusCrystallized: {
+		RunStatusInProgress, RunStatusCrystallized,
+		RunStatusReplayPassed, RunStatusReplayFailed, RunStatusAbandoned,
+	},
+	RunStatusReplayPassed: {
+		RunStatusInProgress, RunStatusCrystallized,
+		RunStatusReplayPassed, RunStatusReplayFailed, RunStatusAccepted, RunStatusAbandoned,
+	},
+	RunStatusReplayFailed: {
+		RunStatusInProgress, RunStatusCrystallized,
+		RunStatusReplayPassed, RunStatusReplayFailed, RunStatusAbandoned,
+	},
+}
+
+func CanTransitionRun(from, to string) bool {
+	for _, s := range runTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRunClosed reports whether a run has reached a terminal status.
+func IsRunClosed(status string) bool {
+	return status == RunStatusAccepted || status == RunStatusAbandoned
+}
+
+func IsKnownRunStatus(status string) bool {
+	_, ok := runTransitions[status]
+	return ok || IsRunClosed(status)
+}
@@ -0,0 +1,14 @@
+package domain
+
+// Schema versions of the JSON documents persisted by the store. Bump the
+// matching constant and register a migration whenever a stored shape changes.
+const (
+	UnitSchemaVersion          = 6
+	ProjectConfigSchemaVersion = 5
+)
+
+// SpecSchemaVersion is the version of the published spec.json JSON Schema.
+// Bump it whenever spec fields are added. v2 added setup, teardown,
+// isolation, top-level net_rules and the rows, row_count and expect_rows of
+// DB checks; v1 specs are valid v2 specs.
+const SpecSchemaVersion = 2
@@ -0,0 +1,261 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Spec is the crystallized, runner-facing form of a unit run (spec.json).
+type Spec struct {
+	SpecVersion   int               `json:"spec_version,omitempty"`
+	UnitID        string            `json:"unit_id" schema:"required"`
+	RunID         string            `json:"run_id,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Prerequisites []string          `json:"prerequisites,omitempty"`
+	Metadata      map[string]any    `json:"metadata,omitempty"`
+	Env           map[string]any    `json:"env,omitempty"`
+	Variables     map[string]any    `json:"variables,omitempty"`
+	Anchors       map[string]string `json:"anchors,omitempty"`
+	Steps         []*ActionStep     `json:"steps" schema:"required"`
+	DBChecks      []*DbCheck        `json:"db_checks,omitempty"`
+	Setup         []*ActionStep     `json:"setup,omitempty"`
+	Teardown      []*ActionStep     `json:"teardown,omitempty"`
+	Isolation     string            `json:"isolation,omitempty"`
+	// NetRules, or Net.Must without it, are net.must rules of older specs
+	// kept at the top level; the runner checks them with those of the steps.
+	NetRules []NetRule  `json:"net_rules,omitempty"`
+	Net      *NetExpect `json:"net,omitempty"`
+}
+
+// AllNetRules lists the net.must rules of the steps followed by the
+// top-level ones, as the runner collects them.
+func (s *Spec) AllNetRules() ([]NetRule, error) {
+	out := []NetRule{}
+	for _, st := range s.Steps {
+		rules, err := st.NetRules()
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", st.Name, err)
+		}
+		out = append(out, rules...)
+	}
+	return append(out, s.TopNetRules()...), nil
+}
+
+// TopNetRules returns the top-level net.must rules of the spec.
+func (s *Spec) TopNetRules() []NetRule {
+	if s.NetRules != nil {
+		return s.NetRules
+	}
+	if s.Net != nil {
+		return s.Net.Must
+	}
+	return nil
+}
+
+// SpecIssue is one validation failure, located by a JSON pointer into the spec.
+type SpecIssue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (i SpecIssue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// ParseActionStep builds a step from its raw JSON object and validates it.
+func ParseActionStep(raw map[string]any) (ActionStep, error) {
+	step := ActionStep{}
+	allowed := jsonFieldNames(step)
+	for k := range raw {
+		if !containsString(allowed, k) {
+			return step, fmt.Errorf("unknown step field %q; allowed fields: %s", k, strings.Join(allowed, ", "))
+		}
+	}
+	if v, ok := raw["step_id"].(string); ok {
+		step.StepID = v
+	}
+	if v, ok := raw["name"].(string); ok {
+		step.Name = v
+	}
+	layers := map[string]*map[string]any{
+		"util":   &step.Util,
+		"db":     &step.DB,
+		"ui":     &step.UI,
+		"net":    &step.Net,
+		"expect": &step.Expect,
+	}
+	for key, dst := range layers {
+		v, exists := raw[key]
+		if !exists || v == nil {
+			continue
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return step, fmt.Errorf("step field %q must be object", key)
+		}
+		*dst = m
+	}
+	if err := step.Validate(); err != nil {
+		return step, err
+	}
+	return step, nil
+}
+
+// ValidateSpec checks a spec.json document against the same domain types the
+// published JSON Schema is generated from and returns every issue found.
+func ValidateSpec(raw []byte) []SpecIssue {
+	issues := []SpecIssue{}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return append(issues, SpecIssue{Message: "invalid JSON: " + err.Error()})
+	}
+
+	allowed := jsonFieldNames(Spec{})
+	for k := range doc {
+		if !containsString(allowed, k) {
+			issues = append(issues, SpecIssue{Path: "/" + k, Message: "unknown field; allowed fields: " + strings.Join(allowed, ", ")})
+		}
+	}
+	if v, _ := doc["unit_id"].(string); v == "" {
+		issues = append(issues, SpecIssue{Path: "/unit_id", Message: "required non-empty string"})
+	}
+	for _, key := range []string{"metadata", "env", "variables", "anchors"} {
+		if v, ok := doc[key]; ok && v != nil {
+			if _, isObj := v.(map[string]any); !isObj {
+				issues = append(issues, SpecIssue{Path: "/" + key, Message: "must be object"})
+			}
+		}
+	}
+	if anchors, ok := doc["anchors"].(map[string]any); ok {
+		for k, v := range anchors {
+			if _, isStr := v.(string); !isStr {
+				issues = append(issues, SpecIssue{Path: "/anchors/" + k, Message: "must be string"})
+			}
+		}
+	}
+	if v, ok := doc["prerequisites"]; ok {
+		arr, isArr := v.([]any)
+		if !isArr {
+			issues = append(issues, SpecIssue{Path: "/prerequisites", Message: "must be array of strings"})
+		}
+		for i, p := range arr {
+			if s, _ := p.(string); s == "" {
+				issues = append(issues, SpecIssue{Path: fmt.Sprintf("/prerequisites/%d", i), Message: "must be non-empty string"})
+			}
+		}
+	}
+
+	steps, isArr := doc["steps"].([]any)
+	if !isArr {
+		issues = append(issues, SpecIssue{Path: "/steps", Message: "required array"})
+	}
+	for i, it := range steps {
+		path := fmt.Sprintf("/steps/%d", i)
+		m, ok := it.(map[string]any)
+		if !ok {
+			issues = append(issues, SpecIssue{Path: path, Message: "must be object"})
+			continue
+		}
+		if _, err := ParseActionStep(m); err != nil {
+			issues = append(issues, SpecIssue{Path: path, Message: err.Error()})
+		}
+	}
+	for _, key := range []string{"setup", "teardown"} {
+		v, ok := doc[key]
+		if !ok || v == nil {
+			continue
+		}
+		fixture, isArr := v.([]any)
+		if !isArr {
+			issues = append(issues, SpecIssue{Path: "/" + key, Message: "must be array"})
+		}
+		for i, it := range fixture {
+			path := fmt.Sprintf("/%s/%d", key, i)
+			m, ok := it.(map[string]any)
+			if !ok {
+				issues = append(issues, SpecIssue{Path: path, Message: "must be object"})
+				continue
+			}
+			st, err := ParseActionStep(m)
+			if err != nil {
+				issues = append(issues, SpecIssue{Path: path, Message: err.Error()})
+			} else if st.UI != nil {
+				issues = append(issues, SpecIssue{Path: path, Message: "fixtures cannot have ui steps"})
+			}
+		}
+	}
+	if v, ok := doc["net_rules"]; ok && v != nil {
+		rules, isArr := v.([]any)
+		if !isArr {
+			issues = append(issues, SpecIssue{Path: "/net_rules", Message: "must be array"})
+		}
+		for i, it := range rules {
+			path := fmt.Sprintf("/net_rules/%d", i)
+			m, ok := it.(map[string]any)
+			if !ok {
+				issues = append(issues, SpecIssue{Path: path, Message: "must be object"})
+				continue
+			}
+			if err := decodeFields("net rule", m, &NetRule{}); err != nil {
+				issues = append(issues, SpecIssue{Path: path, Message: err.Error()})
+			}
+		}
+	}
+	if v, ok := doc["net"]; ok && v != nil {
+		m, isObj := v.(map[string]any)
+		if !isObj {
+			issues = append(issues, SpecIssue{Path: "/net", Message: "must be object"})
+		} else if _, err := DecodeStepLayer("net", m); err != nil {
+			issues = append(issues, SpecIssue{Path: "/net", Message: err.Error()})
+		} else if _, hasOp := m["op"]; hasOp {
+			issues = append(issues, SpecIssue{Path: "/net", Message: "top-level net only takes must"})
+		}
+	}
+	if v, ok := doc["isolation"]; ok && v != nil && v != "" && v != IsolationRollback {
+		issues = append(issues, SpecIssue{Path: "/isolation", Message: "must be empty or " + IsolationRollback})
+	}
+
+	if v, ok := doc["db_checks"]; ok && v != nil {
+		checks, isArr := v.([]any)
+		if !isArr {
+			issues = append(issues, SpecIssue{Path: "/db_checks", Message: "must be array"})
+		}
+		for i, it := range checks {
+			path := fmt.Sprintf("/db_checks/%d", i)
+			m, ok := it.(map[string]any)
+			if !ok {
+				issues = append(issues, SpecIssue{Path: path, Message: "must be object"})
+				continue
+			}
+			if err := validateDbCheckMap(m); err != nil {
+				issues = append(issues, SpecIssue{Path: path, Message: err.Error()})
+			}
+		}
+	}
+	return issues
+}
+
+func validateDbCheckMap(raw map[string]any) error {
+	allowed := jsonFieldNames(DbCheck{})
+	for k := range raw {
+		if !containsString(allowed, k) {
+			return fmt.Errorf("unknown db check field %q; allowed fields: %s", k, strings.Join(allowed, ", "))
+		}
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	var check DbCheck
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&check); err != nil {
+		return err
+	}
+	return check.Validate()
+}
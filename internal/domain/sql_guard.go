@@ -0,0 +1,211 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlStatement is one statement of a SQL text: its leading keyword and the
+// upper-cased words outside literals, quoted identifiers and comments.
+type sqlStatement struct {
+	keyword string
+	words   []string
+}
+
+// readKeywords lead statements that only read.
+var readKeywords = map[string]bool{
+	"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true,
+	"SHOW": true, "EXPLAIN": true, "DESCRIBE": true, "DESC": true,
+}
+
+// writeWords modify data or schema wherever they appear in a read statement:
+// data-modifying CTEs, SELECT ... INTO, EXPLAIN ANALYZE DELETE, locking reads.
+var writeWords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"INTO": true, "CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true,
+	"GRANT": true, "REVOKE": true,
+}
+
+// sqlDialect is how literals and comments are lexed. Backslash escapes and #
+// comments are MySQL's; $tag$ bodies are PostgreSQL's.
+type sqlDialect struct {
+	backslash bool
+}
+
+// sqlDialects are the readings a statement has to be safe under: text hidden
+// in a literal by one dialect may be live SQL in the other.
+var sqlDialects = []sqlDialect{{backslash: false}, {backslash: true}}
+
+// parse splits text into statements. It understands '...' and "..."
+// literals, `...` identifiers, $tag$ bodies and comments, which is enough to
+// classify statements, not to validate them.
+func (d sqlDialect) parse(text string) ([]sqlStatement, error) {
+	out := []sqlStatement{}
+	cur := sqlStatement{}
+	flush := func() {
+		if len(cur.words) > 0 {
+			cur.keyword = cur.words[0]
+			out = append(out, cur)
+		}
+		cur = sqlStatement{}
+	}
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ';':
+			flush()
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			end := closingQuote(text, i+1, c, d.backslash && c != '`')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c at offset %d", c, i)
+			}
+			i = end + 1
+		case c == '-' && strings.HasPrefix(text[i:], "--"), c == '#' && d.backslash:
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				i = len(text)
+			} else {
+				i += end + 1
+			}
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			i += end + 4
+		case c == '$' && !d.backslash && dollarTag(text[i:]) != "":
+			tag := dollarTag(text[i:])
+			end := strings.Index(text[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %s body at offset %d", tag, i)
+			}
+			i += len(tag) + end + len(tag)
+		case isWordByte(c):
+			j := i
+			for j < len(text) && (isWordByte(text[j]) || text[j] >= '0' && text[j] <= '9') {
+				j++
+			}
+			cur.words = append(cur.words, strings.ToUpper(text[i:j]))
+			i = j
+		case c >= '0' && c <= '9':
+			for i < len(text) && (isWordByte(text[i]) || text[i] >= '0' && text[i] <= '9' || text[i] == '.') {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	flush()
+	return out, nil
+}
+
+// closingQuote finds the quote ending a literal opened before from; doubled
+// quotes, and backslash escapes when enabled, stay inside it.
+func closingQuote(text string, from int, q byte, backslash bool) int {
+	for i := from; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case q:
+			if i+1 < len(text) && text[i+1] == q {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// dollarTag returns the $tag$ opening a PostgreSQL dollar-quoted body, or ""
+// for placeholders such as $1 and ${var}.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case isWordByte(s[i]), i > 1 && s[i] >= '0' && s[i] <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// readOnly reports why the statement may write, or "" when it only reads.
+func (st sqlStatement) readOnly() string {
+	if !readKeywords[st.keyword] {
+		return st.keyword + " statement"
+	}
+	for i, w := range st.words {
+		if !writeWords[w] {
+			continue
+		}
+		if w == "UPDATE" && i > 0 && st.words[i-1] == "FOR" {
+			return "locking clause FOR UPDATE"
+		}
+		return w + " inside " + st.keyword
+	}
+	return ""
+}
+
+// checkSQL runs check on the statements of text in every dialect that can
+// lex it; text no dialect can lex is rejected.
+func checkSQL(text string, check func([]sqlStatement) error) error {
+	var parseErr error
+	parsed := false
+	for _, d := range sqlDialects {
+		stmts, err := d.parse(text)
+		if err != nil {
+			parseErr = err
+			continue
+		}
+		parsed = true
+		if err := check(stmts); err != nil {
+			return err
+		}
+	}
+	if !parsed {
+		return parseErr
+	}
+	return nil
+}
+
+// CheckReadOnlySQL fails when any statement of text may write.
+func CheckReadOnlySQL(text string) error {
+	return checkSQL(text, func(stmts []sqlStatement) error {
+		for _, st := range stmts {
+			if why := st.readOnly(); why != "" {
+				return fmt.Errorf("%s is not read-only", why)
+			}
+		}
+		return nil
+	})
+}
+
+// CheckSelectSQL fails unless text is a single SELECT (or WITH ... SELECT)
+// that only reads, as DB checks must be.
+func CheckSelectSQL(text string) error {
+	return checkSQL(text, checkSelect)
+}
+
+func checkSelect(stmts []sqlStatement) error {
+	if len(stmts) != 1 {
+		return fmt.Errorf("db check sql must be a single statement, got %d", len(stmts))
+	}
+	st := stmts[0]
+	if st.keyword != "SELECT" && st.keyword != "WITH" {
+		return fmt.Errorf("db check sql must be a SELECT, got %s", st.keyword)
+	}
+	if why := st.readOnly(); why != "" {
+		return fmt.Errorf("db check sql must only read: %s", why)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		err  string
+	}{
+		{"select", "SELECT 1", ""},
+		{"several reads", "select * from t; SHOW TABLES; explain select 1", ""},
+		{"lower-case write", "delete from t", "DELETE statement"},
+		{"write after read", "SELECT 1; DROP TABLE t", "DROP statement"},
+		{"data-modifying CTE", "WITH x AS (DELETE FROM t RETURNING *) SELECT * FROM x", "DELETE inside WITH"},
+		{"select into", "SELECT * INTO backup FROM t", "INTO inside SELECT"},
+		{"explain analyze", "EXPLAIN ANALYZE DELETE FROM t", "DELETE inside EXPLAIN"},
+		{"locking read", "SELECT * FROM t FOR UPDATE", "FOR UPDATE"},
+		{"keyword in literal", "SELECT 'DELETE FROM t; DROP TABLE t'", ""},
+		{"doubled quote", "SELECT 'it''s; DROP TABLE t'", ""},
+		{"double-quoted identifier", `SELECT "delete", "update" FROM t`, ""},
+		{"backquoted identifier", "SELECT `delete` FROM `drop`", ""},
+		{"doubled backquote", "SELECT `a``; DROP TABLE t` FROM t", ""},
+		{"line comment", "SELECT 1 -- ; DROP TABLE t", ""},
+		{"block comment", "SELECT 1 /* ; DROP TABLE t */", ""},
+		{"write after block comment", "SELECT 1 /* c */; DROP TABLE t", "DROP statement"},
+		{"hash comment is live SQL outside MySQL", "SELECT 1 # ; DROP TABLE t", "DROP statement"},
+		{"backslash escape hides a write from PostgreSQL", `SELECT 'a\'; DROP TABLE t; -- '`, "DROP statement"},
+		{"backslash escape read by MySQL only", `SELECT 'it\'s'`, ""},
+		{"dollar-quoted body", "SELECT $$it's$$", ""},
+		{"tagged dollar-quoted body", "SELECT $fn$it's$fn$", ""},
+		{"dollar body is live SQL outside PostgreSQL", "SELECT $$; DROP TABLE t$$", "DROP statement"},
+		{"placeholders are not dollar quotes", "SELECT * FROM t WHERE id = $1 AND name = ${name}", ""},
+		{"unterminated literal", "SELECT 'a", "unterminated '"},
+		{"unterminated comment", "SELECT 1 /* c", "unterminated comment"},
+		{"unterminated dollar body still lexes as MySQL", "SELECT $$a", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckReadOnlySQL(tt.sql)
+			checkSQLErr(t, tt.sql, err, tt.err)
+		})
+	}
+}
+
+func TestCheckSelectSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		err  string
+	}{
+		{"select", "SELECT COUNT(*) FROM t WHERE id = :id", ""},
+		{"with select", "WITH a AS (SELECT 1) SELECT * FROM a", ""},
+		{"trailing semicolon and comment", "SELECT 1; -- done", ""},
+		{"show", "SHOW TABLES", "must be a SELECT, got SHOW"},
+		{"two statements", "SELECT 1; SELECT 2", "single statement, got 2"},
+		{"semicolon in literal", "SELECT ';'", ""},
+		{"empty", "  -- nothing", "single statement, got 0"},
+		{"locking read", "SELECT * FROM t FOR UPDATE", "must only read: locking clause FOR UPDATE"},
+		{"update", "UPDATE t SET v = 1", "must be a SELECT, got UPDATE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSelectSQL(tt.sql)
+			checkSQLErr(t, tt.sql, err, tt.err)
+		})
+	}
+}
+
+func checkSQLErr(t *testing.T, sql string, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", sql, err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("%q: error = %v, want it to contain %q", sql, err, want)
+	}
+}
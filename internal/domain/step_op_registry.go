@@ -0,0 +1,237 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// StepLayers are the keys of an ActionStep that carry an op, in the order the
+// runner resolves them.
+var StepLayers = []string{"ui", "db", "util", "net"}
+
+var stepOpRegistry = map[string]func() StepOp{
+	"util.gen_id":            func() StepOp { return &UtilGenID{} },
+	"util.gen_ts":            func() StepOp { return &UtilGenTS{} },
+	"db.exec":                func() StepOp { return &DBExec{} },
+	"net.call":               func() StepOp { return &NetCall{} },
+	"ui.goto":                func() StepOp { return &UIGoto{} },
+	"ui.hash_navigate":       func() StepOp { return &UIHashNavigate{} },
+	"ui.eval":                func() StepOp { return &UIEval{} },
+	"ui.picker_select":       func() StepOp { return &UIPickerSelect{} },
+	"ui.click":               func() StepOp { return &UIClick{} },
+	"ui.fill":                func() StepOp { return &UIFill{} },
+	"ui.click_text":          func() StepOp { return &UIClickText{} },
+	"ui.wait_text":           func() StepOp { return &UIWaitText{} },
+	"ui.wait_selector":       func() StepOp { return &UIWaitSelector{} },
+	"ui.fill_form":           func() StepOp { return &UIFillForm{} },
+	"ui.wait_ms":             func() StepOp { return &UIWaitMS{} },
+	"ui.verify_url_contains": func() StepOp { return &UIVerifyURLContains{} },
+}
+
+// StepOpNames lists the registered ops of a layer ("" for all layers), sorted.
+func StepOpNames(layer string) []string {
+	out := []string{}
+	for name := range stepOpRegistry {
+		if layer == "" || strings.HasPrefix(name, layer+".") {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NewStepOp returns an empty typed value for a registered op.
+func NewStepOp(op string) (StepOp, bool) {
+	newOp, ok := stepOpRegistry[op]
+	if !ok {
+		return nil, false
+	}
+	return newOp(), true
+}
+
+// DecodeStepLayer converts the raw map stored under a step layer into its typed
+// op and validates it. Unknown ops and fields are rejected with the allowed set.
+func DecodeStepLayer(layer string, raw map[string]any) (StepOp, error) {
+	op, _ := raw["op"].(string)
+	var v StepOp
+	if op == "" {
+		if layer != "net" {
+			return nil, fmt.Errorf("%s: missing op; allowed ops: %s", layer, strings.Join(StepOpNames(layer), ", "))
+		}
+		v = &NetExpect{}
+		op = "net"
+	} else {
+		newOp, ok := stepOpRegistry[op]
+		if !ok || !strings.HasPrefix(op, layer+".") {
+			return nil, fmt.Errorf("%s: unknown op %q; allowed ops: %s", layer, op, strings.Join(StepOpNames(layer), ", "))
+		}
+		v = newOp()
+	}
+
+	fields := map[string]any{}
+	for k, val := range raw {
+		if k != "op" {
+			fields[k] = val
+		}
+	}
+	if err := decodeFields(op, fields, v); err != nil {
+		return nil, err
+	}
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeFields decodes raw into v, rejecting fields v does not declare with
+// the allowed set.
+func decodeFields(name string, raw map[string]any, v any) error {
+	allowed := jsonFieldNames(v)
+	for k := range raw {
+		if !containsString(allowed, k) {
+			return fmt.Errorf("%s: unknown field %q; allowed fields: %s", name, k, strings.Join(allowed, ", "))
+		}
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Expectations decodes the expect layer of the step, or returns nil when it
+// has none.
+func (s *ActionStep) Expectations() (*StepExpect, error) {
+	if s.Expect == nil {
+		return nil, nil
+	}
+	e := &StepExpect{}
+	if err := decodeFields("expect", s.Expect, e); err != nil {
+		return nil, err
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *ActionStep) layer(name string) map[string]any {
+	switch name {
+	case "ui":
+		return s.UI
+	case "db":
+		return s.DB
+	case "util":
+		return s.Util
+	case "net":
+		return s.Net
+	default:
+		return nil
+	}
+}
+
+// Ops decodes every layer set on the step, in runner resolution order.
+func (s *ActionStep) Ops() ([]StepOp, error) {
+	out := []StepOp{}
+	for _, name := range StepLayers {
+		raw := s.layer(name)
+		if raw == nil {
+			continue
+		}
+		op, err := DecodeStepLayer(name, raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, op)
+	}
+	return out, nil
+}
+
+// Op returns the op the runner executes for the step, or nil when the step
+// only carries net expectations.
+func (s *ActionStep) Op() (StepOp, error) {
+	ops, err := s.Ops()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if _, ok := op.(*NetExpect); !ok {
+			return op, nil
+		}
+	}
+	return nil, nil
+}
+
+// NetRules returns the net.must rules attached to the step.
+func (s *ActionStep) NetRules() ([]NetRule, error) {
+	if s.Net == nil {
+		return nil, nil
+	}
+	op, err := DecodeStepLayer("net", s.Net)
+	if err != nil {
+		return nil, err
+	}
+	switch v := op.(type) {
+	case *NetExpect:
+		return v.Must, nil
+	case *NetCall:
+		return v.Must, nil
+	}
+	return nil, nil
+}
+
+// Validate checks that every layer, expect included, decodes and that at most
+// one layer carries an op, since the runner would silently skip the others.
+func (s *ActionStep) Validate() error {
+	ops, err := s.Ops()
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, op := range ops {
+		if _, ok := op.(*NetExpect); !ok {
+			names = append(names, op.OpName())
+		}
+	}
+	if len(names) > 1 {
+		return fmt.Errorf("step has multiple ops (%s); split them into separate steps", strings.Join(names, ", "))
+	}
+	if _, err := s.Expectations(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func jsonFieldNames(v any) []string {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	out := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag != "" && tag != "-" {
+			out = append(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func containsString(arr []string, s string) bool {
+	for _, it := range arr {
+		if it == s {
+			return true
+		}
+	}
+	return false
+}
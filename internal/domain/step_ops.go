@@ -0,0 +1,303 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// StepOp is the typed form of one layer (ui/db/util/net) of an ActionStep.
+// There is one implementation per op the Node runner supports.
+type StepOp interface {
+	OpName() string
+	Validate() error
+}
+
+// ---- util ----
+
+type UtilGenID struct {
+	Key string `json:"key" schema:"required"`
+}
+
+func (o *UtilGenID) OpName() string { return "util.gen_id" }
+func (o *UtilGenID) Validate() error {
+	if o.Key == "" {
+		return errors.New("util.gen_id requires key")
+	}
+	return nil
+}
+
+type UtilGenTS struct {
+	Key string `json:"key" schema:"required"`
+}
+
+func (o *UtilGenTS) OpName() string { return "util.gen_ts" }
+func (o *UtilGenTS) Validate() error {
+	if o.Key == "" {
+		return errors.New("util.gen_ts requires key")
+	}
+	return nil
+}
+
+// ---- db ----
+
+// DBExec runs a statement; DMS names the project datasource it runs against
+// (the Node runner only knows the MYSQL_* database).
+type DBExec struct {
+	SQL    string            `json:"sql" schema:"required"`
+	Params map[string]string `json:"params,omitempty"`
+	DMS    string            `json:"dms,omitempty"`
+}
+
+func (o *DBExec) OpName() string { return "db.exec" }
+func (o *DBExec) Validate() error {
+	if o.SQL == "" {
+		return errors.New("db.exec requires sql")
+	}
+	return nil
+}
+
+// ---- net ----
+
+type NetAnchor struct {
+	Key      string `json:"key" schema:"required"`
+	JSONPath string `json:"jsonpath" schema:"required"`
+}
+
+type NetRequire struct {
+	Message  string `json:"message,omitempty"`
+	NeedUnit string `json:"need_unit,omitempty"`
+}
+
+// NetRule is one entry of net.must: a response that has to be observed while
+// the step list runs. CaptureAnchors and Anchors are aliases in the runner.
+type NetRule struct {
+	Method         string            `json:"method,omitempty"`
+	URLContains    string            `json:"url_contains,omitempty"`
+	Status         json.Number       `json:"status,omitempty"`
+	ExpectJSON     map[string]any    `json:"expect_json,omitempty"`
+	ExpectJSONPath map[string]any    `json:"expect_jsonpath,omitempty"`
+	Anchor         *NetAnchor        `json:"anchor,omitempty"`
+	CaptureAnchors map[string]string `json:"capture_anchors,omitempty"`
+	Anchors        map[string]string `json:"anchors,omitempty"`
+}
+
+type NetCall struct {
+	URL            string         `json:"url" schema:"required"`
+	Method         string         `json:"method,omitempty"`
+	Headers        map[string]any `json:"headers,omitempty"`
+	JSON           any            `json:"json,omitempty"`
+	Form           map[string]any `json:"form,omitempty"`
+	Status         json.Number    `json:"status,omitempty"`
+	ExpectJSON     map[string]any `json:"expect_json,omitempty"`
+	ExpectJSONPath map[string]any `json:"expect_jsonpath,omitempty"`
+	// ExpectPresent lists JSONPaths that must resolve to a non-null value.
+	ExpectPresent []string    `json:"expect_present,omitempty"`
+	Anchor        *NetAnchor  `json:"anchor,omitempty"`
+	Require       *NetRequire `json:"require,omitempty"`
+	Must          []NetRule   `json:"must,omitempty"`
+}
+
+func (o *NetCall) OpName() string { return "net.call" }
+func (o *NetCall) Validate() error {
+	if o.URL == "" {
+		return errors.New("net.call requires url")
+	}
+	if o.Anchor != nil && (o.Anchor.Key == "" || o.Anchor.JSONPath == "") {
+		return errors.New("net.call anchor requires key and jsonpath")
+	}
+	return nil
+}
+
+// NetExpect is a net layer without op: only response expectations that are
+// checked while the other layers of the step list run.
+type NetExpect struct {
+	Must []NetRule `json:"must" schema:"required"`
+}
+
+func (o *NetExpect) OpName() string { return "net.must" }
+func (o *NetExpect) Validate() error {
+	if len(o.Must) == 0 {
+		return errors.New("net without op requires a non-empty must list")
+	}
+	return nil
+}
+
+// ---- ui ----
+
+type UIGoto struct {
+	URL string `json:"url" schema:"required"`
+}
+
+func (o *UIGoto) OpName() string { return "ui.goto" }
+func (o *UIGoto) Validate() error {
+	if o.URL == "" {
+		return errors.New("ui.goto requires url")
+	}
+	return nil
+}
+
+type UIHashNavigate struct {
+	Hash   string      `json:"hash" schema:"required"`
+	WaitMS json.Number `json:"wait_ms,omitempty"`
+}
+
+func (o *UIHashNavigate) OpName() string { return "ui.hash_navigate" }
+func (o *UIHashNavigate) Validate() error {
+	if o.Hash == "" {
+		return errors.New("ui.hash_navigate requires hash")
+	}
+	return nil
+}
+
+type UIEval struct {
+	Code   string      `json:"code" schema:"required"`
+	WaitMS json.Number `json:"wait_ms,omitempty"`
+}
+
+func (o *UIEval) OpName() string { return "ui.eval" }
+func (o *UIEval) Validate() error {
+	if o.Code == "" {
+		return errors.New("ui.eval requires code")
+	}
+	return nil
+}
+
+type UIPickerSelect struct {
+	Index  json.Number `json:"index,omitempty"`
+	WaitMS json.Number `json:"wait_ms,omitempty"`
+}
+
+func (o *UIPickerSelect) OpName() string  { return "ui.picker_select" }
+func (o *UIPickerSelect) Validate() error { return nil }
+
+type UIClick struct {
+	Selector  string      `json:"selector,omitempty"`
+	Role      string      `json:"role,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	UseEval   bool        `json:"use_eval,omitempty"`
+	TimeoutMS json.Number `json:"timeout_ms,omitempty"`
+}
+
+func (o *UIClick) OpName() string { return "ui.click" }
+func (o *UIClick) Validate() error {
+	if o.Selector == "" && (o.Role == "" || o.Name == "") {
+		return errors.New("ui.click requires selector or role+name")
+	}
+	return nil
+}
+
+type UIFill struct {
+	Value    string      `json:"value" schema:"required"`
+	Selector string      `json:"selector,omitempty"`
+	Label    string      `json:"label,omitempty"`
+	Index    json.Number `json:"index,omitempty"`
+	Textarea bool        `json:"textarea,omitempty"`
+	UseEval  bool        `json:"use_eval,omitempty"`
+}
+
+func (o *UIFill) OpName() string { return "ui.fill" }
+func (o *UIFill) Validate() error {
+	if o.Selector == "" && o.Label == "" && o.Index == "" && !o.Textarea {
+		return errors.New("ui.fill requires selector, label, index, or textarea")
+	}
+	return nil
+}
+
+type UIClickText struct {
+	Text  string `json:"text" schema:"required"`
+	Exact *bool  `json:"exact,omitempty"`
+}
+
+func (o *UIClickText) OpName() string { return "ui.click_text" }
+func (o *UIClickText) Validate() error {
+	if o.Text == "" {
+		return errors.New("ui.click_text requires text")
+	}
+	return nil
+}
+
+type UIWaitText struct {
+	Text      string      `json:"text" schema:"required"`
+	Exact     *bool       `json:"exact,omitempty"`
+	TimeoutMS json.Number `json:"timeout_ms,omitempty"`
+}
+
+func (o *UIWaitText) OpName() string { return "ui.wait_text" }
+func (o *UIWaitText) Validate() error {
+	if o.Text == "" {
+		return errors.New("ui.wait_text requires text")
+	}
+	return nil
+}
+
+type UIWaitSelector struct {
+	Selector  string      `json:"selector" schema:"required"`
+	State     string      `json:"state,omitempty"`
+	TimeoutMS json.Number `json:"timeout_ms,omitempty"`
+}
+
+func (o *UIWaitSelector) OpName() string { return "ui.wait_selector" }
+func (o *UIWaitSelector) Validate() error {
+	if o.Selector == "" {
+		return errors.New("ui.wait_selector requires selector")
+	}
+	switch o.State {
+	case "", "attached", "detached", "visible", "hidden":
+		return nil
+	default:
+		return errors.New("ui.wait_selector state must be one of attached, detached, visible, hidden")
+	}
+}
+
+type UIFillForm struct {
+	Selector  string      `json:"selector" schema:"required"`
+	Value     any         `json:"value" schema:"required"`
+	TimeoutMS json.Number `json:"timeout_ms,omitempty"`
+}
+
+func (o *UIFillForm) OpName() string { return "ui.fill_form" }
+func (o *UIFillForm) Validate() error {
+	if o.Selector == "" || o.Value == nil {
+		return errors.New("ui.fill_form requires selector and value")
+	}
+	return nil
+}
+
+type UIWaitMS struct {
+	MS json.Number `json:"ms,omitempty"`
+}
+
+func (o *UIWaitMS) OpName() string  { return "ui.wait_ms" }
+func (o *UIWaitMS) Validate() error { return nil }
+
+type UIVerifyURLContains struct {
+	URLContains string `json:"url_contains" schema:"required"`
+}
+
+func (o *UIVerifyURLContains) OpName() string { return "ui.verify_url_contains" }
+func (o *UIVerifyURLContains) Validate() error {
+	if o.URLContains == "" {
+		return errors.New("ui.verify_url_contains requires url_contains")
+	}
+	return nil
+}
+
+// ---- expect ----
+
+// ExpectConsoleNoError asks that the page logs no console error during the
+// step.
+const ExpectConsoleNoError = "no_error"
+
+// StepExpect is the expect layer of a step: checks on the page that hold
+// after its op.
+type StepExpect struct {
+	Console string `json:"console,omitempty"`
+}
+
+func (e *StepExpect) Validate() error {
+	if e.Console != "" && e.Console != ExpectConsoleNoError {
+		return fmt.Errorf("expect: console must be %s, got %q", ExpectConsoleNoError, e.Console)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// placeholderPattern matches ${name} exactly like the runner's substitute().
+var placeholderPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+const (
+	VariableUndefined         = "undefined"
+	VariableUsedBeforeDefined = "used_before_defined"
+)
+
+// VariableIssue reports a ${name} placeholder that the runner would replace
+// with an empty string.
+type VariableIssue struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	DefinedAt string `json:"defined_at,omitempty"`
+}
+
+// Placeholders returns the distinct ${name} placeholders found in s.
+func Placeholders(s string) []string {
+	out := []string{}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		if !containsString(out, m[1]) {
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+// StepDefines lists the context keys a step adds when it runs: util.gen_id /
+// util.gen_ts keys and anchors captured from net responses.
+func StepDefines(step *ActionStep) []string {
+	out := []string{}
+	ops, err := step.Ops()
+	if err != nil {
+		return out
+	}
+	addRules := func(rules []NetRule) {
+		for _, r := range rules {
+			if r.Anchor != nil && r.Anchor.Key != "" {
+				out = append(out, r.Anchor.Key)
+			}
+			for k := range r.CaptureAnchors {
+				out = append(out, k)
+			}
+			for k := range r.Anchors {
+				out = append(out, k)
+			}
+		}
+	}
+	for _, op := range ops {
+		switch v := op.(type) {
+		case *UtilGenID:
+			out = append(out, v.Key)
+		case *UtilGenTS:
+			out = append(out, v.Key)
+		case *NetCall:
+			if v.Anchor != nil {
+				out = append(out, v.Anchor.Key)
+			}
+			addRules(v.Must)
+		case *NetExpect:
+			addRules(v.Must)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AnalyzeVariables resolves every placeholder of a run in execution order.
+// Variables, unit env, recorded anchors and keys produced by prerequisites are
+// available from the start; step-produced keys become available after their
+// step. Setup steps run first, then the steps and DB checks, then teardown.
+func AnalyzeVariables(env map[string]any, run *Run, prerequisiteKeys []string) []VariableIssue {
+	defined := map[string]bool{}
+	for _, k := range prerequisiteKeys {
+		defined[k] = true
+	}
+	for k := range run.Variables {
+		defined[k] = true
+	}
+	for k := range env {
+		defined[k] = true
+	}
+	for k := range run.Anchors {
+		defined[k] = true
+	}
+
+	// Where each step-produced key is first defined, for used-before-defined reports.
+	producedAt := map[string]string{}
+	for _, list := range []struct {
+		path  string
+		steps []*ActionStep
+	}{{"/setup", run.Setup}, {"/steps", run.Steps}, {"/teardown", run.Teardown}} {
+		for i, step := range list.steps {
+			for _, k := range StepDefines(step) {
+				if _, ok := producedAt[k]; !ok {
+					producedAt[k] = fmt.Sprintf("%s/%d", list.path, i)
+				}
+			}
+		}
+	}
+
+	issues := []VariableIssue{}
+	check := func(path string, v any) {
+		walkStrings(path, v, func(p, s string) {
+			for _, name := range Placeholders(s) {
+				if defined[name] {
+					continue
+				}
+				issue := VariableIssue{Name: name, Kind: VariableUndefined, Path: p}
+				if at, ok := producedAt[name]; ok {
+					issue.Kind = VariableUsedBeforeDefined
+					issue.DefinedAt = at
+				}
+				issues = append(issues, issue)
+			}
+		})
+	}
+
+	steps := func(path string, list []*ActionStep) {
+		for i, step := range list {
+			base := fmt.Sprintf("%s/%d", path, i)
+			check(base+"/ui", step.UI)
+			check(base+"/db", step.DB)
+			check(base+"/util", step.Util)
+			check(base+"/net", step.Net)
+			for _, k := range StepDefines(step) {
+				defined[k] = true
+			}
+		}
+	}
+	steps("/setup", run.Setup)
+	steps("/steps", run.Steps)
+	for i, c := range run.DBChecks {
+		base := fmt.Sprintf("/db_checks/%d", i)
+		check(base+"/params", c.Params)
+		check(base+"/assert", c.Assert)
+		check(base+"/row_count", c.RowCount)
+		check(base+"/expect_rows", c.ExpectRows)
+	}
+	steps("/teardown", run.Teardown)
+
+	return issues
+}
+
+// walkStrings calls fn for every string inside v, with its JSON pointer path.
+func walkStrings(path string, v any, fn func(path, s string)) {
+	switch t := v.(type) {
+	case string:
+		fn(path, t)
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkStrings(path+"/"+k, t[k], fn)
+		}
+	case map[string]string:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fn(path+"/"+k, t[k])
+		}
+	case []any:
+		for i, it := range t {
+			walkStrings(fmt.Sprintf("%s/%d", path, i), it, fn)
+		}
+	case []map[string]any:
+		for i, it := range t {
+			walkStrings(fmt.Sprintf("%s/%d", path, i), it, fn)
+		}
+	}
+}
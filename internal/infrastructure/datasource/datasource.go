@@ -0,0 +1,235 @@
+// Package datasource opens the databases named in a project config and binds
+// the :name parameters of spec SQL in each driver's placeholder style.
+package datasource
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+// Driver adapts one database/sql driver.
+type Driver struct {
+	// SQLName is the name the driver registers with database/sql.
+	SQLName string
+	// Placeholder renders the n-th (1-based) bind parameter.
+	Placeholder func(n int) string
+}
+
+var drivers = map[string]Driver{
+	domain.DriverMySQL:    {SQLName: "mysql", Placeholder: func(int) string { return "?" }},
+	domain.DriverPostgres: {SQLName: "postgres", Placeholder: func(n int) string { return "$" + strconv.Itoa(n) }},
+	domain.DriverSQLite:   {SQLName: "sqlite3", Placeholder: func(int) string { return "?" }},
+}
+
+// namedParam matches :name parameters; a leading second colon (PostgreSQL
+// ::type casts) is matched too so the cast can be left alone.
+var namedParam = regexp.MustCompile(`::?[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// DB is an open datasource.
+type DB struct {
+	*sql.DB
+	Name       string
+	Datasource domain.Datasource
+	driver     Driver
+}
+
+// Open opens a datasource whose DSN has already been expanded. Connections
+// are established lazily by database/sql.
+func Open(name string, ds domain.Datasource) (*DB, error) {
+	if err := ds.Validate(); err != nil {
+		return nil, fmt.Errorf("datasource %s: %w", name, err)
+	}
+	d := drivers[domain.NormalizeDriver(ds.Driver)]
+	if d.SQLName == "mysql" {
+		cfg, err := mysql.ParseDSN(ds.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("datasource %s: %w", name, err)
+		}
+		ds.DSN = mysqlDSN(cfg)
+	}
+	db, err := sql.Open(d.SQLName, ds.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("open datasource %s: %w", name, err)
+	}
+	return &DB{DB: db, Name: name, Datasource: ds, driver: d}, nil
+}
+
+// MySQLDSN builds the DSN of the MySQL database the MYSQL_* settings name,
+// with the options of configured MySQL datasources.
+func MySQLDSN(host, port, user, password, database string) string {
+	cfg := mysql.NewConfig()
+	cfg.Net, cfg.Addr = "tcp", net.JoinHostPort(host, port)
+	cfg.User, cfg.Passwd, cfg.DBName = user, password, database
+	return mysqlDSN(cfg)
+}
+
+// mysqlDSN applies the options every MySQL connection uses: timestamps stay
+// the text the server returns in its session zone (parseTime off, see
+// DB.Location), and the charset defaults to utf8mb4 like the Node runner.
+func mysqlDSN(cfg *mysql.Config) string {
+	cfg.ParseTime = false
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	if _, ok := cfg.Params["charset"]; !ok {
+		cfg.Params["charset"] = "utf8mb4"
+	}
+	return cfg.FormatDSN()
+}
+
+// MySQLEnv turns a MySQL DSN into the MYSQL_* settings of the Node runner.
+func MySQLEnv(dsn string) (map[string]string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Net != "tcp" {
+		return nil, fmt.Errorf("the node runner only connects over tcp, not %s", cfg.Net)
+	}
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"MYSQL_HOST":     host,
+		"MYSQL_PORT":     port,
+		"MYSQL_USER":     cfg.User,
+		"MYSQL_PASSWORD": cfg.Passwd,
+		"MYSQL_DATABASE": cfg.DBName,
+	}, nil
+}
+
+// Bind rewrites :name parameters to the driver's placeholders and returns the
+// values of params in order, each passed through sub.
+func (db *DB) Bind(query string, params map[string]string, sub func(string) string) (string, []any) {
+	args := []any{}
+	out := namedParam.ReplaceAllStringFunc(query, func(m string) string {
+		if m[1] == ':' {
+			return m
+		}
+		args = append(args, sub(params[m[1:]]))
+		return db.driver.Placeholder(len(args))
+	})
+	return out, args
+}
+
+// Location returns the zone of timestamps the database returns without one:
+// the configured TimeZone, else the session zone of MySQL and PostgreSQL, and
+// UTC for SQLite, whose CURRENT_TIMESTAMP is UTC.
+func (db *DB) Location() (*time.Location, error) {
+	if db.Datasource.TimeZone != "" {
+		return time.LoadLocation(db.Datasource.TimeZone)
+	}
+	var q string
+	switch domain.NormalizeDriver(db.Datasource.Driver) {
+	case domain.DriverMySQL:
+		q = "SELECT TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), NOW())"
+	case domain.DriverPostgres:
+		q = "SELECT EXTRACT(TIMEZONE FROM now())::int"
+	default:
+		return time.UTC, nil
+	}
+	var offset int
+	if err := db.QueryRow(q).Scan(&offset); err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		return time.UTC, nil
+	}
+	return time.FixedZone("", offset), nil
+}
+
+// PrimaryKey returns the primary key columns of table in key order, or none
+// when the table has no primary key. table may be schema-qualified and must
+// already be a validated identifier.
+func (db *DB) PrimaryKey(table string) ([]string, error) {
+	schema, name, qualified := strings.Cut(table, ".")
+	if !qualified {
+		schema, name = "", table
+	}
+	var rows *sql.Rows
+	var err error
+	switch domain.NormalizeDriver(db.Datasource.Driver) {
+	case domain.DriverMySQL:
+		q := `SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE
+			WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+			ORDER BY ORDINAL_POSITION`
+		rows, err = db.Query(q, schema, name)
+	case domain.DriverPostgres:
+		q := `SELECT a.attname FROM pg_index i
+			JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+			WHERE i.indrelid = $1::regclass AND i.indisprimary
+			ORDER BY array_position(i.indkey::int2[], a.attnum)`
+		rows, err = db.Query(q, table)
+	case domain.DriverSQLite:
+		q := "PRAGMA table_info(" + name + ")"
+		if qualified {
+			q = "PRAGMA " + schema + ".table_info(" + name + ")"
+		}
+		return db.sqlitePrimaryKey(q, table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []string{}
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		out = append(out, col)
+	}
+	return out, rows.Err()
+}
+
+// sqlitePrimaryKey reads the pk position column of PRAGMA table_info.
+func (db *DB) sqlitePrimaryKey(q, table string) ([]string, error) {
+	rows, err := db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type keyCol struct {
+		name string
+		pos  int
+	}
+	cols := []keyCol{}
+	seen := 0
+	for rows.Next() {
+		seen++
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		if pk > 0 {
+			cols = append(cols, keyCol{name, pk})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if seen == 0 {
+		return nil, fmt.Errorf("no such table: %s", table)
+	}
+	sort.Slice(cols, func(i, j int) bool { return cols[i].pos < cols[j].pos })
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = c.name
+	}
+	return out, nil
+}
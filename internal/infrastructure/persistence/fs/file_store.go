@@ -3,6 +3,7 @@ package fs
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -73,6 +74,10 @@ func (s *FileStore) GetUnit(projectKey string, unitID string) (*domain.Unit, err
 	if err != nil {
 		return nil, err
 	}
+	b, _, err = migrateDocument(docKindUnit, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
 	var u domain.Unit
 	if err := json.Unmarshal(b, &u); err != nil {
 		return nil, err
@@ -81,6 +86,7 @@ func (s *FileStore) GetUnit(projectKey string, unitID string) (*domain.Unit, err
 }
 
 func (s *FileStore) SaveUnit(projectKey string, u *domain.Unit) error {
+	u.SchemaVersion = domain.UnitSchemaVersion
 	if err := os.MkdirAll(filepath.Dir(s.unitPath(projectKey, u.UnitID)), 0o755); err != nil {
 		return err
 	}
@@ -113,6 +119,102 @@ func (s *FileStore) ListUnitIDs(projectKey string) ([]string, error) {
 	return ids, nil
 }
 
+// ReadProjectConfig returns the project config JSON upgraded to the latest schema.
+func (s *FileStore) ReadProjectConfig(projectKey string) ([]byte, error) {
+	path := s.projectConfigPath(projectKey)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b, _, err = migrateDocument(docKindProjectConfig, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+func (s *FileStore) WriteProjectConfig(projectKey string, b []byte) (string, error) {
+	path := s.projectConfigPath(projectKey)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Migrate rewrites every outdated unit and config file of a project (or of all
+// projects when projectKey is empty) in place at the latest schema version.
+func (s *FileStore) Migrate(projectKey string) ([]map[string]any, error) {
+	keys := []string{}
+	if strings.TrimSpace(projectKey) != "" {
+		keys = append(keys, safeProjectKey(projectKey))
+	} else {
+		entries, err := os.ReadDir(filepath.Join(s.baseDir, "projects"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				keys = append(keys, e.Name())
+			}
+		}
+	}
+
+	out := []map[string]any{}
+	for _, key := range keys {
+		res, err := migrateFile(docKindProjectConfig, s.projectConfigPath(key))
+		if err != nil && !os.IsNotExist(err) {
+			return out, err
+		}
+		if res != nil {
+			out = append(out, res)
+		}
+
+		unitIDs, err := s.ListUnitIDs(key)
+		if err != nil {
+			return out, err
+		}
+		for _, id := range unitIDs {
+			res, err := migrateFile(docKindUnit, s.unitPath(key, id))
+			if err != nil {
+				return out, err
+			}
+			out = append(out, res)
+		}
+	}
+	return out, nil
+}
+
+func migrateFile(kind string, path string) (map[string]any, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b, from, err := migrateDocument(kind, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	to := latestSchemaVersion(kind)
+	if from != to {
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]any{
+		"kind":     kind,
+		"path":     path,
+		"from":     from,
+		"to":       to,
+		"migrated": from != to,
+	}, nil
+}
+
+func (s *FileStore) projectConfigPath(projectKey string) string {
+	return filepath.Join(s.baseDir, "projects", safeProjectKey(projectKey), "config.json")
+}
+
 func (s *FileStore) unitPath(projectKey string, unitID string) string {
 	return filepath.Join(s.baseDir, "projects", safeProjectKey(projectKey), "units", unitID+".json")
 }
@@ -0,0 +1,143 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+)
+
+const (
+	docKindUnit          = "unit"
+	docKindProjectConfig = "project_config"
+)
+
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
+// migration upgrades a raw JSON document of one kind from version From to From+1.
+type migration struct {
+	Kind  string
+	From  int
+	Apply func(doc map[string]any) error
+}
+
+// migrations is the registry of all known upgrades, applied in order on read.
+// Documents written before schema_version existed are treated as version 0.
+var migrations = []migration{
+	{Kind: docKindUnit, From: 0, Apply: func(doc map[string]any) error { return nil }},
+	{Kind: docKindProjectConfig, From: 0, Apply: func(doc map[string]any) error { return nil }},
+	{Kind: docKindUnit, From: 1, Apply: migrateUnitRunStatus},
+	// v3 adds the optional unit "history" list; older files need no rewrite.
+	{Kind: docKindUnit, From: 2, Apply: func(doc map[string]any) error { return nil }},
+	// v4 adds optional retry_attempts / retry_interval_ms to db checks.
+	{Kind: docKindUnit, From: 3, Apply: func(doc map[string]any) error { return nil }},
+	// v5 adds optional rows / row_count / expect_rows and object assertions
+	// to db checks.
+	{Kind: docKindUnit, From: 4, Apply: func(doc map[string]any) error { return nil }},
+	// v6 adds optional setup / teardown steps and isolation to runs.
+	{Kind: docKindUnit, From: 5, Apply: func(doc map[string]any) error { return nil }},
+	// v2 adds the optional specs_dir to project configs.
+	{Kind: docKindProjectConfig, From: 1, Apply: func(doc map[string]any) error { return nil }},
+	// v3 adds the optional templates_dir to project configs.
+	{Kind: docKindProjectConfig, From: 2, Apply: func(doc map[string]any) error { return nil }},
+	// v4 adds the optional datasources to project configs.
+	{Kind: docKindProjectConfig, From: 3, Apply: func(doc map[string]any) error { return nil }},
+	// v5 adds the optional protected flag to datasources.
+	{Kind: docKindProjectConfig, From: 4, Apply: func(doc map[string]any) error { return nil }},
+}
+
+// migrateUnitRunStatus derives the lifecycle status of runs recorded before
+// statuses were tracked, when every run stayed "in_progress" forever.
+func migrateUnitRunStatus(doc map[string]any) error {
+	runs, _ := doc["runs"].([]any)
+	for _, it := range runs {
+		run, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		if status, _ := run["status"].(string); status != "" && status != domain.RunStatusInProgress {
+			continue
+		}
+		meta, _ := run["meta"].(map[string]any)
+		if replay, ok := meta["replay_result"].(map[string]any); ok {
+			if passed, _ := replay["ok"].(bool); passed {
+				run["status"] = domain.RunStatusReplayPassed
+			} else {
+				run["status"] = domain.RunStatusReplayFailed
+			}
+			continue
+		}
+		if artifacts, _ := run["artifacts"].(map[string]any); len(artifacts) > 0 {
+			run["status"] = domain.RunStatusCrystallized
+			continue
+		}
+		run["status"] = domain.RunStatusInProgress
+	}
+	return nil
+}
+
+func latestSchemaVersion(kind string) int {
+	switch kind {
+	case docKindUnit:
+		return domain.UnitSchemaVersion
+	case docKindProjectConfig:
+		return domain.ProjectConfigSchemaVersion
+	default:
+		return 0
+	}
+}
+
+func schemaVersionOf(doc map[string]any) int {
+	switch v := doc["schema_version"].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// migrateDocument upgrades raw JSON to the latest version of kind and returns
+// the upgraded bytes together with the version the document was stored with.
+func migrateDocument(kind string, raw []byte) ([]byte, int, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, 0, err
+	}
+	from := schemaVersionOf(doc)
+	latest := latestSchemaVersion(kind)
+	if from > latest {
+		return nil, from, fmt.Errorf("%w: %s schema_version %d is newer than supported %d; upgrade syzygy-mcp", ErrUnsupportedSchemaVersion, kind, from, latest)
+	}
+	if from == latest {
+		return raw, from, nil
+	}
+
+	for v := from; v < latest; v++ {
+		m, ok := findMigration(kind, v)
+		if !ok {
+			return nil, from, fmt.Errorf("no migration registered for %s schema_version %d", kind, v)
+		}
+		if err := m.Apply(doc); err != nil {
+			return nil, from, fmt.Errorf("migrate %s from schema_version %d: %w", kind, v, err)
+		}
+		doc["schema_version"] = v + 1
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, from, err
+	}
+	return b, from, nil
+}
+
+func findMigration(kind string, from int) (migration, bool) {
+	for _, m := range migrations {
+		if m.Kind == kind && m.From == from {
+			return m, true
+		}
+	}
+	return migration{}, false
+}
@@ -0,0 +1,997 @@
+// Package replay runs crystallized specs in-process for units without UI
+// steps, so replay does not need Node, npm or Playwright.
+package replay
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cookchen233/syzygy-mcp-go/internal/domain"
+	"github.com/cookchen233/syzygy-mcp-go/internal/infrastructure/datasource"
+)
+
+// Same placeholder and named parameter syntax as syzygy-runner.
+var (
+	placeholder = regexp.MustCompile(`\$\{([^}]+)\}`)
+	unsafeLabel = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+)
+
+// recentMax bounds the responses listed when a net.must rule is not observed.
+const recentMax = 50
+
+type EngineConfig struct {
+	// Timeout applies to each HTTP request; zero means 30s.
+	Timeout time.Duration
+}
+
+// Engine executes db.exec, net.call and util.* steps and DB checks with the
+// semantics of syzygy-runner: ${var} substitution from variables, env and
+// anchors, relative URLs resolved against api_origin or base_url, string
+// comparison of expectations and retries for DB checks.
+type Engine struct {
+	timeout time.Duration
+}
+
+func NewEngine(cfg EngineConfig) *Engine {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &Engine{timeout: cfg.Timeout}
+}
+
+// Replay runs specs in order, sharing anchors; the last spec is the unit
+// itself and seeds the anchors, as in the runner. Teardown steps of every spec
+// that started run afterwards in reverse order, whether or not it failed,
+// after the tables of opts.Snapshot were read.
+func (e *Engine) Replay(specs []*domain.Spec, opts domain.ReplayOptions) *domain.ReplayReport {
+	var unit *domain.Spec
+	if len(specs) > 0 {
+		unit = specs[len(specs)-1]
+	}
+	s := e.newSession(unit, opts)
+	s.rollback = unit != nil && unit.Isolation == domain.IsolationRollback
+	defer s.close()
+
+	var started []*domain.Spec
+	for _, spec := range specs {
+		started = append(started, spec)
+		if err := s.runSpec(spec); err != nil {
+			s.report.Error = err.Error()
+			if p := s.writeArtifact("replay-failed", map[string]any{
+				"unit_id": spec.UnitID,
+				"message": err.Error(),
+				"anchors": s.anchors,
+				"recent":  s.recent,
+			}); p != "" {
+				s.report.FailureArtifacts = append(s.report.FailureArtifacts, p)
+			}
+			break
+		}
+	}
+	if len(opts.Snapshot) > 0 {
+		s.report.Snapshot = s.snapshot(opts.Snapshot)
+	}
+	s.teardown(started)
+	if s.rollback {
+		s.rollbackAll()
+		s.report.RolledBack = true
+		s.logf("isolation: rolled back")
+	}
+	s.report.OK = s.report.Error == ""
+	s.report.Anchors = s.anchors
+	return s.report
+}
+
+// RunFixtures runs the setup steps of specs, then replay unless a setup step
+// failed, then reads the tables of opts.Snapshot and runs the teardown steps
+// of every spec whose setup ran in reverse order, all in one session: replay
+// gets the anchors captured so far, and teardown sees those and the anchors
+// replay returns.
+func (e *Engine) RunFixtures(specs []*domain.Spec, opts domain.ReplayOptions, replay func(anchors map[string]string) map[string]string) *domain.ReplayReport {
+	var unit *domain.Spec
+	if len(specs) > 0 {
+		unit = specs[len(specs)-1]
+	}
+	s := e.newSession(unit, opts)
+	defer s.close()
+
+	var started []*domain.Spec
+	for _, spec := range specs {
+		started = append(started, spec)
+		outcomes := s.runSteps(spec, spec.Setup, false)
+		s.report.Setup = append(s.report.Setup, outcomes...)
+		if o := failedStep(outcomes); o != nil {
+			s.report.Error = strings.TrimSpace(fmt.Sprintf("setup failed: %s: %s", o.Name, o.Error))
+			break
+		}
+	}
+	if s.report.Error == "" {
+		anchors := map[string]string{}
+		for k, v := range s.anchors {
+			anchors[k] = v
+		}
+		for k, v := range replay(anchors) {
+			s.anchors[k] = v
+		}
+	}
+	if len(opts.Snapshot) > 0 {
+		s.report.Snapshot = s.snapshot(opts.Snapshot)
+	}
+	s.teardown(started)
+	s.report.OK = s.report.Error == ""
+	s.report.Anchors = s.anchors
+	return s.report
+}
+
+func failedStep(outcomes []domain.StepOutcome) *domain.StepOutcome {
+	for i := range outcomes {
+		if !outcomes[i].OK {
+			return &outcomes[i]
+		}
+	}
+	return nil
+}
+
+// RunnerEnv resolves the datasource dms names for spec, as a replay would,
+// and returns it as the MYSQL_* settings of the Node runner, or nil without
+// project datasources. Only MySQL datasources can be handed to the runner.
+func (e *Engine) RunnerEnv(spec *domain.Spec, dms string, opts domain.ReplayOptions) (map[string]string, error) {
+	name, ds, ok, err := domain.ResolveDatasource(opts.Datasources, dms, spec.Env)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if driver := domain.NormalizeDriver(ds.Driver); driver != domain.DriverMySQL {
+		return nil, fmt.Errorf("datasource %s is %s; the node runner only reaches MySQL", name, driver)
+	}
+	s := e.newSession(spec, opts)
+	defer s.close()
+	env, err := datasource.MySQLEnv(s.expandDSN(ds.DSN))
+	if err != nil {
+		return nil, fmt.Errorf("datasource %s: %w", name, err)
+	}
+	return env, nil
+}
+
+// RunDBChecks runs checks once against the current state of their datasources,
+// with ${var} resolved from the spec variables, env and anchors.
+func (e *Engine) RunDBChecks(spec *domain.Spec, checks []*domain.DbCheck, opts domain.ReplayOptions) []*domain.DbCheckResult {
+	s := e.newSession(spec, opts)
+	defer s.close()
+
+	out := make([]*domain.DbCheckResult, 0, len(checks))
+	for _, c := range checks {
+		db, err := s.conn(c.DMS)
+		if err != nil {
+			out = append(out, &domain.DbCheckResult{CheckID: c.CheckID, Name: c.Name, SQL: c.SQL, Args: []any{}, Rows: []map[string]any{}, Asserts: []domain.DbAssertResult{}, Error: err.Error()})
+			continue
+		}
+		s.missing = nil
+		r := s.evaluate(db, c)
+		r.Warnings = s.missing
+		out = append(out, r)
+	}
+	return out
+}
+
+// maxSnapshotRows caps the rows Snapshot reads per table.
+const maxSnapshotRows = 10000
+
+// Snapshot reads tables ([datasource:]table) from the datasources a replay of
+// spec would use, newest primary key first so rows a replay inserts stay within
+// the row limit.
+func (e *Engine) Snapshot(spec *domain.Spec, tables []string, opts domain.ReplayOptions) []*domain.TableSnapshot {
+	s := e.newSession(spec, opts)
+	defer s.close()
+	return s.snapshot(tables)
+}
+
+// snapshot reads tables through the session's connections, so it sees what
+// the session wrote even inside a rollback transaction.
+func (s *session) snapshot(tables []string) []*domain.TableSnapshot {
+	out := make([]*domain.TableSnapshot, 0, len(tables))
+	for _, ref := range tables {
+		snap := &domain.TableSnapshot{Table: ref, PrimaryKey: []string{}, Rows: []map[string]any{}}
+		out = append(out, snap)
+		dms, table, err := domain.ParseTableRef(ref)
+		if err != nil {
+			snap.Error = err.Error()
+			continue
+		}
+		snap.Table = table
+		db, err := s.conn(dms)
+		if err != nil {
+			snap.Error = err.Error()
+			continue
+		}
+		snap.Datasource = db.Name
+		if snap.PrimaryKey, err = db.PrimaryKey(table); err != nil {
+			snap.PrimaryKey, snap.Error = []string{}, err.Error()
+			continue
+		}
+		q := "SELECT * FROM " + table
+		if len(snap.PrimaryKey) > 0 {
+			q += " ORDER BY " + strings.Join(snap.PrimaryKey, " DESC, ") + " DESC"
+		}
+		rows, err := s.fetch(db, fmt.Sprintf("%s LIMIT %d", q, maxSnapshotRows+1), nil)
+		if err != nil {
+			snap.Error = err.Error()
+			continue
+		}
+		if len(rows) > maxSnapshotRows {
+			rows, snap.Truncated = rows[:maxSnapshotRows], true
+		}
+		snap.Rows = rows
+	}
+	return out
+}
+
+// newSession starts a session seeded with the anchors recorded in spec.
+func (e *Engine) newSession(spec *domain.Spec, opts domain.ReplayOptions) *session {
+	if opts.Since.IsZero() {
+		opts.Since = time.Now()
+	}
+	jar, _ := cookiejar.New(nil)
+	s := &session{
+		opts:    opts,
+		client:  &http.Client{Jar: jar, Timeout: e.timeout},
+		spec:    spec,
+		anchors: map[string]string{},
+		report:  &domain.ReplayReport{},
+	}
+	if spec != nil {
+		for k, v := range spec.Anchors {
+			s.anchors[k] = v
+		}
+	}
+	return s
+}
+
+type response struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// session is the state of one replay.
+type session struct {
+	opts    domain.ReplayOptions
+	client  *http.Client
+	dbs     map[string]*dbConn
+	spec    *domain.Spec
+	anchors map[string]string
+	rules   []domain.NetRule
+	hits    []bool
+	recent  []response
+	report  *domain.ReplayReport
+	// missing collects unresolved ${var} references for DB check results.
+	missing []string
+	// rollback runs every datasource in a transaction that is never
+	// committed.
+	rollback bool
+}
+
+// dbConn is an open datasource of a replay.
+type dbConn struct {
+	*datasource.DB
+	// label locates the database in error messages, desc in artifacts.
+	label string
+	desc  map[string]any
+	tx    *sql.Tx
+	// loc is the zone of zone-less timestamps, read on first use.
+	loc *time.Location
+}
+
+func (c *dbConn) location() (*time.Location, error) {
+	if c.loc == nil {
+		loc, err := c.Location()
+		if err != nil {
+			return nil, fmt.Errorf("time zone of %s: %w", c.label, err)
+		}
+		c.loc = loc
+	}
+	return c.loc, nil
+}
+
+func (c *dbConn) Exec(query string, args ...any) (sql.Result, error) {
+	if c.tx != nil {
+		return c.tx.Exec(query, args...)
+	}
+	return c.DB.Exec(query, args...)
+}
+
+func (c *dbConn) Query(query string, args ...any) (*sql.Rows, error) {
+	if c.tx != nil {
+		return c.tx.Query(query, args...)
+	}
+	return c.DB.Query(query, args...)
+}
+
+func (s *session) close() {
+	s.rollbackAll()
+	for _, db := range s.dbs {
+		db.Close()
+	}
+}
+
+// teardown runs the teardown steps of specs in reverse order, each step
+// whether or not an earlier one failed.
+func (s *session) teardown(specs []*domain.Spec) {
+	for i := len(specs) - 1; i >= 0; i-- {
+		if len(specs[i].Teardown) == 0 {
+			continue
+		}
+		s.logf("teardown: %s", specs[i].UnitID)
+		s.report.Teardown = append(s.report.Teardown, s.runSteps(specs[i], specs[i].Teardown, true)...)
+	}
+}
+
+func (s *session) rollbackAll() {
+	for _, db := range s.dbs {
+		if db.tx != nil {
+			db.tx.Rollback()
+			db.tx = nil
+		}
+	}
+}
+
+func (s *session) logf(format string, args ...any) {
+	s.report.Log = append(s.report.Log, "[syzygy] "+fmt.Sprintf(format, args...))
+}
+
+func (s *session) runSpec(spec *domain.Spec) error {
+	s.spec = spec
+	for _, o := range s.runSteps(spec, spec.Setup, false) {
+		if !o.OK {
+			return fmt.Errorf("setup failed: %s", o.Error)
+		}
+	}
+	rules, err := spec.AllNetRules()
+	if err != nil {
+		return err
+	}
+	s.rules, s.hits = rules, make([]bool, len(rules))
+
+	for _, st := range spec.Steps {
+		if err := s.runStep(st); err != nil {
+			return err
+		}
+	}
+
+	for i, rule := range s.rules {
+		if s.hits[i] {
+			continue
+		}
+		tail := []string{}
+		for _, r := range s.recent[max(0, len(s.recent)-20):] {
+			tail = append(tail, fmt.Sprintf("%s %d %s", r.Method, r.Status, r.URL))
+		}
+		msg := fmt.Sprintf("Net check failed: missing request method=%s url_contains=%s status=%s",
+			orStar(rule.Method), orStar(s.sub(rule.URLContains)), orStar(rule.Status.String()))
+		if len(tail) > 0 {
+			msg += "\nRecent responses:\n" + strings.Join(tail, "\n")
+		}
+		return errors.New(msg)
+	}
+
+	for _, c := range spec.DBChecks {
+		if err := s.runCheck(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runSteps runs fixture steps and reports each; unless keepGoing it stops at
+// the first failure. A ${var} that does not resolve fails the step: a fixture
+// would otherwise seed or clean up the wrong rows.
+func (s *session) runSteps(spec *domain.Spec, steps []*domain.ActionStep, keepGoing bool) []domain.StepOutcome {
+	s.spec = spec
+	out := []domain.StepOutcome{}
+	for _, st := range steps {
+		o := domain.StepOutcome{UnitID: spec.UnitID, StepID: st.StepID, Name: st.Name, OK: true}
+		s.missing = nil
+		if err := s.runStep(st); err != nil {
+			o.OK, o.Error = false, err.Error()
+		} else if len(s.missing) > 0 {
+			o.OK, o.Error = false, strings.Join(s.missing, "; ")
+		}
+		out = append(out, o)
+		if !o.OK && !keepGoing {
+			break
+		}
+	}
+	return out
+}
+
+func (s *session) runStep(st *domain.ActionStep) error {
+	op, err := st.Op()
+	if err != nil {
+		return fmt.Errorf("step %s: %w", st.Name, err)
+	}
+	if op == nil {
+		s.logf("step: %s (op=none)", st.Name)
+		return nil
+	}
+	s.logf("step: %s (op=%s)", st.Name, op.OpName())
+
+	switch o := op.(type) {
+	case *domain.UtilGenID:
+		v := strconv.FormatInt(time.Now().UnixMilli()*1000+rand.Int63n(1000), 10)
+		s.anchors[o.Key] = v
+		s.logf("util.gen_id: %s=%s", o.Key, v)
+	case *domain.UtilGenTS:
+		v := time.Now().UTC().Format("2006-01-02-15-04-05")
+		s.anchors[o.Key] = v
+		s.logf("util.gen_ts: %s=%s", o.Key, v)
+	case *domain.DBExec:
+		db, err := s.conn(o.DMS)
+		if err != nil {
+			return fmt.Errorf("db.exec failed: step=%s err=%v", st.Name, err)
+		}
+		if db.Datasource.ReadOnly {
+			return fmt.Errorf("db.exec failed: step=%s err=datasource %s is read-only", st.Name, db.Name)
+		}
+		if db.Datasource.Protected {
+			if err := domain.CheckReadOnlySQL(o.SQL); err != nil {
+				return fmt.Errorf("db.exec failed: step=%s err=datasource %s is protected: %v", st.Name, db.Name, err)
+			}
+		}
+		q, args := db.Bind(o.SQL, o.Params, s.sub)
+		s.logf("db.exec: sql=%s values=%s", q, jsonText(args))
+		if _, err := db.Exec(q, args...); err != nil {
+			return fmt.Errorf("db.exec failed: step=%s sql=%s err=%v", st.Name, q, err)
+		}
+	case *domain.NetCall:
+		return s.call(st, o)
+	default:
+		return fmt.Errorf("op %s needs the browser runner; step=%s", op.OpName(), st.Name)
+	}
+	return nil
+}
+
+func (s *session) ctx() map[string]string {
+	out := map[string]string{}
+	for _, m := range []map[string]any{s.spec.Variables, s.spec.Env} {
+		for k, v := range m {
+			out[k] = str(v)
+		}
+	}
+	for k, v := range s.anchors {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *session) sub(raw string) string {
+	ctx := s.ctx()
+	return placeholder.ReplaceAllStringFunc(raw, func(m string) string {
+		key := m[2 : len(m)-1]
+		v, ok := ctx[key]
+		if !ok {
+			s.logf("Warning: variable %q not found in context", key)
+			s.missing = append(s.missing, fmt.Sprintf("variable %q not found in context", key))
+		}
+		return v
+	})
+}
+
+func (s *session) deepSub(v any) any {
+	switch t := v.(type) {
+	case string:
+		return s.sub(t)
+	case map[string]any:
+		out := map[string]any{}
+		for k, it := range t {
+			out[k] = s.deepSub(it)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, it := range t {
+			out[i] = s.deepSub(it)
+		}
+		return out
+	}
+	return v
+}
+
+// absURL resolves /path against api_origin or the origin of base_url.
+func (s *session) absURL(raw string) string {
+	if !strings.HasPrefix(raw, "/") {
+		return raw
+	}
+	ctx := s.ctx()
+	for _, k := range []string{"api_origin", "API_ORIGIN"} {
+		if o := ctx[k]; strings.HasPrefix(o, "http://") || strings.HasPrefix(o, "https://") {
+			return strings.TrimSuffix(o, "/") + raw
+		}
+	}
+	for _, k := range []string{"base_url", "BASE_URL"} {
+		if u, err := url.Parse(ctx[k]); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return u.Scheme + "://" + u.Host + raw
+		}
+	}
+	return raw
+}
+
+// call performs a net.call. Without an Authorization header SYZYGY_AUTH_TOKEN
+// is sent as a bearer token, standing in for the token the browser holds.
+func (s *session) call(st *domain.ActionStep, c *domain.NetCall) error {
+	method := strings.ToUpper(c.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	target := s.absURL(s.sub(c.URL))
+	fail := func(err error) error {
+		if c.Require != nil {
+			msg := "prerequisite not satisfied"
+			if c.Require.Message != "" {
+				msg = s.sub(c.Require.Message)
+			}
+			need := ""
+			if c.Require.NeedUnit != "" {
+				need = " need_unit=" + c.Require.NeedUnit
+			}
+			return fmt.Errorf("Requirement failed: %s%s. step=%s. url=%s err=%v", msg, need, st.Name, target, err)
+		}
+		return fmt.Errorf("net.call failed: step=%s url=%s err=%v", st.Name, target, err)
+	}
+
+	var body io.Reader
+	header := http.Header{}
+	if c.JSON != nil {
+		b, err := json.Marshal(s.deepSub(c.JSON))
+		if err != nil {
+			return fail(err)
+		}
+		body = bytes.NewReader(b)
+		header.Set("Content-Type", "application/json")
+	} else if c.Form != nil {
+		form := url.Values{}
+		for k, v := range c.Form {
+			form.Set(k, s.sub(str(v)))
+		}
+		body = strings.NewReader(form.Encode())
+		header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range c.Headers {
+		header.Set(k, s.sub(str(v)))
+	}
+	if header.Get("Authorization") == "" && s.opts.Env["SYZYGY_AUTH_TOKEN"] != "" {
+		header.Set("Authorization", "Bearer "+s.opts.Env["SYZYGY_AUTH_TOKEN"])
+	}
+
+	s.logf("net.call: %s %s", method, target)
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return fail(err)
+	}
+	req.Header = header
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	raw, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return fail(err)
+	}
+	var doc any
+	if strings.Contains(res.Header.Get("Content-Type"), "application/json") {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if dec.Decode(&doc) != nil {
+			doc = nil
+		}
+	}
+	s.observe(method, target, res.StatusCode, doc)
+
+	if c.Status != "" && c.Status.String() != strconv.Itoa(res.StatusCode) {
+		return fail(fmt.Errorf("status mismatch expected=%s actual=%d", c.Status, res.StatusCode))
+	}
+	if len(c.ExpectJSON)+len(c.ExpectJSONPath)+len(c.ExpectPresent) > 0 && !isObject(doc) {
+		return fail(errors.New("response json is not object"))
+	}
+	for _, k := range sortedKeys(c.ExpectJSON) {
+		obj, _ := doc.(map[string]any)
+		v, ok := obj[k]
+		if want, got := s.sub(str(c.ExpectJSON[k])), jsString(v, ok); want != got {
+			return fail(fmt.Errorf("expect_json mismatch key=%s expected=%s actual=%s", k, want, got))
+		}
+	}
+	for _, jp := range sortedKeys(c.ExpectJSONPath) {
+		v, ok := domain.JSONPath(doc, jp)
+		if want, got := s.sub(str(c.ExpectJSONPath[jp])), jsString(v, ok); want != got {
+			return fail(fmt.Errorf("expect_jsonpath mismatch path=%s expected=%s actual=%s", jp, want, got))
+		}
+	}
+	for _, jp := range c.ExpectPresent {
+		if v, ok := domain.JSONPath(doc, jp); !ok || v == nil {
+			return fail(fmt.Errorf("expect_present missing path=%s", jp))
+		}
+	}
+	var anchored map[string]any
+	if c.Anchor != nil && c.Anchor.Key != "" && c.Anchor.JSONPath != "" {
+		if doc == nil {
+			return fail(errors.New("anchor requires json response"))
+		}
+		v, ok := domain.JSONPath(doc, c.Anchor.JSONPath)
+		if !ok || v == nil {
+			return fail(fmt.Errorf("anchor jsonpath not found: %s", c.Anchor.JSONPath))
+		}
+		s.anchors[c.Anchor.Key] = jsString(v, true)
+		anchored = map[string]any{"key": c.Anchor.Key, "value": s.anchors[c.Anchor.Key], "jsonpath": c.Anchor.JSONPath}
+		s.logf("net.call anchor: %s=%s", c.Anchor.Key, s.anchors[c.Anchor.Key])
+	}
+
+	expectStatus := any(nil)
+	if c.Status != "" {
+		expectStatus = c.Status
+	}
+	s.writeArtifact("net-call", map[string]any{
+		"step":          st.Name,
+		"method":        method,
+		"url":           target,
+		"status":        res.StatusCode,
+		"expect_status": expectStatus,
+		"request":       map[string]any{"has_json": c.JSON != nil, "has_form": c.Form != nil},
+		"anchored":      anchored,
+		"body":          doc,
+	})
+	return nil
+}
+
+// observe matches a response against the net.must rules of the spec and
+// captures their anchors, like the runner's response listener.
+func (s *session) observe(method, target string, status int, doc any) {
+	s.recent = append(s.recent, response{Method: method, URL: target, Status: status})
+	if len(s.recent) > recentMax {
+		s.recent = s.recent[1:]
+	}
+	for i, rule := range s.rules {
+		if rule.Method != "" && !strings.EqualFold(rule.Method, method) {
+			continue
+		}
+		if contains := s.sub(rule.URLContains); contains != "" && !strings.Contains(target, contains) {
+			continue
+		}
+		if rule.Status != "" && rule.Status.String() != strconv.Itoa(status) {
+			continue
+		}
+		if !s.matches(doc, rule) {
+			continue
+		}
+		s.hits[i] = true
+		if doc == nil {
+			continue
+		}
+		captures := map[string]string{}
+		if rule.Anchor != nil && rule.Anchor.Key != "" && rule.Anchor.JSONPath != "" {
+			captures[rule.Anchor.Key] = rule.Anchor.JSONPath
+		}
+		for _, m := range []map[string]string{rule.CaptureAnchors, rule.Anchors} {
+			for k, jp := range m {
+				captures[k] = jp
+			}
+		}
+		for _, k := range sortedKeys(captures) {
+			if v, ok := domain.JSONPath(doc, captures[k]); ok && v != nil {
+				s.anchors[k] = jsString(v, true)
+				s.logf("net capture anchor: %s=%s", k, s.anchors[k])
+			}
+		}
+	}
+}
+
+func (s *session) matches(doc any, rule domain.NetRule) bool {
+	if len(rule.ExpectJSON)+len(rule.ExpectJSONPath) == 0 {
+		return true
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, want := range rule.ExpectJSON {
+		v, ok := obj[k]
+		if s.sub(str(want)) != jsString(v, ok) {
+			return false
+		}
+	}
+	for jp, want := range rule.ExpectJSONPath {
+		v, ok := domain.JSONPath(doc, jp)
+		if s.sub(str(want)) != jsString(v, ok) {
+			return false
+		}
+	}
+	return true
+}
+
+// env reads MYSQL_* style settings from the spec context, then the process env.
+func (s *session) env(key string) string {
+	ctx := s.ctx()
+	for _, k := range []string{key, strings.ToLower(key)} {
+		if v := ctx[k]; v != "" {
+			return v
+		}
+	}
+	return s.opts.Env[key]
+}
+
+// expandDSN replaces ${VAR} in a DSN from the spec context or the replay env.
+func (s *session) expandDSN(dsn string) string {
+	return placeholder.ReplaceAllStringFunc(dsn, func(m string) string {
+		return s.env(m[2 : len(m)-1])
+	})
+}
+
+// conn opens the datasource dms resolves to on first use. Without project
+// datasources it is the MySQL database from MYSQL_*, as in the runner.
+func (s *session) conn(dms string) (*dbConn, error) {
+	name, ds, ok, err := domain.ResolveDatasource(s.opts.Datasources, dms, s.spec.Env)
+	if err != nil {
+		return nil, err
+	}
+	if db := s.dbs[name]; db != nil {
+		return db, nil
+	}
+
+	var label string
+	var desc map[string]any
+	if ok {
+		ds.DSN = s.expandDSN(ds.DSN)
+		label = "datasource=" + name
+		desc = map[string]any{"name": name, "driver": domain.NormalizeDriver(ds.Driver), "read_only": ds.ReadOnly, "protected": ds.Protected}
+	} else {
+		host, user, database := s.env("MYSQL_HOST"), s.env("MYSQL_USER"), s.env("MYSQL_DATABASE")
+		if host == "" || user == "" || database == "" {
+			return nil, errors.New("Missing MySQL env. Required: MYSQL_HOST, MYSQL_USER, MYSQL_DATABASE (and MYSQL_PASSWORD if needed)")
+		}
+		port := s.env("MYSQL_PORT")
+		if port == "" {
+			port = "3306"
+		}
+		ds = domain.Datasource{Driver: domain.DriverMySQL, DSN: datasource.MySQLDSN(host, port, user, s.env("MYSQL_PASSWORD"), database)}
+		label = fmt.Sprintf("mysql=%s:%s/%s", host, port, database)
+		desc = map[string]any{"driver": domain.DriverMySQL, "host": host, "port": port, "database": database, "user": user}
+	}
+	db, err := datasource.Open(name, ds)
+	if err != nil {
+		return nil, err
+	}
+	if s.dbs == nil {
+		s.dbs = map[string]*dbConn{}
+	}
+	conn := &dbConn{DB: db, label: label, desc: desc}
+	if s.rollback {
+		if conn.tx, err = db.Begin(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("begin transaction on %s: %w", label, err)
+		}
+	}
+	s.dbs[name] = conn
+	return conn, nil
+}
+
+// runCheck runs a DB check with retries; assertions apply to the first row.
+func (s *session) runCheck(c *domain.DbCheck) error {
+	db, err := s.conn(c.DMS)
+	if err != nil {
+		return fmt.Errorf("DB check failed: %s - %v", c.Name, err)
+	}
+	attempts := c.RetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	interval := time.Duration(c.RetryIntervalMS) * time.Millisecond
+	if interval == 0 {
+		interval = 500 * time.Millisecond
+	}
+	for i := 0; i < attempts; i++ {
+		if err = s.check(db, c); err == nil {
+			return nil
+		}
+		if i < attempts-1 {
+			time.Sleep(interval)
+		}
+	}
+
+	p := s.writeArtifact("db-check-failed", map[string]any{
+		"message": err.Error(),
+		"check": map[string]any{
+			"name":   c.Name,
+			"dms":    c.DMS,
+			"sql":    c.SQL,
+			"params": c.Params,
+			"assert": c.Assert,
+		},
+		"datasource": db.desc,
+		"anchors":    s.anchors,
+		"env":        s.spec.Env,
+	})
+	if p != "" {
+		s.report.FailureArtifacts = append(s.report.FailureArtifacts, p)
+	}
+	return fmt.Errorf("DB check failed: %s - %v (%s)", c.Name, err, db.label)
+}
+
+func (s *session) check(db *dbConn, c *domain.DbCheck) error {
+	if r := s.evaluate(db, c); !r.OK {
+		return errors.New(r.Error)
+	}
+	return nil
+}
+
+// maxCheckRows caps the rows a DB check result carries; assertions see all
+// rows.
+const maxCheckRows = 100
+
+// evaluate runs a DB check once and reports every assertion; the first
+// failure becomes the error.
+func (s *session) evaluate(db *dbConn, c *domain.DbCheck) *domain.DbCheckResult {
+	q, args := db.Bind(c.SQL, c.Params, s.sub)
+	s.logf("assertDb: sql=%s values=%s", q, jsonText(args))
+	res := &domain.DbCheckResult{
+		CheckID:    c.CheckID,
+		Name:       c.Name,
+		Datasource: db.desc,
+		SQL:        q,
+		Args:       args,
+		Rows:       []map[string]any{},
+		Asserts:    []domain.DbAssertResult{},
+	}
+	if err := domain.CheckSelectSQL(c.SQL); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	rows, err := s.fetch(db, q, args)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Rows = rows
+	if len(rows) > maxCheckRows {
+		res.Rows, res.Truncated = rows[:maxCheckRows], true
+	}
+
+	loc, err := db.location()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Asserts, err = c.Evaluate(rows, s.deepSub, s.opts.Since.In(loc), time.Now().In(loc))
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.OK = res.Error == ""
+	return res
+}
+
+// fetch reads the result rows; values are strings, or nil for NULL.
+func (s *session) fetch(db *dbConn, q string, args []any) ([]map[string]any, error) {
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]any{}
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := map[string]any{}
+		for i, col := range cols {
+			row[col] = nil
+			if vals[i].Valid {
+				row[col] = vals[i].String
+			}
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// writeArtifact writes <ts>-<label>.json into the artifacts directory, named
+// like the runner's artifacts, and returns its path.
+func (s *session) writeArtifact(label string, payload any) string {
+	if s.opts.ArtifactsDir == "" {
+		return ""
+	}
+	if err := os.MkdirAll(s.opts.ArtifactsDir, 0o755); err != nil {
+		return ""
+	}
+	ts := strings.NewReplacer(":", "-", ".", "-").Replace(time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	p := filepath.Join(s.opts.ArtifactsDir, ts+"-"+unsafeLabel.ReplaceAllString(label, "_")+".json")
+	b, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil || os.WriteFile(p, b, 0o644) != nil {
+		return ""
+	}
+	return p
+}
+
+// jsString formats a response value the way the runner's String() does.
+func jsString(v any, ok bool) string {
+	if !ok {
+		return "undefined"
+	}
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return jsonText(v)
+}
+
+// str formats a spec value (variables, env, params) as a context string.
+func str(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return jsonText(v)
+}
+
+func isObject(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return true
+	}
+	return false
+}
+
+func jsonText(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func orStar(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
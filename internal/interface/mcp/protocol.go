@@ -46,3 +46,7 @@ type ToolsCallParams struct {
 	Name      string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
 }
+
+type ResourcesReadParams struct {
+	URI string `json:"uri"`
+}
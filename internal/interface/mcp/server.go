@@ -13,6 +13,7 @@ import (
 
 	"github.com/cookchen233/syzygy-mcp-go/internal/application"
 	"github.com/cookchen233/syzygy-mcp-go/internal/infrastructure/persistence/fs"
+	"github.com/cookchen233/syzygy-mcp-go/internal/infrastructure/replay"
 )
 
 type ServerConfig struct {
@@ -48,7 +49,7 @@ func NewServer(cfg ServerConfig) *Server {
 		BaseDir: dataDir,
 	})
 
-	app := application.NewApp(store, cfg.Logger)
+	app := application.NewApp(store, replay.NewEngine(replay.EngineConfig{}), cfg.Logger)
 
 	return &Server{
 		cfg: cfg,
@@ -120,7 +121,10 @@ func (s *Server) handle(req JSONRPCRequest) *JSONRPCResponse {
 		resp := NewResultResponse(req.ID, map[string]any{"prompts": []any{}})
 		return &resp
 	case "resources/list":
-		resp := NewResultResponse(req.ID, map[string]any{"resources": []any{}})
+		resp := NewResultResponse(req.ID, map[string]any{"resources": s.app.ResourceRegistry().ListResources()})
+		return &resp
+	case "resources/read":
+		resp := s.handleResourcesRead(req)
 		return &resp
 	case "tools/list":
 		resp := s.handleToolsList(req)
@@ -155,6 +159,19 @@ func (s *Server) handleToolsList(req JSONRPCRequest) JSONRPCResponse {
 	return NewResultResponse(req.ID, map[string]any{"tools": tools})
 }
 
+func (s *Server) handleResourcesRead(req JSONRPCRequest) JSONRPCResponse {
+	var params ResourcesReadParams
+	if err := decodeParams(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrInvalidParams, "invalid params", err.Error())
+	}
+
+	content, err := s.app.ResourceRegistry().ReadResource(params.URI)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrInvalidParams, err.Error(), params.URI)
+	}
+	return NewResultResponse(req.ID, map[string]any{"contents": []any{content}})
+}
+
 func (s *Server) handleToolsCall(req JSONRPCRequest, _ requestContext) JSONRPCResponse {
 	var params ToolsCallParams
 	if err := decodeParams(req.Params, &params); err != nil {
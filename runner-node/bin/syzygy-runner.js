@@ -136,6 +136,19 @@ function assertJsonPathExpect(body, expectJsonPath, ctx) {
   }
 }
 
+function assertJsonPathPresent(body, paths) {
+  if (!body || typeof body !== 'object') {
+    throw new Error('response json is not object')
+  }
+  for (const jp of paths) {
+    const out = JSONPath({ path: String(jp), json: body })
+    const v = Array.isArray(out) ? out[0] : out
+    if (v === undefined || v === null) {
+      throw new Error(`expect_present missing path=${jp}`)
+    }
+  }
+}
+
 function buildContext(spec, anchors) {
   const ctx = {
     ...(spec.variables || {}),
@@ -212,9 +225,27 @@ function genBigintIdString() {
   return (ms * 1_000n + rand).toString()
 }
 
+// isExtendedCheck mirrors DbCheck.Extended of syzygy-mcp: assertions beyond
+// rows[0] string equality and not_null / not_empty.
+function isExtendedCheck(check) {
+  if ((check.rows && check.rows !== 'first') || check.row_count !== undefined || (check.expect_rows || []).length > 0) {
+    return true
+  }
+  return Object.values(check.assert || {}).some((v) => typeof v !== 'string')
+}
+
 async function assertDb(spec, anchors) {
+  // SYZYGY_DB_CHECKS=basic: syzygy_replay evaluates the extended checks itself
+  // once the runner passed.
+  const checks = (spec.db_checks || []).filter((check) => {
+    if (process.env.SYZYGY_DB_CHECKS === 'basic' && isExtendedCheck(check)) {
+      console.log(`[syzygy] assertDb: ${check.name || ''} is evaluated by syzygy_replay, skipping`)
+      return false
+    }
+    return true
+  })
   // Skip DB assertions if no db_checks defined
-  if (!spec.db_checks || spec.db_checks.length === 0) {
+  if (checks.length === 0) {
     console.log('[syzygy] assertDb: no db_checks, skipping')
     return
   }
@@ -222,7 +253,7 @@ async function assertDb(spec, anchors) {
   const mysqlCfg = getMysqlConfigFromEnv(ctx)
   const conn = await mysql.createConnection(mysqlCfg)
   try {
-    for (const check of spec.db_checks) {
+    for (const check of checks) {
       const attempts = check.retry_attempts ? Number(check.retry_attempts) : 1
       const intervalMs = check.retry_interval_ms ? Number(check.retry_interval_ms) : 500
 
@@ -528,6 +559,9 @@ async function runSteps(page, spec, anchors) {
         if (net.expect_jsonpath) {
           assertJsonPathExpect(body, net.expect_jsonpath, curCtx)
         }
+        if (Array.isArray(net.expect_present)) {
+          assertJsonPathPresent(body, net.expect_present)
+        }
 
         if (net.anchor?.key && net.anchor?.jsonpath) {
           if (!body) {
@@ -786,16 +820,26 @@ async function runSteps(page, spec, anchors) {
   }
 }
 
+function parseAnchorsEnv() {
+  const raw = process.env.SYZYGY_ANCHORS
+  if (!raw) return {}
+  try {
+    return JSON.parse(raw)
+  } catch (err) {
+    die(`Invalid SYZYGY_ANCHORS: ${err.message}`)
+  }
+}
+
 async function main() {
   const arg1 = process.argv[2]
   if (arg1 === '--help' || arg1 === '-h') {
-    console.log('Usage: syzygy-runner <spec.json>\n\nEnv:\n  SYZYGY_SPEC=<spec.json>\n  HEADLESS=0 to run headed')
+    console.log('Usage: syzygy-runner <spec.json>\n\nEnv:\n  SYZYGY_SPEC=<spec.json>\n  SYZYGY_ANCHORS=<json> extra anchors\n  HEADLESS=0 to run headed')
     process.exit(0)
   }
 
   const specPath = arg1 || process.env.SYZYGY_SPEC
   if (!specPath) {
-    console.log('Usage: syzygy-runner <spec.json>\n\nEnv:\n  SYZYGY_SPEC=<spec.json>\n  HEADLESS=0 to run headed')
+    console.log('Usage: syzygy-runner <spec.json>\n\nEnv:\n  SYZYGY_SPEC=<spec.json>\n  SYZYGY_ANCHORS=<json> extra anchors\n  HEADLESS=0 to run headed')
     process.exit(0)
   }
   
@@ -871,7 +915,8 @@ async function main() {
   }
 
   const { spec: rootSpec } = await loadSpec(specPath)
-  const anchors = { ...(rootSpec.anchors || {}) }
+  // SYZYGY_ANCHORS: anchors captured by setup steps before the runner starts.
+  const anchors = { ...(rootSpec.anchors || {}), ...parseAnchorsEnv() }
 
   const browser = await chromium.launch({ headless: process.env.HEADLESS !== '0' })
   